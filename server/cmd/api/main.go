@@ -1,16 +1,20 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/RvShivam/inventify/internal/channels"
 	"github.com/RvShivam/inventify/internal/db"
 	"github.com/RvShivam/inventify/internal/events"
 	"github.com/RvShivam/inventify/internal/handlers"
 	"github.com/RvShivam/inventify/internal/middleware"
 	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/ondc"
+	"github.com/RvShivam/inventify/internal/services"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -45,18 +49,32 @@ func main() {
 		&models.Channel{},
 		&models.Product{},
 		&models.ProductImage{},
+		&models.ProductAttribute{},
+		&models.ProductVariant{},
 		&models.ProductChannel{},
 		&models.ProductChannelOverride{},
 		&models.ProductWoo{},
 		&models.ProductONDC{},
 		&models.SellerLocation{},
 		&models.ProductLocationStock{},
+		&models.StockTransfer{},
+		&models.StockTransferLine{},
+		&models.StockAdjustment{},
+		&models.StockAdjustmentLine{},
+		&models.Stocktake{},
+		&models.StocktakeLine{},
+		&models.StocktakeCount{},
 		&models.InventoryReservation{},
 		&models.InventoryMovement{},
 		&models.ChannelPublishLog{},
 		&models.WooStore{},
 		&models.WooStoreWebhook{},
+		&models.WebhookInbox{},
 		&models.Order{},
+		&models.OrderItem{},
+		&models.OrderFlag{},
+		&models.ImportJob{},
+		&models.ProductSyncDecision{},
 	); err != nil {
 		log.Fatal("AutoMigrate failed: ", err)
 	}
@@ -70,6 +88,20 @@ func main() {
 
 	log.Println("Postgres-level migrations applied")
 
+	// Normalize line items of orders stored before order_items existed
+	if n, err := services.BackfillOrderItems(dbconn); err != nil {
+		log.Println("order_items backfill failed:", err)
+	} else if n > 0 {
+		log.Printf("order_items backfilled for %d orders", n)
+	}
+
+	// Start every stock line's movement ledger at its current stock
+	if n, err := services.BackfillOpeningBalances(dbconn); err != nil {
+		log.Println("opening balance backfill failed:", err)
+	} else if n > 0 {
+		log.Printf("opening balances recorded for %d stock lines", n)
+	}
+
 	// Init RabbitMQ events (optional — will be NO-OP if RABBITMQ_URL is empty)
 	rabbitErr := events.InitRabbitMQ(os.Getenv("RABBITMQ_URL"))
 	if rabbitErr != nil {
@@ -82,8 +114,15 @@ func main() {
 		}
 	}()
 
+	// Sales channel adapters, keyed by Channel.Name
+	channels.Register(channels.NewWooAdapter(dbconn, services.NewProductService(dbconn).SyncProductToWoo))
+
 	// Start Consumers
 	events.StartOrderConsumer(dbconn)
+	events.StartWooProductConsumer(dbconn)
+	events.StartProductSyncConsumer(dbconn)
+	events.StartReservationSweeper(dbconn, events.ReservationSweepInterval)
+	events.StartStockReconciler(dbconn, events.StockReconcileInterval, os.Getenv("STOCK_RECONCILE_REPAIR") == "true")
 
 	// Router & routes
 	router := gin.Default()
@@ -95,7 +134,7 @@ func main() {
 			// This allows any connection from localhost, regardless of port
 			return strings.HasPrefix(origin, "http://localhost")
 		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Organization-Id"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true, // IMPORTANT: allow cookies / auth headers in cross-origin requests
@@ -111,6 +150,28 @@ func main() {
 	// public webhook receiver (Woo -> our server)
 	router.POST("/webhooks/woo", handlers.WooWebhookReceiver(dbconn))
 
+	// ONDC seller app (Beckn). Buyers call these; every request must be signed.
+	ondcCfg, err := ondc.LoadConfig()
+	switch {
+	case errors.Is(err, ondc.ErrNotConfigured):
+		log.Println("ONDC disabled:", err)
+	case err != nil:
+		log.Fatal("ONDC config: ", err)
+	default:
+		log.Printf("ONDC enabled as %s (key %s): %s", ondcCfg.SubscriberID, ondcCfg.UniqueKeyID, ondcCfg.PublicKey())
+		beckn := router.Group("/ondc")
+		beckn.Use(middleware.RequireBecknSignature(ondcCfg))
+		{
+			beckn.POST("/search", handlers.ONDCSearch(dbconn, ondcCfg))
+			beckn.POST("/select", handlers.ONDCSelect(dbconn, ondcCfg))
+			beckn.POST("/init", handlers.ONDCInit(dbconn, ondcCfg))
+			beckn.POST("/confirm", handlers.ONDCConfirm(dbconn, ondcCfg))
+			beckn.POST("/status", handlers.ONDCStatus(dbconn, ondcCfg))
+			beckn.POST("/cancel", handlers.ONDCCancel(dbconn, ondcCfg))
+			beckn.POST("/update", handlers.ONDCUpdate(dbconn, ondcCfg))
+		}
+	}
+
 	// protected API (user auth)
 	api := router.Group("/api")
 	api.Use(middleware.RequireAuth)
@@ -121,16 +182,111 @@ func main() {
 		// Woo store management
 		woo := api.Group("/woo_stores")
 		{
-			woo.POST("", handlers.CreateWooStore(dbconn))                   // create + validate + save (publishes event)
-			woo.POST("/:id/test", handlers.TestWooStore(dbconn))            // re-validate stored creds
-			woo.POST("/:id/webhooks", handlers.RegisterWooWebhooks(dbconn)) // create webhooks on remote Woo and persist
+			woo.POST("", handlers.CreateWooStore(dbconn))                        // create + validate + save (publishes event)
+			woo.POST("/:id/test", handlers.TestWooStore(dbconn))                 // re-validate stored creds
+			woo.POST("/:id/webhooks", handlers.RegisterWooWebhooks(dbconn))      // create webhooks on remote Woo and persist
+			woo.POST("/:id/import_products", handlers.ImportWooProducts(dbconn)) // background import of the existing Woo catalog
+		}
+
+		// Products
+		products := api.Group("/products")
+		{
+			products.GET("", handlers.ListProducts(dbconn))
+			products.POST("", handlers.CreateProduct(dbconn))
+			products.GET("/export", handlers.ExportProducts(dbconn))
+			products.POST("/import/preview", handlers.PreviewProductImport(dbconn))
+			products.POST("/import", handlers.ImportProducts(dbconn))
+			products.GET("/import/:id", handlers.GetImportJob(dbconn))
+			products.GET("/:id", handlers.GetProduct(dbconn))
+			products.PUT("/:id", handlers.UpdateProduct(dbconn))
+			products.PATCH("/:id", handlers.UpdateProduct(dbconn))
+			products.DELETE("/:id", handlers.DeleteProduct(dbconn))
+			products.GET("/:id/sync_decisions", handlers.ListProductSyncDecisions(dbconn))
+			products.GET("/:id/publish_logs", handlers.ListProductPublishLogs(dbconn))
+			products.GET("/:id/channels/:channel/preview", handlers.PreviewProductChannel(dbconn))
+			products.GET("/:id/stock", handlers.GetProductStock(dbconn))
+			products.PUT("/:id/stock", handlers.SetProductStock(dbconn))
+			products.GET("/:id/movements", handlers.ListProductMovements(dbconn))
+		}
+
+		// Seller locations (warehouses, stores) and the stock held at each
+		locations := api.Group("/locations")
+		{
+			locations.GET("", handlers.ListLocations(dbconn))
+			locations.POST("", handlers.CreateLocation(dbconn))
+			locations.GET("/:id", handlers.GetLocation(dbconn))
+			locations.PATCH("/:id", handlers.UpdateLocation(dbconn))
+			locations.DELETE("/:id", handlers.DeleteLocation(dbconn))
+			locations.GET("/:id/stock", handlers.ListLocationStock(dbconn))
+		}
+
+		// Stock transfers between locations
+		transfers := api.Group("/stock_transfers")
+		{
+			transfers.GET("", handlers.ListStockTransfers(dbconn))
+			transfers.POST("", handlers.CreateStockTransfer(dbconn))
+			transfers.GET("/:id", handlers.GetStockTransfer(dbconn))
+			transfers.POST("/:id/dispatch", handlers.DispatchStockTransfer(dbconn))
+			transfers.POST("/:id/receive", handlers.ReceiveStockTransfer(dbconn))
+			transfers.POST("/:id/cancel", handlers.CancelStockTransfer(dbconn))
+		}
+
+		// Stock ledger, its reconciliation against on-hand stock, and manual adjustments
+		// (applied at once or after an admin's approval)
+		inventory := api.Group("/inventory")
+		{
+			inventory.GET("/movements", handlers.ListMovements(dbconn))
+			inventory.GET("/reconciliation", handlers.GetStockReconciliation(dbconn))
+			inventory.POST("/reconciliation/repair", handlers.RepairStockReconciliation(dbconn))
+			inventory.GET("/adjustments", handlers.ListStockAdjustments(dbconn))
+			inventory.POST("/adjustments", handlers.CreateStockAdjustment(dbconn))
+			inventory.GET("/adjustments/:id", handlers.GetStockAdjustment(dbconn))
+			inventory.POST("/adjustments/:id/approve", handlers.ApproveStockAdjustment(dbconn))
+			inventory.POST("/adjustments/:id/reject", handlers.RejectStockAdjustment(dbconn))
+		}
+
+		// Stocktakes: counts of a location or category, posted as adjustments on approval
+		stocktakes := api.Group("/stocktakes")
+		{
+			stocktakes.GET("", handlers.ListStocktakes(dbconn))
+			stocktakes.POST("", handlers.CreateStocktake(dbconn))
+			stocktakes.GET("/:id", handlers.GetStocktake(dbconn))
+			stocktakes.GET("/:id/counts", handlers.ListStocktakeCounts(dbconn))
+			stocktakes.POST("/:id/counts", handlers.SubmitStocktakeCounts(dbconn))
+			stocktakes.POST("/:id/approve", handlers.ApproveStocktake(dbconn))
+			stocktakes.POST("/:id/cancel", handlers.CancelStocktake(dbconn))
 		}
 
-		// add other protected routes like /products here
-		api.POST("/products", handlers.CreateProduct(dbconn))
+		// Sales channels
+		channelRoutes := api.Group("/channels")
+		{
+			channelRoutes.GET("", handlers.ListChannels(dbconn))
+			channelRoutes.PATCH("/:name", handlers.UpdateChannel(dbconn))
+		}
+		api.GET("/publish_logs/failures", handlers.ListPublishFailures(dbconn))
+
+		// Orders (all channels)
+		orders := api.Group("/orders")
+		{
+			orders.GET("", handlers.ListOrders(dbconn))
+			orders.GET("/export", handlers.ExportOrders(dbconn))
+			orders.GET("/:id", handlers.GetOrder(dbconn))
+		}
+
+		api.GET("/reports/product_sales", handlers.ProductSalesReport(dbconn))
+
+		// Orders needing review (oversold etc.)
+		api.GET("/order_flags", handlers.ListOrderFlags(dbconn))
+		api.POST("/order_flags/:id/resolve", handlers.ResolveOrderFlag(dbconn))
+
+		// Webhook inbox (inspect and replay channel deliveries)
+		api.GET("/webhook_inbox", handlers.ListWebhookInbox(dbconn))
+		api.GET("/webhook_inbox/:id", handlers.GetWebhookInbox(dbconn))
+		api.POST("/webhook_inbox/:id/replay", handlers.ReplayWebhookInbox(dbconn))
 
 		// Organization management
 		api.GET("/organization", handlers.GetOrganization(dbconn))
+		api.PATCH("/organization", handlers.UpdateOrganization(dbconn))
 		api.POST("/organization/referral_code", handlers.RegenerateReferralCode(dbconn))
 	}
 
@@ -140,6 +296,7 @@ func main() {
 	{
 		internal.POST("/woo/stores/:id/sync_categories", handlers.SyncWooCategories(dbconn))
 		internal.POST("/woo/stores/:id/register_webhooks", handlers.InternalRegisterWebhooks(dbconn))
+		internal.POST("/woo/stores/:id/import_products", handlers.InternalImportWooProducts(dbconn))
 		internal.POST("/products/:id/sync_woo", handlers.SyncProductToWooInternal(dbconn))
 	}
 
@@ -0,0 +1,171 @@
+// Command ondc-mock is a stand-in buyer app, gateway and registry for exercising the
+// ONDC seller endpoints locally. It serves /lookup with its own key and URL (point the API's
+// ONDC_REGISTRY_URL at it), sends a signed /search to the API and prints the /on_search
+// catalog that comes back.
+//
+//	go run ./cmd/ondc-mock -genkey                      # print a fresh key pair for the API's env
+//	go run ./cmd/ondc-mock -search shirt -city std:080  # search and wait for on_search
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/ondc"
+)
+
+func main() {
+	listen := flag.String("listen", ":9090", "address for /lookup and /on_search")
+	selfURL := flag.String("url", "http://localhost:9090", "our public URL, sent as bap_uri")
+	subscriberID := flag.String("id", "mock-bap.local", "our subscriber id (bap_id)")
+	uniqueKeyID := flag.String("ukid", "mock-key-1", "our unique key id")
+	keyFlag := flag.String("key", "", "base64 ed25519 private key (generated when empty)")
+	bppURL := flag.String("bpp", "http://localhost:8080/ondc", "seller app base URL")
+	bppKey := flag.String("bpp-key", "", "seller's base64 public key; on_search signatures are checked when set")
+	search := flag.String("search", "", "item name to search for")
+	city := flag.String("city", "*", "context.city")
+	wait := flag.Duration("wait", 15*time.Second, "how long to wait for on_search")
+	genKey := flag.Bool("genkey", false, "print a new key pair and exit")
+	flag.Parse()
+
+	if *genKey {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("ONDC_SIGNING_PRIVATE_KEY=" + base64.StdEncoding.EncodeToString(priv.Seed()))
+		fmt.Println("public key: " + ondc.EncodePublicKey(pub))
+		return
+	}
+
+	var priv ed25519.PrivateKey
+	if *keyFlag != "" {
+		k, err := ondc.ParsePrivateKey(*keyFlag)
+		if err != nil {
+			log.Fatal("-key: ", err)
+		}
+		priv = k
+	} else {
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		priv = k
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	log.Printf("mock BAP %s|%s public key %s", *subscriberID, *uniqueKeyID, ondc.EncodePublicKey(pub))
+
+	var sellerKeys ondc.KeyResolver
+	if *bppKey != "" {
+		k, err := ondc.ParsePublicKey(*bppKey)
+		if err != nil {
+			log.Fatal("-bpp-key: ", err)
+		}
+		sellerKeys = keyOf(k)
+	}
+
+	got := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+
+	// registry: answers for our own key only
+	mux.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) {
+		var q struct {
+			SubscriberID string `json:"subscriber_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+		entries := []map[string]string{}
+		if q.SubscriberID == *subscriberID {
+			entries = append(entries, map[string]string{
+				"subscriber_id":      *subscriberID,
+				"subscriber_url":     *selfURL,
+				"ukId":               *uniqueKeyID,
+				"signing_public_key": ondc.EncodePublicKey(pub),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	})
+
+	mux.HandleFunc("/on_search", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if sellerKeys != nil {
+			if _, err := ondc.Verify(r.Header.Get("Authorization"), body, sellerKeys, time.Now()); err != nil {
+				log.Printf("on_search signature rejected: %v", err)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(ondc.Nack(ondc.ErrorTypePolicy, "10001", err.Error()))
+				return
+			}
+		}
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, body, "", "  "); err != nil {
+			pretty.Write(body)
+		}
+		fmt.Println(pretty.String())
+		json.NewEncoder(w).Encode(ondc.Ack())
+		select {
+		case got <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		log.Fatal(http.ListenAndServe(*listen, mux))
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	req := ondc.SearchRequest{
+		Context: ondc.Context{
+			Domain:        "ONDC:RET10",
+			Country:       "IND",
+			City:          *city,
+			Action:        ondc.ActionSearch,
+			CoreVersion:   "1.2.0",
+			BapID:         *subscriberID,
+			BapURI:        *selfURL,
+			TransactionID: "txn-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+			MessageID:     "msg-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+			Timestamp:     time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+			TTL:           "PT30S",
+		},
+	}
+	if *search != "" {
+		req.Message.Intent.Item = &struct {
+			Descriptor *ondc.Descriptor `json:"descriptor,omitempty"`
+		}{Descriptor: &ondc.Descriptor{Name: *search}}
+	}
+
+	cfg := &ondc.Config{
+		SubscriberID: *subscriberID,
+		UniqueKeyID:  *uniqueKeyID,
+		PrivateKey:   priv,
+		SignatureTTL: 5 * time.Minute,
+	}
+	if err := cfg.Callback(*bppURL, ondc.ActionSearch, req); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("search %s ACKed, waiting for on_search", req.Context.MessageID)
+
+	select {
+	case <-got:
+	case <-time.After(*wait):
+		log.Fatal("no on_search received")
+	}
+}
+
+// keyOf resolves every subscriber to the one key given on the command line.
+type keyOf ed25519.PublicKey
+
+func (k keyOf) PublicKey(subscriberID, uniqueKeyID string) (ed25519.PublicKey, error) {
+	return ed25519.PublicKey(k), nil
+}
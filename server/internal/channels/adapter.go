@@ -0,0 +1,93 @@
+// Package channels holds the sales channel integrations behind a common Adapter interface.
+// Adapters are registered by models.Channel.Name ("woocommerce", "ondc", ...) so callers can
+// dispatch on a channel row without knowing which marketplace it is.
+package channels
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Adapter is implemented once per sales channel.
+type Adapter interface {
+	// Name is the models.Channel.Name the adapter serves.
+	Name() string
+
+	// TestConnection checks the organization's stored credentials against the channel.
+	TestConnection(orgID uint) error
+
+	// PublishProduct creates or updates the product on the channel.
+	PublishProduct(productID uint) error
+
+	// UpdateStock pushes the product's current stock to the channel.
+	UpdateStock(productID uint) error
+
+	// FetchOrders returns raw channel orders modified since the given time.
+	FetchOrders(orgID uint, since time.Time) ([]map[string]interface{}, error)
+
+	// ParseWebhook authenticates an inbound webhook delivery and decodes it.
+	ParseWebhook(r *http.Request, body []byte) (*Webhook, error)
+
+	// MapCategories pulls the channel's categories and maps them onto local categories.
+	MapCategories(orgID uint) (CategorySyncResult, error)
+}
+
+// Webhook is an authenticated inbound delivery.
+type Webhook struct {
+	Channel        string
+	OrganizationID uint
+	Topic          string
+	Payload        interface{} // decoded JSON, or the raw body as a string when it isn't JSON
+	// Source identifies the registration that delivered it (e.g. the WooStoreWebhook row).
+	Source map[string]interface{}
+}
+
+// CategorySyncResult counts the outcome of MapCategories.
+type CategorySyncResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+var (
+	// ErrWebhookNotRegistered means the delivery can't be matched to a known webhook (and its secret).
+	ErrWebhookNotRegistered = errors.New("webhook not registered")
+	// ErrWebhookSignature means the signature is missing or doesn't match the body.
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	// ErrNotConnected means the organization has no usable credentials for the channel.
+	ErrNotConnected = errors.New("channel not connected")
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Adapter{}
+)
+
+// Register makes an adapter available under its Name, replacing any previous one.
+func Register(a Adapter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[a.Name()] = a
+}
+
+// Get returns the adapter for a channel name.
+func Get(name string) (Adapter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	a, ok := registry[name]
+	return a, ok
+}
+
+// Names lists the registered channel names, sorted.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,295 @@
+package channels
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/crypto"
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// WooName is the models.Channel.Name of the WooCommerce channel.
+const WooName = "woocommerce"
+
+// WooAdapter is the WooCommerce Adapter. Product publishing lives in services (it needs the
+// channel view and publish logs), so it is passed in rather than imported.
+type WooAdapter struct {
+	db      *gorm.DB
+	publish func(productID uint) error
+}
+
+// NewWooAdapter builds the Woo adapter; publish is normally ProductService.SyncProductToWoo.
+func NewWooAdapter(db *gorm.DB, publish func(productID uint) error) *WooAdapter {
+	return &WooAdapter{db: db, publish: publish}
+}
+
+func (a *WooAdapter) Name() string { return WooName }
+
+// TestConnection validates the credentials of the org's active store.
+func (a *WooAdapter) TestConnection(orgID uint) error {
+	client, _, err := a.clientForOrg(orgID)
+	if err != nil {
+		return err
+	}
+	return client.Test()
+}
+
+// PublishProduct runs the full product (and variation) sync.
+func (a *WooAdapter) PublishProduct(productID uint) error {
+	return a.publish(productID)
+}
+
+// UpdateStock uses the full sync too: it is idempotent, already carries stock for the
+// product and its variations, and records a publish log.
+func (a *WooAdapter) UpdateStock(productID uint) error {
+	return a.publish(productID)
+}
+
+// FetchOrders pages through /orders modified after since.
+func (a *WooAdapter) FetchOrders(orgID uint, since time.Time) ([]map[string]interface{}, error) {
+	client, _, err := a.clientForOrg(orgID)
+	if err != nil {
+		return nil, err
+	}
+	const perPage = 100
+	var all []map[string]interface{}
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("per_page", strconv.Itoa(perPage))
+		q.Set("page", strconv.Itoa(page))
+		q.Set("orderby", "modified")
+		q.Set("order", "asc")
+		q.Set("modified_after", since.UTC().Format("2006-01-02T15:04:05"))
+		q.Set("dates_are_gmt", "true")
+
+		var batch []map[string]interface{}
+		if _, err := client.GetJSON("/orders", q, &batch); err != nil {
+			return nil, fmt.Errorf("failed to fetch orders page %d: %w", page, err)
+		}
+		all = append(all, batch...)
+		if len(batch) < perPage {
+			return all, nil
+		}
+	}
+}
+
+// ParseWebhook finds the WooStoreWebhook a delivery belongs to (by X-WC-Webhook-ID, else by
+// delivery URL) and verifies X-WC-Webhook-Signature: base64 HMAC-SHA256 of the raw body.
+func (a *WooAdapter) ParseWebhook(r *http.Request, body []byte) (*Webhook, error) {
+	webhookIDHeader := r.Header.Get("X-WC-Webhook-ID")
+
+	ws, err := a.findStoreWebhook(r, webhookIDHeader)
+	if err != nil {
+		return nil, err
+	}
+	if ws == nil {
+		log.Printf("woo webhook receiver: webhook record not found (webhook_id=%q path=%s)", webhookIDHeader, r.URL.Path)
+		return nil, ErrWebhookNotRegistered
+	}
+
+	appKey := []byte(os.Getenv("APP_SECRET_KEY"))
+	if len(appKey) == 0 {
+		return nil, errors.New("APP_SECRET_KEY not set")
+	}
+	secret, err := crypto.Decrypt(ws.SecretEncrypted, appKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt webhook secret: %w", err)
+	}
+
+	signature := r.Header.Get("X-WC-Webhook-Signature")
+	if signature == "" {
+		log.Println("woo webhook receiver: missing X-WC-Webhook-Signature header")
+		return nil, ErrWebhookSignature
+	}
+	if !verifyWooSignature(secret, body, signature) {
+		log.Println("woo webhook receiver: signature verification failed")
+		return nil, ErrWebhookSignature
+	}
+
+	wh := &Webhook{
+		Channel: WooName,
+		// Some installs set topic header
+		Topic: r.Header.Get("X-WC-Webhook-Topic"),
+		Source: map[string]interface{}{
+			"id":           ws.ID,
+			"webhook_id":   ws.WebhookID,
+			"topic":        ws.Topic,
+			"delivery_url": ws.DeliveryURL,
+		},
+	}
+	var store models.WooStore
+	if err := a.db.Select("id", "organization_id").First(&store, ws.WooStoreID).Error; err == nil {
+		wh.OrganizationID = store.OrganizationID
+	}
+
+	// Parse JSON to make sure it's valid (fall back to the raw body)
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &wh.Payload); err != nil {
+			log.Println("woo webhook receiver: warning: payload is not valid JSON:", err)
+			wh.Payload = string(body)
+		}
+	}
+	return wh, nil
+}
+
+// findStoreWebhook looks the delivery up by webhook id, then by the public request URL
+// (exact, then ignoring the scheme since proxies may terminate TLS). Returns nil when not found.
+func (a *WooAdapter) findStoreWebhook(r *http.Request, webhookID string) (*models.WooStoreWebhook, error) {
+	var ws models.WooStoreWebhook
+	if webhookID != "" {
+		err := a.db.Where("webhook_id = ?", webhookID).First(&ws).Error
+		if err == nil {
+			return &ws, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("lookup by webhook_id: %w", err)
+		}
+	}
+
+	reqURL := requestPublicURL(r)
+	err := a.db.Where("delivery_url = ?", reqURL).First(&ws).Error
+	if err == nil {
+		return &ws, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("lookup by delivery_url: %w", err)
+	}
+
+	reqNoScheme := reqURL
+	if idx := strings.Index(reqURL, "://"); idx >= 0 {
+		reqNoScheme = reqURL[idx+3:]
+	}
+	// loose, but the signature is still verified against the matched secret
+	if err := a.db.Where("delivery_url LIKE ?", "%://"+reqNoScheme).First(&ws).Error; err == nil {
+		return &ws, nil
+	}
+	return nil, nil
+}
+
+// MapCategories imports the org's Woo categories as local categories with woocommerce mappings.
+func (a *WooAdapter) MapCategories(orgID uint) (CategorySyncResult, error) {
+	client, store, err := a.clientForOrg(orgID)
+	if err != nil {
+		return CategorySyncResult{}, err
+	}
+	cats, err := client.ListCategories()
+	if err != nil {
+		return CategorySyncResult{}, err
+	}
+	res, err := ImportWooCategories(a.db, cats)
+	if err != nil {
+		return res, err
+	}
+	now := time.Now()
+	a.db.Model(&store).Update("last_synced_at", &now)
+	return res, nil
+}
+
+// clientForOrg returns a client for the org's active store.
+func (a *WooAdapter) clientForOrg(orgID uint) (*WooClient, models.WooStore, error) {
+	store, err := ActiveWooStore(a.db, orgID)
+	if err != nil {
+		return nil, store, err
+	}
+	client, err := NewWooClientForStore(store)
+	return client, store, err
+}
+
+// ActiveWooStore returns the org's active store, or ErrNotConnected when there is none.
+func ActiveWooStore(db *gorm.DB, orgID uint) (models.WooStore, error) {
+	var store models.WooStore
+	err := db.Where("organization_id = ? AND is_active = ?", orgID, true).First(&store).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return store, fmt.Errorf("no active woocommerce store found: %w", ErrNotConnected)
+	}
+	return store, err
+}
+
+// ImportWooCategories finds or creates a local Category (case-insensitive name) for each Woo
+// category and adds the woocommerce CategoryMapping when missing.
+func ImportWooCategories(db *gorm.DB, wooCats []WooCategory) (CategorySyncResult, error) {
+	var res CategorySyncResult
+	for _, wc := range wooCats {
+		name := strings.TrimSpace(wc.Name)
+		if name == "" {
+			res.Skipped++
+			continue
+		}
+		var cat models.Category
+		err := db.Where("LOWER(name) = LOWER(?)", name).First(&cat).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			cat = models.Category{
+				Name:        name,
+				Description: wc.Description,
+			}
+			if err := db.Create(&cat).Error; err != nil {
+				res.Skipped++
+				continue
+			}
+		} else if err != nil {
+			return res, err
+		}
+
+		channelCategoryID := strconv.Itoa(wc.ID)
+		var mapping models.CategoryMapping
+		err = db.Where("category_id = ? AND channel = ? AND channel_category_id = ?", cat.ID, WooName, channelCategoryID).First(&mapping).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			mapping = models.CategoryMapping{
+				CategoryID:        cat.ID,
+				Channel:           WooName,
+				ChannelCategoryID: channelCategoryID,
+			}
+			if err := db.Create(&mapping).Error; err != nil {
+				// duplicate constraint or transient error; keep going
+				res.Skipped++
+				continue
+			}
+			res.Imported++
+		case err != nil:
+			return res, err
+		default:
+			res.Skipped++
+		}
+	}
+	return res, nil
+}
+
+// verifyWooSignature checks Woo's signature header: base64(hmac_sha256(body, secret)).
+func verifyWooSignature(secret string, body []byte, signatureHeader string) bool {
+	decodedHeader, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signatureHeader))
+	if err != nil {
+		log.Println("woo webhook receiver: signature base64 decode failed:", err)
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), decodedHeader)
+}
+
+// requestPublicURL reconstructs the public delivery URL used for DB matching,
+// e.g. https://abcd.ngrok.app/webhooks/woo
+func requestPublicURL(r *http.Request) string {
+	// prefer X-Forwarded-Proto (ngrok and proxies set it)
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if proto == "" {
+		if r.TLS != nil {
+			proto = "https"
+		} else {
+			proto = "http"
+		}
+	}
+	return proto + "://" + r.Host + r.URL.Path
+}
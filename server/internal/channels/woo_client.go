@@ -0,0 +1,223 @@
+package channels
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/crypto"
+	"github.com/RvShivam/inventify/internal/models"
+)
+
+// WooClient talks to one store's WooCommerce REST API (wc/v3).
+type WooClient struct {
+	SiteURL string
+	HTTP    *http.Client
+
+	consumerKey    string
+	consumerSecret string
+}
+
+// NewWooClient builds a client from plaintext credentials.
+func NewWooClient(siteURL, consumerKey, consumerSecret string, verifySSL bool) *WooClient {
+	return &WooClient{
+		SiteURL:        strings.TrimRight(siteURL, "/"),
+		HTTP:           wooHTTPClient(verifySSL),
+		consumerKey:    consumerKey,
+		consumerSecret: consumerSecret,
+	}
+}
+
+// NewWooClientForStore decrypts the store's stored credentials with APP_SECRET_KEY.
+func NewWooClientForStore(store models.WooStore) (*WooClient, error) {
+	appKey := []byte(os.Getenv("APP_SECRET_KEY"))
+	ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt consumer key: %w", err)
+	}
+	cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt consumer secret: %w", err)
+	}
+	return NewWooClient(store.SiteURL, ck, cs, store.VerifySSL), nil
+}
+
+// wooHTTPClient builds the HTTP client used for Woo REST calls.
+func wooHTTPClient(verifySSL bool) *http.Client {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if !verifySSL {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return client
+}
+
+// URL returns the absolute URL of a wc/v3 endpoint, e.g. "/products/12".
+func (c *WooClient) URL(endpoint string, q url.Values) string {
+	u := c.SiteURL + "/wp-json/wc/v3" + endpoint
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+// Do sends a JSON request (payload may be nil) and returns the body of a 2xx response.
+// Non-2xx responses come back as *WooAPIError.
+func (c *WooClient) Do(method, endpoint string, payload interface{}) ([]byte, error) {
+	body, _, err := c.do(method, c.URL(endpoint, nil), payload)
+	return body, err
+}
+
+// GetJSON decodes a GET response into out and returns the response headers
+// (Woo reports paging in X-WP-Total / X-WP-TotalPages).
+func (c *WooClient) GetJSON(endpoint string, q url.Values, out interface{}) (http.Header, error) {
+	body, header, err := c.do("GET", c.URL(endpoint, q), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, fmt.Errorf("failed to parse woo response: %w", err)
+	}
+	return header, nil
+}
+
+func (c *WooClient) do(method, urlStr string, payload interface{}) ([]byte, http.Header, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonBody, _ := json.Marshal(payload)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+	req, err := http.NewRequest(method, urlStr, reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.consumerKey, c.consumerSecret)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, resp.Header, &WooAPIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+	return bodyBytes, resp.Header, nil
+}
+
+// WooAPIError is a non-2xx response from the Woo REST API.
+type WooAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *WooAPIError) Error() string {
+	return fmt.Sprintf("woo api error (%d): %s", e.StatusCode, e.Body)
+}
+
+// IsPermanent reports whether retrying the same request cannot help:
+// any 4xx except 408 (timeout) and 429 (rate limited).
+func (e *WooAPIError) IsPermanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+// Test tries a read (products) to validate the credentials.
+func (c *WooClient) Test() error {
+	_, err := c.Do("GET", "/products?per_page=1", nil)
+	var apiErr *WooAPIError
+	if errors.As(err, &apiErr) {
+		// 401/403 => likely bad creds or insufficient perms
+		if apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("authentication failed (%d): %s", apiErr.StatusCode, apiErr.Body)
+		}
+		return fmt.Errorf("unexpected status: %d", apiErr.StatusCode)
+	}
+	return err
+}
+
+// CreateWebhook creates a webhook and returns its Woo id.
+func (c *WooClient) CreateWebhook(deliveryURL, topic, secret string) (string, error) {
+	payload := map[string]interface{}{
+		"name":         "inventify-" + topic,
+		"topic":        topic,
+		"delivery_url": deliveryURL,
+		"secret":       secret,
+		"status":       "active",
+	}
+	body, err := c.Do("POST", "/webhooks", payload)
+	if err != nil {
+		var apiErr *WooAPIError
+		if errors.As(err, &apiErr) {
+			return "", fmt.Errorf("webhook create returned %d: %s", apiErr.StatusCode, apiErr.Body)
+		}
+		return "", err
+	}
+	var respJSON map[string]interface{}
+	if err := json.Unmarshal(body, &respJSON); err != nil {
+		return "", err
+	}
+	// woo returns id field (number or string); normalize to string
+	if id, ok := respJSON["id"]; ok {
+		return fmt.Sprintf("%v", id), nil
+	}
+	return "", errors.New("no id in webhook response")
+}
+
+// FindWebhook returns the id of the webhook with this topic and delivery URL, or "" when none exists.
+func (c *WooClient) FindWebhook(topic, deliveryURL string) (string, error) {
+	var webhooks []map[string]interface{}
+	if _, err := c.GetJSON("/webhooks", url.Values{"per_page": {"100"}}, &webhooks); err != nil {
+		return "", fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, wh := range webhooks {
+		t, _ := wh["topic"].(string)
+		d, _ := wh["delivery_url"].(string)
+		if t == topic && d == deliveryURL {
+			if id, ok := wh["id"]; ok {
+				return fmt.Sprintf("%v", id), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// DeleteWebhook permanently deletes a webhook.
+func (c *WooClient) DeleteWebhook(webhookID string) error {
+	if _, err := c.Do("DELETE", "/webhooks/"+url.PathEscape(webhookID)+"?force=true", nil); err != nil {
+		return fmt.Errorf("failed to delete webhook %s: %w", webhookID, err)
+	}
+	return nil
+}
+
+// WooCategory matches the Woo REST response for product categories.
+type WooCategory struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Parent      int    `json:"parent"`
+	Count       int    `json:"count"`
+}
+
+// ListCategories retrieves the store's product categories (first 100).
+func (c *WooClient) ListCategories() ([]WooCategory, error) {
+	var out []WooCategory
+	if _, err := c.GetJSON("/products/categories", url.Values{"per_page": {"100"}}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
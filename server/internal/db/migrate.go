@@ -14,13 +14,23 @@ func RunPostgresMigrations(db *gorm.DB) error {
 		return err
 	}
 
+	// Enable pg_trgm for product search (ILIKE + similarity ranking)
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "pg_trgm";`).Error; err != nil {
+		return err
+	}
+
 	migrations := []string{
 		// ───────────────────────────────────────────
-		// SKU uniqueness per organization
+		// SKU uniqueness per organization (live products with a SKU only,
+		// so a deleted product's SKU can be used again and SKU-less products don't collide)
 		// ───────────────────────────────────────────
-		`CREATE UNIQUE INDEX IF NOT EXISTS ux_product_org_sku
+		`DROP INDEX IF EXISTS ux_product_org_sku;`,
+
+		`DROP INDEX IF EXISTS ux_product_org_sku_live;`,
+
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_product_org_live_sku
 		 ON products (organization_id, sku)
-		 WHERE sku IS NOT NULL;`,
+		 WHERE sku IS NOT NULL AND sku <> '' AND deleted_at IS NULL;`,
 
 		// ───────────────────────────────────────────
 		// ProductChannel uniqueness (replace CONSTRAINT with INDEX)
@@ -34,6 +44,13 @@ func RunPostgresMigrations(db *gorm.DB) error {
 		`CREATE UNIQUE INDEX IF NOT EXISTS ux_category_channel
 		 ON category_mappings (category_id, channel);`,
 
+		// ───────────────────────────────────────────
+		// One live override per product & channel
+		// ───────────────────────────────────────────
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_product_channel_override
+		 ON product_channel_overrides (product_id, channel)
+		 WHERE deleted_at IS NULL;`,
+
 		// ───────────────────────────────────────────
 		// Non-negative prices & stock (keep CHECK constraints)
 		// ───────────────────────────────────────────
@@ -70,11 +87,51 @@ func RunPostgresMigrations(db *gorm.DB) error {
 		EXCEPTION WHEN duplicate_object THEN
 		END$$;`,
 
+		// ───────────────────────────────────────────
+		// Product variants: SKU per parent, non-negative prices & stock
+		// ───────────────────────────────────────────
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_variant_product_sku
+		 ON product_variants (product_id, sku)
+		 WHERE sku IS NOT NULL AND sku <> '' AND deleted_at IS NULL;`,
+
+		`DO $$
+		BEGIN
+		  IF NOT EXISTS (
+		    SELECT 1 FROM pg_constraint WHERE conname = 'chk_variant_prices_nonneg'
+		  ) THEN
+		    ALTER TABLE product_variants
+		      ADD CONSTRAINT chk_variant_prices_nonneg
+		      CHECK (regular_price >= 0 AND (sale_price IS NULL OR sale_price >= 0));
+		  END IF;
+		EXCEPTION WHEN duplicate_object THEN
+		END$$;`,
+
+		`DO $$
+		BEGIN
+		  IF NOT EXISTS (
+		    SELECT 1 FROM pg_constraint WHERE conname = 'chk_variant_stock_qty_nonneg'
+		  ) THEN
+		    ALTER TABLE product_variants
+		      ADD CONSTRAINT chk_variant_stock_qty_nonneg CHECK (stock_quantity >= 0);
+		  END IF;
+		EXCEPTION WHEN duplicate_object THEN
+		END$$;`,
+
 		// ───────────────────────────────────────────
 		// Inventory reservation idempotency + safety
 		// ───────────────────────────────────────────
-		`CREATE UNIQUE INDEX IF NOT EXISTS ux_reservation_product_context
-		 ON inventory_reservations (product_id, context_id);`,
+		// one hold row per stock line (product or variant) per source & context; a new hold
+		// for the same line reuses the row, whatever its status
+		`DROP INDEX IF EXISTS ux_reservation_product_context;`,
+
+		`DROP INDEX IF EXISTS ux_reservation_line_context;`,
+
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_reservation_source_line_context
+		 ON inventory_reservations (source, product_id, COALESCE(variant_id, 0), context_id);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_reservation_live
+		 ON inventory_reservations (expires_at)
+		 WHERE status = 'reserved';`,
 
 		`DO $$
 		BEGIN
@@ -87,6 +144,70 @@ func RunPostgresMigrations(db *gorm.DB) error {
 		EXCEPTION WHEN duplicate_object THEN
 		END$$;`,
 
+		// one default location per org
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_seller_location_default
+		 ON seller_locations (organization_id)
+		 WHERE is_default AND deleted_at IS NULL;`,
+
+		// one stock row per product/variant & location
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_product_location_stock
+		 ON product_location_stocks (product_id, COALESCE(variant_id, 0), location_id)
+		 WHERE deleted_at IS NULL;`,
+
+		`DO $$
+		BEGIN
+		  IF NOT EXISTS (
+		    SELECT 1 FROM pg_constraint WHERE conname = 'chk_location_stock_nonneg'
+		  ) THEN
+		    ALTER TABLE product_location_stocks
+		      ADD CONSTRAINT chk_location_stock_nonneg CHECK (stock_qty >= 0);
+		  END IF;
+		EXCEPTION WHEN duplicate_object THEN
+		END$$;`,
+
+		// one order per channel order id, so concurrent webhook deliveries can't double-deduct.
+		// Duplicates stored before the index existed are folded into the oldest copy first:
+		// the extras are soft-deleted and the kept order is flagged for review, since each
+		// copy took its own stock.
+		`WITH ranked AS (
+		   SELECT id, organization_id,
+		          FIRST_VALUE(id) OVER w AS keep_id,
+		          ROW_NUMBER() OVER w AS rn
+		   FROM orders
+		   WHERE deleted_at IS NULL AND external_id IS NOT NULL
+		   WINDOW w AS (PARTITION BY organization_id, source, external_id ORDER BY created_at, id)
+		 ), dropped AS (
+		   UPDATE orders SET deleted_at = NOW()
+		   FROM ranked
+		   WHERE orders.id = ranked.id AND ranked.rn > 1
+		   RETURNING ranked.organization_id, ranked.keep_id, orders.id
+		 )
+		 INSERT INTO order_flags (created_at, updated_at, organization_id, order_id, reason, details, owed_qty, status)
+		 SELECT NOW(), NOW(), organization_id, keep_id, 'duplicate_order',
+		        jsonb_build_object('duplicate_order_ids', jsonb_agg(id)), 0, 'open'
+		 FROM dropped
+		 GROUP BY organization_id, keep_id;`,
+
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_order_source_external
+		 ON orders (organization_id, source, external_id)
+		 WHERE deleted_at IS NULL;`,
+
+		// order list: newest first per org
+		`CREATE INDEX IF NOT EXISTS idx_order_org_created
+		 ON orders (organization_id, created_at DESC);`,
+
+		// sales per product (reports)
+		`CREATE INDEX IF NOT EXISTS idx_order_item_org_product
+		 ON order_items (organization_id, product_id);`,
+
+		// an order's stock movements, summed to know what it still holds
+		`CREATE INDEX IF NOT EXISTS idx_movement_ref
+		 ON inventory_movements (ref);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_order_flags_open
+		 ON order_flags (organization_id, created_at DESC)
+		 WHERE status = 'open' AND deleted_at IS NULL;`,
+
 		// ───────────────────────────────────────────
 		// Helpful indexes
 		// ───────────────────────────────────────────
@@ -95,6 +216,40 @@ func RunPostgresMigrations(db *gorm.DB) error {
 
 		`CREATE INDEX IF NOT EXISTS idx_publish_product_channel
 		 ON channel_publish_logs (product_id, channel);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_publish_failures
+		 ON channel_publish_logs (channel, attempt_at DESC)
+		 WHERE success = FALSE;`,
+
+		// ───────────────────────────────────────────
+		// Product search (trigram) + list filters
+		// ───────────────────────────────────────────
+		`CREATE INDEX IF NOT EXISTS idx_product_name_trgm
+		 ON products USING gin (name gin_trgm_ops);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_sku_trgm
+		 ON products USING gin (sku gin_trgm_ops);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_brand_trgm
+		 ON products USING gin (brand gin_trgm_ops);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_hsn_trgm
+		 ON products USING gin (hsn_code gin_trgm_ops);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_org_effective_price
+		 ON products (organization_id, (COALESCE(sale_price, regular_price)));`,
+
+		`CREATE INDEX IF NOT EXISTS idx_product_org_stock
+		 ON products (organization_id, stock_quantity);`,
+
+		// ───────────────────────────────────────────
+		// Webhook inbox: one row per channel delivery
+		// ───────────────────────────────────────────
+		`CREATE UNIQUE INDEX IF NOT EXISTS ux_webhook_inbox_delivery
+		 ON webhook_inboxes (channel, delivery_id, payload_hash);`,
+
+		`CREATE INDEX IF NOT EXISTS idx_webhook_inbox_org_status
+		 ON webhook_inboxes (organization_id, status, id DESC);`,
 	}
 
 	for _, sql := range migrations {
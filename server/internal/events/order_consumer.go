@@ -2,9 +2,10 @@ package events
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"time"
 
-	"github.com/RvShivam/inventify/internal/models"
 	"github.com/RvShivam/inventify/internal/services"
 	"gorm.io/gorm"
 )
@@ -27,47 +28,34 @@ func StartOrderConsumer(db *gorm.DB) {
 			return nil
 		}
 
-		payload, ok := event["payload"].(map[string]interface{})
-		if !ok {
-			log.Printf("❌ Invalid payload format")
-			return nil
-		}
+		return processInboxed(db, event, func() error {
+			payload, ok := event["payload"].(map[string]interface{})
+			if !ok {
+				return permanent(errors.New("invalid payload format"))
+			}
 
-		// Extract woo_store_webhook info to find OrganizationID
-		wooWebhookInfo, ok := event["woo_store_webhook"].(map[string]interface{})
-		if !ok {
-			log.Printf("❌ Missing woo_store_webhook info")
-			return nil
-		}
+			// The event has woo_store_webhook.id (which is the DB ID of WooStoreWebhook).
+			// From WooStoreWebhook we get WooStoreID, and from WooStore the OrganizationID.
+			wooStore, err := wooStoreForEvent(db, event)
+			if err != nil {
+				return permanent(err)
+			}
 
-		// We need to find the OrganizationID.
-		// The event has woo_store_webhook.id (which is the DB ID of WooStoreWebhook).
-		// From WooStoreWebhook we can get WooStoreID, and from WooStore we get OrganizationID.
+			log.Printf("📦 Processing Order Webhook: %s (OrgID: %d)", topic, wooStore.OrganizationID)
 
-		webhookDBIDFloat, _ := wooWebhookInfo["id"].(float64)
-		webhookDBID := uint(webhookDBIDFloat)
+			changes, err := orderService.CreateOrUpdateOrderFromWoo(wooStore.OrganizationID, payload)
+			if errors.Is(err, services.ErrIllegalTransition) {
+				return permanent(err) // a retry would be rejected the same way
+			}
+			if err != nil {
+				log.Printf("❌ Error processing order: %v", err)
+				return err // Return error to Nack/Retry
+			}
+			PublishStockChanged(wooStore.OrganizationID, "woocommerce", changes)
 
-		var wsWebhook models.WooStoreWebhook
-		if err := db.First(&wsWebhook, webhookDBID).Error; err != nil {
-			log.Printf("❌ Could not find WooStoreWebhook %d: %v", webhookDBID, err)
-			return nil // Maybe retry?
-		}
-
-		var wooStore models.WooStore
-		if err := db.First(&wooStore, wsWebhook.WooStoreID).Error; err != nil {
-			log.Printf("❌ Could not find WooStore %d: %v", wsWebhook.WooStoreID, err)
+			log.Println("✅ Order processed successfully")
 			return nil
-		}
-
-		log.Printf("📦 Processing Order Webhook: %s (OrgID: %d)", topic, wooStore.OrganizationID)
-
-		if err := orderService.CreateOrUpdateOrderFromWoo(wooStore.OrganizationID, payload); err != nil {
-			log.Printf("❌ Error processing order: %v", err)
-			return err // Return error to Nack/Retry
-		}
-
-		log.Println("✅ Order processed successfully")
-		return nil
+		})
 	}
 
 	// Register consumer
@@ -80,3 +68,27 @@ func StartOrderConsumer(db *gorm.DB) {
 		log.Println("🎧 Order Consumer registered for 'woo.webhook.received'")
 	}
 }
+
+// PublishStockChanged announces one product.stock_changed per affected product.
+func PublishStockChanged(orgID uint, source string, changes []services.StockChange) {
+	seen := make(map[uint]bool, len(changes))
+	for _, ch := range changes {
+		if seen[ch.ProductID] {
+			continue
+		}
+		seen[ch.ProductID] = true
+		ev := ProductStockChangedEvent{
+			BaseEvent: BaseEvent{
+				Event:     RoutingKeyProductStockChanged,
+				Version:   1,
+				Timestamp: time.Now().UTC(),
+			},
+			ProductID:      ch.ProductID,
+			OrganizationID: orgID,
+			Source:         source,
+		}
+		if err := Publish(RoutingKeyProductStockChanged, ev); err != nil {
+			log.Printf("Failed to publish %s event: %v", RoutingKeyProductStockChanged, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+	"gorm.io/gorm"
+)
+
+// StartWooProductConsumer applies product changes made directly in wp-admin
+// (product.updated / product.deleted webhooks) to the linked local products.
+func StartWooProductConsumer(db *gorm.DB) {
+	productService := services.NewProductService(db)
+
+	handler := func(body []byte) error {
+		var event map[string]interface{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			log.Printf("❌ Error decoding event: %v", err)
+			return nil // Return nil to Ack (poison message)
+		}
+
+		topic, _ := event["topic"].(string)
+		if topic != "product.updated" && topic != "product.deleted" {
+			// Orders are handled by StartOrderConsumer
+			return nil
+		}
+
+		return processInboxed(db, event, func() error {
+			payload, ok := event["payload"].(map[string]interface{})
+			if !ok {
+				return permanent(errors.New("invalid payload format"))
+			}
+
+			wooStore, err := wooStoreForEvent(db, event)
+			if err != nil {
+				return permanent(err)
+			}
+
+			var decisions []*models.ProductSyncDecision
+			if topic == "product.deleted" {
+				var decision *models.ProductSyncDecision
+				decision, err = productService.ApplyWooProductDelete(wooStore.OrganizationID, payload)
+				if decision != nil {
+					decisions = append(decisions, decision)
+				}
+			} else {
+				decisions, err = productService.ApplyWooProductUpdate(wooStore.OrganizationID, payload)
+			}
+			if err != nil {
+				log.Printf("❌ Error applying Woo %s: %v", topic, err)
+				return err // Return error to Nack/Retry
+			}
+			if len(decisions) == 0 {
+				// not linked, or our own push echoed back
+				return nil
+			}
+
+			republish, pushStock := false, false
+			for _, decision := range decisions {
+				log.Printf("🔁 Woo %s for product %d: %s (policy %s)", topic, decision.ProductID, decision.Decision, decision.Policy)
+				if decision.Decision != services.SyncDecisionKeptLocal {
+					continue
+				}
+				if services.IsStockDecision(decision) {
+					pushStock = true
+				} else {
+					republish = true
+				}
+			}
+			productID, orgID := decisions[0].ProductID, decisions[0].OrganizationID
+
+			// Local version won: push it back so Woo converges.
+			if republish {
+				ev := ProductUpdatedEvent{
+					BaseEvent: BaseEvent{
+						Event:     RoutingKeyProductUpdated,
+						Version:   1,
+						Timestamp: time.Now().UTC(),
+					},
+					ProductID:      productID,
+					OrganizationID: orgID,
+				}
+				if err := Publish(RoutingKeyProductUpdated, ev); err != nil {
+					log.Printf("Failed to publish %s event: %v", RoutingKeyProductUpdated, err)
+				}
+			} else if pushStock {
+				// stock edited in Woo: only our stock goes back (a full push would carry it too)
+				PublishStockChanged(orgID, "woo_conflict", []services.StockChange{{ProductID: productID}})
+			}
+			return nil
+		})
+	}
+
+	// Queue: worker.products.woo
+	// Binding: woo.webhook.received
+	err := Consume("worker.products.woo", RoutingKeyWooWebhookReceived, handler)
+	if err != nil {
+		log.Printf("❌ Failed to register Woo product consumer: %v", err)
+	} else {
+		log.Println("🎧 Woo Product Consumer registered for 'woo.webhook.received'")
+	}
+}
+
+var errMissingWebhookInfo = errors.New("missing woo_store_webhook info")
+
+// wooStoreForEvent resolves the WooStore a woo.webhook.received event came from
+// via woo_store_webhook.id (the WooStoreWebhook DB ID).
+func wooStoreForEvent(db *gorm.DB, event map[string]interface{}) (models.WooStore, error) {
+	var wooStore models.WooStore
+	wooWebhookInfo, ok := event["woo_store_webhook"].(map[string]interface{})
+	if !ok {
+		return wooStore, errMissingWebhookInfo
+	}
+	webhookDBIDFloat, _ := wooWebhookInfo["id"].(float64)
+
+	var wsWebhook models.WooStoreWebhook
+	if err := db.First(&wsWebhook, uint(webhookDBIDFloat)).Error; err != nil {
+		return wooStore, err
+	}
+	if err := db.First(&wooStore, wsWebhook.WooStoreID).Error; err != nil {
+		return wooStore, err
+	}
+	return wooStore, nil
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/channels"
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	syncMaxAttempts = 5
+	syncBaseBackoff = 2 * time.Second
+	syncMaxBackoff  = 30 * time.Second
+)
+
+// channelSyncer pushes a local product to one sales channel.
+type channelSyncer func(productID uint) error
+
+// StartProductSyncConsumer pushes products to their enabled channels whenever a product is
+// created, updated or its stock changes, through the adapter registered for each channel.
+// Transient failures are retried in-process with exponential backoff; permanent ones
+// (4xx from the channel) are logged and dropped.
+func StartProductSyncConsumer(db *gorm.DB) {
+	handler := func(body []byte) error {
+		var event struct {
+			BaseEvent
+			ProductID      uint   `json:"product_id"`
+			OrganizationID uint   `json:"organization_id"`
+			Source         string `json:"source"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			log.Printf("❌ Error decoding event: %v", err)
+			return nil // Return nil to Ack (poison message)
+		}
+
+		switch event.Event {
+		case RoutingKeyProductCreated, RoutingKeyProductUpdated, RoutingKeyProductStockChanged:
+		default:
+			return nil
+		}
+		if event.ProductID == 0 {
+			return nil
+		}
+
+		names, err := enabledChannelNames(db, event.ProductID)
+		if err != nil {
+			return err // Return error to Nack/Retry
+		}
+
+		for _, name := range names {
+			if name == event.Source {
+				// the channel already has this change
+				continue
+			}
+			adapter, ok := channels.Get(name)
+			if !ok {
+				continue
+			}
+			sync := channelSyncer(adapter.PublishProduct)
+			if event.Event == RoutingKeyProductStockChanged {
+				sync = adapter.UpdateStock
+			}
+			if err := syncWithRetry(name, event.ProductID, sync); err != nil {
+				log.Printf("❌ Sync of product %d to %s failed: %v", event.ProductID, name, err)
+				continue
+			}
+			log.Printf("✅ Product %d synced to %s (%s)", event.ProductID, name, event.Event)
+		}
+		return nil
+	}
+
+	// Queue: worker.products.sync
+	// Binding: product.*
+	err := Consume("worker.products.sync", "product.*", handler)
+	if err != nil {
+		log.Printf("❌ Failed to register product sync consumer: %v", err)
+	} else {
+		log.Println("🎧 Product Sync Consumer registered for 'product.*'")
+	}
+}
+
+// enabledChannelNames lists the active channels a product is enabled on.
+func enabledChannelNames(db *gorm.DB, productID uint) ([]string, error) {
+	var names []string
+	err := db.Model(&models.ProductChannel{}).
+		Joins("JOIN channels ON channels.id = product_channels.channel_id AND channels.deleted_at IS NULL").
+		Where("product_channels.product_id = ? AND product_channels.is_enabled = ? AND channels.is_active = ?", productID, true, true).
+		Pluck("channels.name", &names).Error
+	return names, err
+}
+
+// syncWithRetry runs sync until it succeeds, fails permanently or runs out of attempts,
+// doubling the wait between attempts.
+func syncWithRetry(channel string, productID uint, sync channelSyncer) error {
+	backoff := syncBaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := sync(productID)
+		if err == nil {
+			return nil
+		}
+		if !isTransientSyncError(err) {
+			return fmt.Errorf("permanent error after %d attempt(s): %w", attempt, err)
+		}
+		if attempt == syncMaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("⚠️ Sync of product %d to %s failed (attempt %d/%d), retrying in %s: %v",
+			productID, channel, attempt, syncMaxAttempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > syncMaxBackoff {
+			backoff = syncMaxBackoff
+		}
+	}
+}
+
+// isTransientSyncError reports whether a failed sync is worth retrying:
+// network errors and non-permanent channel API errors (5xx, 408, 429).
+// Anything else (missing store, disabled channel, bad data) won't fix itself.
+func isTransientSyncError(err error) bool {
+	var apiErr *channels.WooAPIError
+	if errors.As(err, &apiErr) {
+		return !apiErr.IsPermanent()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
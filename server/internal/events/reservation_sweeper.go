@@ -0,0 +1,34 @@
+package events
+
+import (
+	"log"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/services"
+	"gorm.io/gorm"
+)
+
+// ReservationSweepInterval is how often expired stock holds are released.
+const ReservationSweepInterval = time.Minute
+
+// StartReservationSweeper expires reservations past their ExpiresAt in the background and
+// announces the freed stock, so channels republish the higher available-to-sell.
+func StartReservationSweeper(db *gorm.DB, interval time.Duration) {
+	reservations := services.NewReservationService(db)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			byOrg, err := reservations.ExpireReservations(time.Now())
+			if err != nil {
+				log.Printf("❌ Reservation sweep failed: %v", err)
+				continue
+			}
+			for orgID, changes := range byOrg {
+				log.Printf("⏳ Expired %d reservation(s) for org %d", len(changes), orgID)
+				PublishStockChanged(orgID, "reservation", changes)
+			}
+		}
+	}()
+	log.Printf("🧹 Reservation sweeper running every %s", interval)
+}
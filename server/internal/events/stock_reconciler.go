@@ -0,0 +1,40 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/services"
+	"gorm.io/gorm"
+)
+
+// StockReconcileInterval is how often stock is checked against the movement ledger.
+const StockReconcileInterval = 6 * time.Hour
+
+// StartStockReconciler compares every stock line with its ledger in the background and
+// logs the drift. With repair it also sets drifting stock back to the ledger and
+// announces the changes, so channels republish.
+func StartStockReconciler(db *gorm.DB, interval time.Duration, repair bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			drift, byOrg, err := services.ReconcileStock(db, 0, repair)
+			if err != nil {
+				log.Printf("❌ Stock reconciliation failed: %v", err)
+			}
+			for _, d := range drift {
+				line := fmt.Sprintf("product %d", d.ProductID)
+				if d.VariantID != nil {
+					line += fmt.Sprintf(" variant %d", *d.VariantID)
+				}
+				log.Printf("⚖️ Stock drift in org %d, %s: stock %d, ledger %d (repaired: %t)", d.OrganizationID, line, d.Stock, d.Ledger, d.Repaired)
+			}
+			for orgID, changes := range byOrg {
+				PublishStockChanged(orgID, "reconcile", changes)
+			}
+		}
+	}()
+	log.Printf("⚖️ Stock reconciler running every %s (repair: %t)", interval, repair)
+}
@@ -8,9 +8,12 @@ const (
 	ExchangeType = "topic"
 
 	// routing keys
-	RoutingKeyWooStoreConnected  = "woo.store.connected"
-	RoutingKeyWooWebhookReceived = "woo.webhook.received"
-	RoutingKeyProductCreated     = "product.created"
+	RoutingKeyWooStoreConnected   = "woo.store.connected"
+	RoutingKeyWooWebhookReceived  = "woo.webhook.received"
+	RoutingKeyProductCreated      = "product.created"
+	RoutingKeyProductUpdated      = "product.updated"
+	RoutingKeyProductDeleted      = "product.deleted"
+	RoutingKeyProductStockChanged = "product.stock_changed"
 	// add more routing keys as needed...
 )
 
@@ -35,3 +38,27 @@ type ProductCreatedEvent struct {
 	ProductID      uint `json:"product_id"`
 	OrganizationID uint `json:"organization_id"`
 }
+
+// ProductUpdatedEvent fired when a product or its channel settings are edited.
+type ProductUpdatedEvent struct {
+	BaseEvent
+	ProductID      uint `json:"product_id"`
+	OrganizationID uint `json:"organization_id"`
+}
+
+// ProductDeletedEvent fired when a product is soft-deleted.
+type ProductDeletedEvent struct {
+	BaseEvent
+	ProductID      uint   `json:"product_id"`
+	OrganizationID uint   `json:"organization_id"`
+	SKU            string `json:"sku"`
+}
+
+// ProductStockChangedEvent fired when stock moves outside a product edit (e.g. an order).
+// Source is the channel that caused the change, so it isn't pushed back there.
+type ProductStockChangedEvent struct {
+	BaseEvent
+	ProductID      uint   `json:"product_id"`
+	OrganizationID uint   `json:"organization_id"`
+	Source         string `json:"source"`
+}
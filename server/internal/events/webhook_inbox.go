@@ -0,0 +1,64 @@
+package events
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/services"
+	"gorm.io/gorm"
+)
+
+// permanentError marks a webhook that retrying won't fix (bad payload, unknown store).
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+func permanent(err error) error { return permanentError{err} }
+
+// webhookBusyWait is how long a consumer holds back a delivery another consumer is still
+// processing before handing it back to the queue, so it isn't redelivered in a tight loop.
+const webhookBusyWait = 5 * time.Second
+
+// processInboxed runs fn for the webhook inbox row behind a woo.webhook.received event
+// and records the outcome there. Deliveries that are already processed are acked without
+// running fn; one another consumer is still processing is requeued, to be taken over if
+// that consumer never finishes (see ClaimWebhook). A failure is requeued until the delivery has
+// had MaxWebhookAttempts; after that, or when it's permanent, it is acked and left
+// failed for someone to replay.
+func processInboxed(db *gorm.DB, event map[string]interface{}, fn func() error) error {
+	idFloat, _ := event["inbox_id"].(float64)
+	inboxID := uint(idFloat)
+	if inboxID == 0 {
+		// published before the inbox existed
+		return fn()
+	}
+
+	attempts, claimed, err := services.ClaimWebhook(db, inboxID, time.Now())
+	if errors.Is(err, services.ErrWebhookBusy) {
+		time.Sleep(webhookBusyWait)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("⏭️ Webhook inbox %d already processed, skipping", inboxID)
+		return nil
+	}
+
+	procErr := fn()
+	if err := services.FinishWebhook(db, inboxID, procErr); err != nil {
+		log.Printf("❌ Failed to record outcome of webhook inbox %d: %v", inboxID, err)
+	}
+	if procErr == nil {
+		return nil
+	}
+
+	var perm permanentError
+	if errors.As(procErr, &perm) || attempts >= services.MaxWebhookAttempts {
+		log.Printf("❌ Webhook inbox %d failed after %d attempt(s), left for replay: %v", inboxID, attempts, procErr)
+		return nil
+	}
+	return procErr
+}
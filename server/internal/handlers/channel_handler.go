@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type channelResp struct {
+	ID       uint                   `json:"id"`
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	IsActive bool                   `json:"is_active"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// updateChannelReq patches a channel. Config keys are merged into the stored config;
+// a null value removes the key.
+type updateChannelReq struct {
+	IsActive *bool                  `json:"is_active"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// ListChannels returns the organization's sales channels with their config.
+func ListChannels(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var channels []models.Channel
+		if err := db.Where("organization_id = ?", orgID).Order("id ASC").Find(&channels).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]channelResp, 0, len(channels))
+		for _, ch := range channels {
+			out = append(out, toChannelResp(ch))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// UpdateChannel updates a channel by name (e.g. PATCH /api/channels/woocommerce). Admin only.
+// For woocommerce, config.conflict_policy sets how inbound product webhooks are reconciled:
+// inventify_wins, woo_wins or newest_wins (default). config.oversell_policy sets what an
+// incoming order short of stock does: reject, backorder or clamp_and_flag (default).
+// config.location_rule sets which locations an order's stock comes from: default (default),
+// nearest or split.
+func UpdateChannel(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+
+		var req updateChannelReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if raw, ok := req.Config["conflict_policy"]; ok && raw != nil {
+			policy, _ := raw.(string)
+			if !services.IsValidConflictPolicy(policy) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "conflict_policy must be inventify_wins, woo_wins or newest_wins"})
+				return
+			}
+		}
+		if raw, ok := req.Config["oversell_policy"]; ok && raw != nil {
+			policy, _ := raw.(string)
+			if !services.IsValidOversellPolicy(policy) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "oversell_policy must be reject, backorder or clamp_and_flag"})
+				return
+			}
+		}
+		if raw, ok := req.Config["location_rule"]; ok && raw != nil {
+			rule, _ := raw.(string)
+			if !services.IsValidLocationRule(rule) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "location_rule must be default, nearest or split"})
+				return
+			}
+		}
+
+		var channel models.Channel
+		if err := db.Where("organization_id = ? AND name = ?", orgID, c.Param("name")).First(&channel).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		if req.IsActive != nil {
+			channel.IsActive = *req.IsActive
+		}
+		if req.Config != nil {
+			cfg := make(map[string]interface{})
+			if len(channel.Config) > 0 {
+				_ = json.Unmarshal(channel.Config, &cfg)
+			}
+			for k, v := range req.Config {
+				if v == nil {
+					delete(cfg, k)
+				} else {
+					cfg[k] = v
+				}
+			}
+			b, _ := json.Marshal(cfg)
+			channel.Config = datatypes.JSON(b)
+		}
+		if err := db.Save(&channel).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel"})
+			return
+		}
+		c.JSON(http.StatusOK, toChannelResp(channel))
+	}
+}
+
+type syncDecisionResp struct {
+	ID               uint                   `json:"id"`
+	Channel          string                 `json:"channel"`
+	Topic            string                 `json:"topic"`
+	Policy           string                 `json:"policy"`
+	Decision         string                 `json:"decision"`
+	RemoteModifiedAt *time.Time             `json:"remote_modified_at"`
+	LocalUpdatedAt   *time.Time             `json:"local_updated_at"`
+	Changes          map[string]interface{} `json:"changes"`
+	CreatedAt        time.Time              `json:"created_at"`
+}
+
+// ListProductSyncDecisions returns how inbound channel changes to a product were resolved, newest first.
+func ListProductSyncDecisions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Model(&models.ProductSyncDecision{}).
+			Where("organization_id = ? AND product_id = ?", orgID, productID).
+			Session(&gorm.Session{})
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var decisions []models.ProductSyncDecision
+		if err := query.Order("id DESC").Limit(page.PerPage).Offset(page.Offset()).Find(&decisions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]syncDecisionResp, 0, len(decisions))
+		for _, d := range decisions {
+			resp := syncDecisionResp{
+				ID:               d.ID,
+				Channel:          d.Channel,
+				Topic:            d.Topic,
+				Policy:           d.Policy,
+				Decision:         d.Decision,
+				RemoteModifiedAt: d.RemoteModifiedAt,
+				LocalUpdatedAt:   d.LocalUpdatedAt,
+				CreatedAt:        d.CreatedAt,
+			}
+			_ = json.Unmarshal(d.Changes, &resp.Changes)
+			out = append(out, resp)
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// PreviewProductChannel returns the product as it would be published to a channel:
+// base fields, then the typed channel settings (custom price etc.), then the override JSON.
+// Each field's origin is reported in "sources".
+func PreviewProductChannel(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		channel := c.Param("channel")
+		if !services.KnownChannels[channel] {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown channel"})
+			return
+		}
+
+		var product models.Product
+		err = db.Preload("Images", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+			Preload("ProductWoo").
+			Preload("ProductONDC").
+			Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Where("id = ? AND organization_id = ?", productID, orgID).
+			First(&product).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		view, err := services.ResolveChannelView(db, &product, channel)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, view)
+	}
+}
+
+func toChannelResp(ch models.Channel) channelResp {
+	resp := channelResp{
+		ID:       ch.ID,
+		Name:     ch.Name,
+		Type:     ch.Type,
+		IsActive: ch.IsActive,
+		Config:   map[string]interface{}{},
+	}
+	if len(ch.Config) > 0 {
+		_ = json.Unmarshal(ch.Config, &resp.Config)
+	}
+	return resp
+}
+
+// requireOrgAdmin writes a 403 and returns false unless the current user is an admin of the org.
+func requireOrgAdmin(c *gin.Context, db *gorm.DB, orgID uint) bool {
+	userIDVal, exists := c.Get("user_Id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return false
+	}
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userIDVal).First(&member).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User not member of organization"})
+		return false
+	}
+	if member.RoleID != 1 { // Assuming 1 is Admin
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+		return false
+	}
+	return true
+}
+
+// isOrgAdmin reports whether the user is an admin of the organization, without answering
+// the request.
+func isOrgAdmin(db *gorm.DB, orgID, userID uint) (bool, error) {
+	var member models.OrganizationMember
+	err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return member.RoleID == 1, nil
+}
@@ -1,71 +1,315 @@
 package handlers
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"net/http"
 )
 
+const dashboardRecentOrders = 5
+
+// dashboardPeriods are the selectable ?period= values; "today" starts at midnight UTC.
+var dashboardPeriods = map[string]time.Duration{
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+	"90d": 90 * 24 * time.Hour,
+	"12m": 365 * 24 * time.Hour,
+}
+
 // DashboardResponse defines the structure of the data sent to the frontend
 type DashboardResponse struct {
+	Period       DashboardPeriod  `json:"period"`
 	Metrics      DashboardMetrics `json:"metrics"`
+	Channels     []ChannelMetrics `json:"channels"`
 	RecentOrders []RecentOrder    `json:"recentOrders"`
 }
 
+type DashboardPeriod struct {
+	Name         string    `json:"name"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	PreviousFrom time.Time `json:"previousFrom"`
+	PreviousTo   time.Time `json:"previousTo"`
+}
+
 type DashboardMetrics struct {
-	TotalSales    float64 `json:"totalSales"`
-	TotalOrders   int64   `json:"totalOrders"`
-	ProductCount  int64   `json:"productCount"`
-	LowStockCount int64   `json:"lowStockCount"`
+	TotalSales        float64  `json:"totalSales"`
+	TotalOrders       int64    `json:"totalOrders"`
+	AverageOrderValue float64  `json:"averageOrderValue"`
+	PreviousSales     float64  `json:"previousSales"`
+	PreviousOrders    int64    `json:"previousOrders"`
+	SalesChangePct    *float64 `json:"salesChangePct"`  // nil when the previous period had no sales
+	OrdersChangePct   *float64 `json:"ordersChangePct"` // nil when the previous period had no orders
+	ProductCount      int64    `json:"productCount"`
+	LowStockCount     int64    `json:"lowStockCount"`
+	LowStockThreshold int      `json:"lowStockThreshold"` // the org default; products may override it
+}
+
+// ChannelMetrics is one channel's (order source's) share of the period.
+type ChannelMetrics struct {
+	Source         string  `json:"source"`
+	Name           string  `json:"name"`
+	Sales          float64 `json:"sales"`
+	Orders         int64   `json:"orders"`
+	PreviousSales  float64 `json:"previousSales"`
+	PreviousOrders int64   `json:"previousOrders"`
 }
 
 type RecentOrder struct {
-	Title    string `json:"title"`
-	Subtitle string `json:"subtitle"`
-	Amount   string `json:"amount"`
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Subtitle  string    `json:"subtitle"`
+	Amount    string    `json:"amount"`
+	Status    string    `json:"status"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type sourceTotals struct {
+	Source string
+	Orders int64
+	Sales  float64
 }
 
-// GetDashboard fetches the data for the main dashboard
+// GetDashboard fetches the data for the main dashboard.
+// Sales and order counts leave out cancelled, refunded and failed orders. Amounts are
+// summed as-is, so an org selling in several currencies gets a mixed total.
+// Query params:
+//   - period: today, 7d, 30d (default), 90d or 12m
+//   - from / to: a custom range instead (RFC3339 or YYYY-MM-DD); compared with the
+//     equally long range right before it
 func GetDashboard(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the user ID from the middleware
-		userID, _ := c.Get("userId")
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		period, err := parseDashboardPeriod(c, time.Now().UTC())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		// Find the organization this user belongs to
-		var member models.OrganizationMember
-		if err := db.Where("user_id = ?", userID).First(&member).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User organization not found"})
+		var org models.Organization
+		if err := db.Select("id", "low_stock_threshold").First(&org, orgID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
 			return
 		}
-		orgID := member.OrganizationID
 
-		// --- Fetch data from the database ---
-		var productCount int64
-		db.Model(&models.Product{}).Where("organization_id = ?", orgID).Count(&productCount)
+		// --- Sales per channel, this period and the one before ---
+		current, err := salesBySource(db, orgID, period.From, period.To)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		previous, err := salesBySource(db, orgID, period.PreviousFrom, period.PreviousTo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
 
-		var lowStockCount int64
-		db.Model(&models.Product{}).Where("organization_id = ? AND quantity < ?", orgID, 10).Count(&lowStockCount)
+		metrics := DashboardMetrics{LowStockThreshold: org.LowStockThreshold}
+		channels := make([]ChannelMetrics, 0, len(current))
+		index := make(map[string]int)
+		channelFor := func(source string) *ChannelMetrics {
+			i, ok := index[source]
+			if !ok {
+				i = len(channels)
+				index[source] = i
+				channels = append(channels, ChannelMetrics{Source: source, Name: channelDisplayName(source)})
+			}
+			return &channels[i]
+		}
+		for _, t := range current {
+			ch := channelFor(t.Source)
+			ch.Sales, ch.Orders = t.Sales, t.Orders
+			metrics.TotalSales += t.Sales
+			metrics.TotalOrders += t.Orders
+		}
+		for _, t := range previous {
+			ch := channelFor(t.Source)
+			ch.PreviousSales, ch.PreviousOrders = t.Sales, t.Orders
+			metrics.PreviousSales += t.Sales
+			metrics.PreviousOrders += t.Orders
+		}
+		if metrics.TotalOrders > 0 {
+			metrics.AverageOrderValue = metrics.TotalSales / float64(metrics.TotalOrders)
+		}
+		metrics.SalesChangePct = changePct(metrics.TotalSales, metrics.PreviousSales)
+		metrics.OrdersChangePct = changePct(float64(metrics.TotalOrders), float64(metrics.PreviousOrders))
 
-		// --- Use DUMMY data for now (since we don't have an orders table yet) ---
-		metrics := DashboardMetrics{
-			TotalSales:    1250.00,
-			TotalOrders:   82,
-			ProductCount:  productCount,
-			LowStockCount: lowStockCount,
+		// --- Catalog ---
+		if err := db.Model(&models.Product{}).Where("organization_id = ?", orgID).Count(&metrics.ProductCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if err := lowStockProducts(db, orgID, org.LowStockThreshold).Count(&metrics.LowStockCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
 		}
 
-		recentOrders := []RecentOrder{
-			{Title: "Order #1082 - Handmade Scarf", Subtitle: "Shopify, 2m ago", Amount: "$25.00"},
-			{Title: "Order #1081 - Clay Vase", Subtitle: "WooCommerce, 1h ago", Amount: "$40.00"},
-			{Title: "Order #1080 - Scented Candle", Subtitle: "Amazon, 3h ago", Amount: "$15.50"},
+		// --- Recent orders (any status) ---
+		var orders []models.Order
+		if err := db.Omit("raw_data").
+			Where("organization_id = ?", orgID).
+			Order("created_at DESC, id DESC").
+			Limit(dashboardRecentOrders).
+			Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		now := time.Now()
+		recentOrders := make([]RecentOrder, 0, len(orders))
+		for _, o := range orders {
+			recentOrders = append(recentOrders, toRecentOrder(o, now))
 		}
 
 		response := DashboardResponse{
+			Period:       period,
 			Metrics:      metrics,
+			Channels:     channels,
 			RecentOrders: recentOrders,
 		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
+
+// parseDashboardPeriod reads ?period= or ?from=&to= and derives the previous period.
+func parseDashboardPeriod(c *gin.Context, now time.Time) (DashboardPeriod, error) {
+	var p DashboardPeriod
+	from, err := queryDate(c, "from", false)
+	if err != nil {
+		return p, err
+	}
+	to, err := queryDate(c, "to", true)
+	if err != nil {
+		return p, err
+	}
+
+	if from != nil || to != nil {
+		p.Name = "custom"
+		p.To = now
+		if to != nil {
+			p.To = *to
+		}
+		if from == nil {
+			return p, fmt.Errorf("from is required with to")
+		}
+		p.From = *from
+		if !p.From.Before(p.To) {
+			return p, fmt.Errorf("from must be before to")
+		}
+	} else {
+		p.Name = strings.ToLower(strings.TrimSpace(c.DefaultQuery("period", "30d")))
+		p.To = now
+		if p.Name == "today" {
+			p.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		} else if d, ok := dashboardPeriods[p.Name]; ok {
+			p.From = now.Add(-d)
+		} else {
+			return p, fmt.Errorf("invalid period %q (use today, 7d, 30d, 90d or 12m)", p.Name)
+		}
+	}
+	p.PreviousTo = p.From
+	p.PreviousFrom = p.From.Add(-p.To.Sub(p.From))
+	return p, nil
+}
+
+// salesBySource totals the org's counted orders created in [from, to) per source.
+func salesBySource(db *gorm.DB, orgID uint, from, to time.Time) ([]sourceTotals, error) {
+	var rows []sourceTotals
+	err := db.Model(&models.Order{}).
+		Select("source, COUNT(*) AS orders, COALESCE(SUM(total), 0) AS sales").
+		Where("organization_id = ? AND created_at >= ? AND created_at < ?", orgID, from, to).
+		Where("status NOT IN ?", []string{services.OrderStatusCancelled, services.OrderStatusRefunded, services.OrderStatusFailed}).
+		Group("source").
+		Order("sales DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// lowStockProducts scopes to stock-managed products at or below their low-stock threshold
+// (the product's own, else orgThreshold). A variable product counts when any of its
+// stock-managed variants is low.
+func lowStockProducts(db *gorm.DB, orgID uint, orgThreshold int) *gorm.DB {
+	return db.Model(&models.Product{}).
+		Where("products.organization_id = ?", orgID).
+		Where(`((
+			products.manage_stock
+			AND products.stock_quantity <= COALESCE(products.low_stock_threshold, ?)
+			AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND v.deleted_at IS NULL)
+		) OR EXISTS (
+			SELECT 1 FROM product_variants v
+			WHERE v.product_id = products.id AND v.deleted_at IS NULL
+			  AND v.manage_stock AND v.stock_quantity <= COALESCE(products.low_stock_threshold, ?)
+		))`, orgThreshold, orgThreshold)
+}
+
+// changePct is the percentage change from prev to cur, nil when prev is zero.
+func changePct(cur, prev float64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	pct := (cur - prev) / prev * 100
+	return &pct
+}
+
+func toRecentOrder(o models.Order, now time.Time) RecentOrder {
+	title := "Order #" + o.ExternalID
+	if items := services.DecodeOrderLineItems(o); len(items) > 0 {
+		title += " - " + items[0].Name
+		if len(items) > 1 {
+			title += fmt.Sprintf(" +%d more", len(items)-1)
+		}
+	}
+	return RecentOrder{
+		ID:        o.ID,
+		Title:     title,
+		Subtitle:  channelDisplayName(o.Source) + ", " + timeAgo(now.Sub(o.CreatedAt)),
+		Amount:    formatMoney(o.Currency, o.Total),
+		Status:    o.Status,
+		Source:    o.Source,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+func channelDisplayName(source string) string {
+	switch source {
+	case "woocommerce":
+		return "WooCommerce"
+	case "ondc":
+		return "ONDC"
+	}
+	return source
+}
+
+// timeAgo renders d as "2m ago", "1h ago", "3d ago".
+func timeAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+var currencySymbols = map[string]string{"USD": "$", "INR": "₹", "EUR": "€", "GBP": "£"}
+
+func formatMoney(currency string, amount float64) string {
+	if sym, ok := currencySymbols[strings.ToUpper(currency)]; ok {
+		return fmt.Sprintf("%s%.2f", sym, amount)
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %.2f", currency, amount))
+}
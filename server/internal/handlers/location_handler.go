@@ -0,0 +1,665 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type locationResp struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	AddressLine1 string    `json:"address_line1"`
+	AddressLine2 string    `json:"address_line2"`
+	City         string    `json:"city"`
+	State        string    `json:"state"`
+	Country      string    `json:"country"`
+	AreaCode     string    `json:"area_code"`
+	GPS          string    `json:"gps"`
+	CityCode     string    `json:"city_code"`
+	Phone        string    `json:"phone"`
+	IsActive     bool      `json:"is_active"`
+	IsDefault    bool      `json:"is_default"`
+	StockUnits   int       `json:"stock_units"` // on-hand summed over every product and variant
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// locationReq is the body of create (name required) and update (only the fields sent).
+type locationReq struct {
+	Name         *string `json:"name"`
+	AddressLine1 *string `json:"address_line1"`
+	AddressLine2 *string `json:"address_line2"`
+	City         *string `json:"city"`
+	State        *string `json:"state"`
+	Country      *string `json:"country"`
+	AreaCode     *string `json:"area_code"`
+	GPS          *string `json:"gps"`
+	CityCode     *string `json:"city_code"`
+	Phone        *string `json:"phone"`
+	IsActive     *bool   `json:"is_active"`
+	IsDefault    *bool   `json:"is_default"`
+}
+
+func (r *locationReq) validate() string {
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		return "name must not be empty"
+	}
+	if r.Country != nil && len(strings.TrimSpace(*r.Country)) != 2 {
+		return "country must be a 2-letter code"
+	}
+	if r.GPS != nil && strings.TrimSpace(*r.GPS) != "" {
+		if _, _, ok := services.ParseGPS(*r.GPS); !ok {
+			return `gps must be "lat,lng"`
+		}
+	}
+	return ""
+}
+
+func (r *locationReq) apply(l *models.SellerLocation) {
+	for _, f := range []struct {
+		dst *string
+		src *string
+	}{
+		{&l.Name, r.Name}, {&l.AddressLine1, r.AddressLine1}, {&l.AddressLine2, r.AddressLine2},
+		{&l.City, r.City}, {&l.State, r.State}, {&l.AreaCode, r.AreaCode}, {&l.GPS, r.GPS},
+		{&l.CityCode, r.CityCode}, {&l.Phone, r.Phone},
+	} {
+		if f.src != nil {
+			*f.dst = strings.TrimSpace(*f.src)
+		}
+	}
+	if r.Country != nil {
+		l.Country = strings.ToUpper(strings.TrimSpace(*r.Country))
+	}
+	if r.IsActive != nil {
+		l.IsActive = *r.IsActive
+	}
+}
+
+type setLocationStockReq struct {
+	LocationID uint  `json:"location_id" binding:"required"`
+	VariantID  *uint `json:"variant_id"`
+	StockQty   *int  `json:"stock_qty" binding:"required"`
+}
+
+type productStockResp struct {
+	ProductID uint                   `json:"product_id"`
+	Lines     []productStockLineResp `json:"lines"`
+}
+
+// productStockLineResp is the product itself, or one of its variants.
+type productStockLineResp struct {
+	VariantID   *uint                 `json:"variant_id,omitempty"`
+	SKU         string                `json:"sku"`
+	ManageStock bool                  `json:"manage_stock"`
+	OnHand      int                   `json:"on_hand"`
+	Reserved    int                   `json:"reserved"`
+	Available   int                   `json:"available"`
+	InTransit   int                   `json:"in_transit"` // dispatched on a transfer, not yet received; not in on_hand
+	Locations   []productStockLocResp `json:"locations"`
+}
+
+type productStockLocResp struct {
+	LocationID uint   `json:"location_id"`
+	Name       string `json:"name"`
+	StockQty   int    `json:"stock_qty"`
+}
+
+type locationStockRowResp struct {
+	ProductID uint   `json:"product_id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku"`
+	StockQty  int    `json:"stock_qty"`
+}
+
+// ListLocations lists the org's locations, the default first, with the stock each holds.
+func ListLocations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var locations []models.SellerLocation
+		if err := db.Where("organization_id = ?", orgID).Order("is_default DESC, id ASC").Find(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		units, err := locationStockUnits(db, orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]locationResp, 0, len(locations))
+		for _, l := range locations {
+			out = append(out, toLocationResp(l, units[l.ID]))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// GetLocation returns one location.
+func GetLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		location, ok := findLocation(c, db, orgID)
+		if !ok {
+			return
+		}
+		units, err := locationStockUnits(db, orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, toLocationResp(location, units[location.ID]))
+	}
+}
+
+// CreateLocation adds a location. Admin only. The org's first location becomes its default.
+func CreateLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		var req locationReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Name == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		if msg := req.validate(); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		location := models.SellerLocation{OrganizationID: orgID, Country: "IN", IsActive: true}
+		req.apply(&location)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var existing int64
+			if err := tx.Model(&models.SellerLocation{}).Where("organization_id = ?", orgID).Count(&existing).Error; err != nil {
+				return err
+			}
+			location.IsDefault = existing == 0 || (req.IsDefault != nil && *req.IsDefault)
+			if location.IsDefault {
+				if err := clearDefaultLocation(tx, orgID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Create(&location).Error; err != nil {
+				return err
+			}
+			if req.IsActive != nil && !*req.IsActive {
+				// gorm writes the column default (true) for a false bool on create
+				location.IsActive = false
+				return tx.Model(&location).Update("is_active", false).Error
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create location"})
+			return
+		}
+		c.JSON(http.StatusCreated, toLocationResp(location, 0))
+	}
+}
+
+// UpdateLocation changes the fields sent. Admin only. Setting is_default moves the default
+// here; the default can't be unset, only moved.
+func UpdateLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		var req locationReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if msg := req.validate(); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		location, ok := findLocation(c, db, orgID)
+		if !ok {
+			return
+		}
+		if req.IsDefault != nil && !*req.IsDefault && location.IsDefault {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Make another location the default instead"})
+			return
+		}
+
+		req.apply(&location)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if req.IsDefault != nil && *req.IsDefault && !location.IsDefault {
+				if err := clearDefaultLocation(tx, orgID); err != nil {
+					return err
+				}
+				location.IsDefault = true
+			}
+			return tx.Save(&location).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
+			return
+		}
+		units, _ := locationStockUnits(db, orgID)
+		c.JSON(http.StatusOK, toLocationResp(location, units[location.ID]))
+	}
+}
+
+// DeleteLocation removes a location that holds no stock and has no open transfers or
+// stocktakes. Admin only. The default location can only go once it is the last one.
+func DeleteLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		location, ok := findLocation(c, db, orgID)
+		if !ok {
+			return
+		}
+
+		var conflict string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var units int
+			if err := tx.Model(&models.ProductLocationStock{}).
+				Select("COALESCE(SUM(stock_qty), 0)").
+				Where("location_id = ?", location.ID).
+				Scan(&units).Error; err != nil {
+				return err
+			}
+			if units > 0 {
+				conflict = fmt.Sprintf("Location still holds %d units; move or adjust its stock first", units)
+				return nil
+			}
+			var open int64
+			if err := tx.Model(&models.StockTransfer{}).
+				Where("(from_location_id = ? OR to_location_id = ?) AND status IN ?", location.ID, location.ID,
+					[]string{services.TransferDraft, services.TransferDispatched}).
+				Count(&open).Error; err != nil {
+				return err
+			}
+			if open > 0 {
+				conflict = "Location has open stock transfers; receive or cancel them first"
+				return nil
+			}
+			if err := tx.Model(&models.Stocktake{}).
+				Where("location_id = ? AND status = ?", location.ID, services.StocktakeCounting).
+				Count(&open).Error; err != nil {
+				return err
+			}
+			if open > 0 {
+				conflict = "Location has an open stocktake; approve or cancel it first"
+				return nil
+			}
+			if location.IsDefault {
+				var others int64
+				if err := tx.Model(&models.SellerLocation{}).
+					Where("organization_id = ? AND id <> ?", orgID, location.ID).
+					Count(&others).Error; err != nil {
+					return err
+				}
+				if others > 0 {
+					conflict = "Make another location the default first"
+					return nil
+				}
+			}
+			if err := tx.Where("location_id = ?", location.ID).Delete(&models.ProductLocationStock{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&location).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
+			return
+		}
+		if conflict != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Location deleted"})
+	}
+}
+
+// ListLocationStock lists what a location holds, per product and variant.
+// Query params:
+//   - in_stock: true to leave out zero rows
+func ListLocationStock(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		inStock, err := queryBool(c, "in_stock")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		location, ok := findLocation(c, db, orgID)
+		if !ok {
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Table("product_location_stocks AS pls").
+			Joins("JOIN products ON products.id = pls.product_id AND products.deleted_at IS NULL").
+			Joins("LEFT JOIN product_variants v ON v.id = pls.variant_id").
+			Where("pls.location_id = ? AND pls.deleted_at IS NULL", location.ID)
+		if inStock != nil && *inStock {
+			query = query.Where("pls.stock_qty > 0")
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]locationStockRowResp, 0)
+		if err := query.
+			Select("pls.product_id, pls.variant_id, products.name, COALESCE(NULLIF(v.sku, ''), products.sku) AS sku, pls.stock_qty").
+			Order("products.name ASC, pls.product_id ASC, pls.variant_id ASC NULLS FIRST").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Scan(&out).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetProductStock shows a product's stock per line (the product, or each variant) and
+// location.
+func GetProductStock(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		resp, err := loadProductStock(db, orgID, uint(productID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// SetProductStock sets what one location holds of a product, or of one of its variants
+// (variant_id, required for a variable product). The line's on-hand becomes the sum of its
+// locations and the change is recorded as a movement and pushed to the channels.
+func SetProductStock(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var req setLocationStockReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *req.StockQty < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stock_qty must be non-negative"})
+			return
+		}
+
+		var product models.Product
+		if err := db.Preload("Variants").Where("id = ? AND organization_id = ?", productID, orgID).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if req.VariantID == nil && len(product.Variants) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "variant_id is required for a variable product"})
+			return
+		}
+		if req.VariantID != nil {
+			found := false
+			for _, v := range product.Variants {
+				found = found || v.ID == *req.VariantID
+			}
+			if !found {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "variant_id is not a variant of this product"})
+				return
+			}
+		}
+		var location models.SellerLocation
+		if err := db.Where("id = ? AND organization_id = ?", req.LocationID, orgID).First(&location).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "location_id is not one of your locations"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+		var delta int
+		err = services.WithActor(db, uid).Transaction(func(tx *gorm.DB) error {
+			var err error
+			delta, err = services.SetLocationStock(tx, orgID, product.ID, req.VariantID, location.ID, *req.StockQty, "location_set", productStockRef(product.ID))
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set stock"})
+			return
+		}
+		if delta != 0 {
+			go events.PublishStockChanged(orgID, "location", []services.StockChange{{ProductID: product.ID, VariantID: req.VariantID, ChangeQty: delta}})
+		}
+
+		resp, err := loadProductStock(db, orgID, product.ID)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Stock updated", "change": delta})
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// loadProductStock builds the per-line, per-location stock view of an org's product.
+func loadProductStock(db *gorm.DB, orgID, productID uint) (productStockResp, error) {
+	resp := productStockResp{ProductID: productID, Lines: []productStockLineResp{}}
+	var product models.Product
+	if err := db.Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Where("id = ? AND organization_id = ?", productID, orgID).
+		First(&product).Error; err != nil {
+		return resp, err
+	}
+
+	var rows []struct {
+		VariantID  *uint
+		LocationID uint
+		Name       string
+		StockQty   int
+	}
+	if err := db.Table("product_location_stocks AS pls").
+		Select("pls.variant_id, pls.location_id, sl.name, pls.stock_qty").
+		Joins("JOIN seller_locations sl ON sl.id = pls.location_id AND sl.deleted_at IS NULL").
+		Where("pls.product_id = ? AND pls.deleted_at IS NULL", productID).
+		Order("sl.is_default DESC, pls.location_id ASC").
+		Scan(&rows).Error; err != nil {
+		return resp, err
+	}
+
+	var transit []struct {
+		VariantID *uint
+		Qty       int
+	}
+	if err := db.Table("stock_transfer_lines AS l").
+		Select("l.variant_id, SUM(l.quantity) AS qty").
+		Joins("JOIN stock_transfers t ON t.id = l.transfer_id AND t.deleted_at IS NULL").
+		Where("l.product_id = ? AND t.status = ?", productID, services.TransferDispatched).
+		Group("l.variant_id").
+		Scan(&transit).Error; err != nil {
+		return resp, err
+	}
+
+	line := func(variantID *uint, sku string, manage bool, onHand int) (productStockLineResp, error) {
+		available, err := services.AvailableToSell(db, productID, variantID)
+		if err != nil {
+			return productStockLineResp{}, err
+		}
+		l := productStockLineResp{
+			VariantID: variantID, SKU: sku, ManageStock: manage,
+			OnHand: onHand, Reserved: onHand - available, Available: max(available, 0),
+			Locations: []productStockLocResp{},
+		}
+		for _, t := range transit {
+			if (t.VariantID == nil) == (variantID == nil) && (variantID == nil || *t.VariantID == *variantID) {
+				l.InTransit += t.Qty
+			}
+		}
+		for _, r := range rows {
+			if (r.VariantID == nil) != (variantID == nil) || (variantID != nil && *r.VariantID != *variantID) {
+				continue
+			}
+			l.Locations = append(l.Locations, productStockLocResp{LocationID: r.LocationID, Name: r.Name, StockQty: r.StockQty})
+		}
+		return l, nil
+	}
+
+	if len(product.Variants) == 0 {
+		l, err := line(nil, product.SKU, product.ManageStock, product.StockQuantity)
+		if err != nil {
+			return resp, err
+		}
+		resp.Lines = append(resp.Lines, l)
+	}
+	for _, v := range product.Variants {
+		id := v.ID
+		l, err := line(&id, v.SKU, v.ManageStock, v.StockQuantity)
+		if err != nil {
+			return resp, err
+		}
+		resp.Lines = append(resp.Lines, l)
+	}
+	return resp, nil
+}
+
+// findLocation loads the :id location of the org, answering 400/404 itself when it can't.
+func findLocation(c *gin.Context, db *gorm.DB, orgID uint) (models.SellerLocation, bool) {
+	var location models.SellerLocation
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return location, false
+	}
+	if err := db.Where("id = ? AND organization_id = ?", id, orgID).First(&location).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+			return location, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return location, false
+	}
+	return location, true
+}
+
+// locationStockUnits sums the stock at each of the org's locations.
+func locationStockUnits(db *gorm.DB, orgID uint) (map[uint]int, error) {
+	var rows []struct {
+		LocationID uint
+		Units      int
+	}
+	err := db.Table("product_location_stocks AS pls").
+		Select("pls.location_id, SUM(pls.stock_qty) AS units").
+		Joins("JOIN seller_locations sl ON sl.id = pls.location_id").
+		Where("sl.organization_id = ? AND pls.deleted_at IS NULL", orgID).
+		Group("pls.location_id").
+		Scan(&rows).Error
+	units := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		units[r.LocationID] = r.Units
+	}
+	return units, err
+}
+
+func clearDefaultLocation(tx *gorm.DB, orgID uint) error {
+	return tx.Model(&models.SellerLocation{}).
+		Where("organization_id = ? AND is_default", orgID).
+		Update("is_default", false).Error
+}
+
+// productStockRef is the movement ref for stock set by hand on a product.
+func productStockRef(productID uint) string {
+	return fmt.Sprintf("product:%d", productID)
+}
+
+func toLocationResp(l models.SellerLocation, units int) locationResp {
+	return locationResp{
+		ID:           l.ID,
+		Name:         l.Name,
+		AddressLine1: l.AddressLine1,
+		AddressLine2: l.AddressLine2,
+		City:         l.City,
+		State:        l.State,
+		Country:      l.Country,
+		AreaCode:     l.AreaCode,
+		GPS:          l.GPS,
+		CityCode:     l.CityCode,
+		Phone:        l.Phone,
+		IsActive:     l.IsActive,
+		IsDefault:    l.IsDefault,
+		StockUnits:   units,
+		CreatedAt:    l.CreatedAt,
+		UpdatedAt:    l.UpdatedAt,
+	}
+}
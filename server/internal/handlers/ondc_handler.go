@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/ondc"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+// ONDCSearch is the Beckn /search endpoint. It ACKs straight away and sends the catalog
+// to the buyer app's /on_search from a goroutine, as the protocol requires.
+// Mount behind middleware.RequireBecknSignature.
+func ONDCSearch(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ondc.SearchRequest
+		if !bindBecknRequest(c, cfg, &req, &req.Context, ondc.ActionSearch) {
+			return
+		}
+
+		go func() {
+			out := ondc.OnSearchRequest{
+				Context: req.Context.CallbackContext(ondc.ActionOnSearch, cfg.SubscriberID, cfg.SubscriberURL),
+			}
+			catalog, err := services.NewONDCService(db).BuildCatalog(req, cfg.SubscriberID)
+			if err != nil {
+				log.Printf("ondc: search %s failed: %v", req.Context.MessageID, err)
+				out.Error = ondc.DomainError(ondc.ErrCodeInternal, "catalog unavailable")
+			}
+			out.Message.Catalog = catalog
+			if err := cfg.Callback(req.Context.BapURI, ondc.ActionOnSearch, out); err != nil {
+				log.Printf("ondc: %v", err)
+				return
+			}
+			log.Printf("ondc: on_search %s sent to %s (%d providers)", req.Context.MessageID, req.Context.BapID, len(catalog.Providers))
+		}()
+
+		c.JSON(http.StatusOK, ondc.Ack())
+	}
+}
+
+// ONDCSelect is the Beckn /select endpoint: quote the cart and hold its stock (on_select).
+func ONDCSelect(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return ondcOrderAction(db, cfg, ondc.ActionSelect, ondc.ActionOnSelect,
+		func(s *services.ONDCService, req ondc.OrderRequest) (services.ONDCOrderResult, error) {
+			return s.Select(req.Context, req.Message.Order)
+		})
+}
+
+// ONDCInit is the Beckn /init endpoint: re-quote with billing and payment terms (on_init).
+func ONDCInit(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return ondcOrderAction(db, cfg, ondc.ActionInit, ondc.ActionOnInit,
+		func(s *services.ONDCService, req ondc.OrderRequest) (services.ONDCOrderResult, error) {
+			return s.Init(req.Context, req.Message.Order)
+		})
+}
+
+// ONDCConfirm is the Beckn /confirm endpoint: place the order (on_confirm).
+func ONDCConfirm(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return ondcOrderAction(db, cfg, ondc.ActionConfirm, ondc.ActionOnConfirm,
+		func(s *services.ONDCService, req ondc.OrderRequest) (services.ONDCOrderResult, error) {
+			return s.Confirm(req.Context, req.Message.Order)
+		})
+}
+
+// ONDCUpdate is the Beckn /update endpoint; we support item returns (on_update).
+func ONDCUpdate(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return ondcOrderAction(db, cfg, ondc.ActionUpdate, ondc.ActionOnUpdate,
+		func(s *services.ONDCService, req ondc.OrderRequest) (services.ONDCOrderResult, error) {
+			return s.Update(req.Context, req.Message.UpdateTarget, req.Message.Order)
+		})
+}
+
+// ONDCStatus is the Beckn /status endpoint (on_status).
+func ONDCStatus(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ondc.StatusRequest
+		if !bindBecknRequest(c, cfg, &req, &req.Context, ondc.ActionStatus) {
+			return
+		}
+		go sendONDCOrderCallback(cfg, req.Context, ondc.ActionOnStatus, func() (services.ONDCOrderResult, error) {
+			return services.NewONDCService(db).Status(req.Context, req.Message.OrderID)
+		})
+		c.JSON(http.StatusOK, ondc.Ack())
+	}
+}
+
+// ONDCCancel is the Beckn /cancel endpoint (on_cancel).
+func ONDCCancel(db *gorm.DB, cfg *ondc.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ondc.CancelRequest
+		if !bindBecknRequest(c, cfg, &req, &req.Context, ondc.ActionCancel) {
+			return
+		}
+		go sendONDCOrderCallback(cfg, req.Context, ondc.ActionOnCancel, func() (services.ONDCOrderResult, error) {
+			return services.NewONDCService(db).Cancel(req.Context, req.Message.OrderID, req.Message.CancellationReasonID)
+		})
+		c.JSON(http.StatusOK, ondc.Ack())
+	}
+}
+
+// ondcOrderAction serves the order actions whose body is an ondc.OrderRequest.
+func ondcOrderAction(db *gorm.DB, cfg *ondc.Config, action, callback string,
+	run func(*services.ONDCService, ondc.OrderRequest) (services.ONDCOrderResult, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ondc.OrderRequest
+		if !bindBecknRequest(c, cfg, &req, &req.Context, action) {
+			return
+		}
+		go sendONDCOrderCallback(cfg, req.Context, callback, func() (services.ONDCOrderResult, error) {
+			return run(services.NewONDCService(db), req)
+		})
+		c.JSON(http.StatusOK, ondc.Ack())
+	}
+}
+
+// sendONDCOrderCallback runs an order action and posts its on_<action> to the buyer app.
+// Protocol errors (*ondc.Error) go back as-is; anything else is logged and reported as
+// an internal error. Stock moved by the action is announced like any other order's.
+func sendONDCOrderCallback(cfg *ondc.Config, ctx ondc.Context, callback string, run func() (services.ONDCOrderResult, error)) {
+	res, err := run()
+	out := ondc.OnOrderRequest{Context: ctx.CallbackContext(callback, cfg.SubscriberID, cfg.SubscriberURL)}
+	out.Message.Order = res.Order
+	if err != nil {
+		var becknErr *ondc.Error
+		if errors.As(err, &becknErr) {
+			out.Error = becknErr
+		} else {
+			log.Printf("ondc: %s %s failed: %v", ctx.Action, ctx.TransactionID, err)
+			out.Error = ondc.DomainError(ondc.ErrCodeInternal, "internal error")
+		}
+	}
+	if len(res.StockChanges) > 0 {
+		events.PublishStockChanged(res.OrganizationID, "ondc", res.StockChanges)
+	}
+	if err := cfg.Callback(ctx.BapURI, callback, out); err != nil {
+		log.Printf("ondc: %v", err)
+	}
+}
+
+// bindBecknRequest decodes a Beckn request into req and checks its context, answering with
+// a NACK and returning false when it is unusable. ctx.BapURI is replaced with the buyer's
+// registered subscriber URL, where the callback goes.
+func bindBecknRequest(c *gin.Context, cfg *ondc.Config, req interface{}, ctx *ondc.Context, action string) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || json.Unmarshal(body, req) != nil {
+		c.JSON(http.StatusBadRequest, ondc.Nack(ondc.ErrorTypeJSONSchema, "10000", "invalid JSON"))
+		return false
+	}
+	if ctx.Action != action {
+		c.JSON(http.StatusBadRequest, ondc.Nack(ondc.ErrorTypeContext, "10000", "context.action must be "+action))
+		return false
+	}
+	if ctx.BapURI == "" || ctx.TransactionID == "" || ctx.MessageID == "" {
+		c.JSON(http.StatusBadRequest, ondc.Nack(ondc.ErrorTypeContext, "10000", "context.bap_uri, transaction_id and message_id are required"))
+		return false
+	}
+	if signer, ok := c.Get("beckn_subscriber_id"); ok && signer != ctx.BapID {
+		c.JSON(http.StatusUnauthorized, ondc.Nack(ondc.ErrorTypePolicy, "10001", "request not signed by context.bap_id"))
+		return false
+	}
+	callbackURL, err := cfg.CallbackURL(ctx.BapID, ctx.BapURI)
+	if err != nil {
+		log.Printf("ondc: rejected %s from %s: %v", action, ctx.BapID, err)
+		c.JSON(http.StatusUnauthorized, ondc.Nack(ondc.ErrorTypePolicy, "10001", "context.bap_id has no registered subscriber url"))
+		return false
+	}
+	ctx.BapURI = callbackURL
+	return true
+}
+
+// ensureONDCChannel returns the org's "ondc" channel, creating it the first time a product
+// is enabled on ONDC (there is no store to connect, unlike WooCommerce).
+func ensureONDCChannel(tx *gorm.DB, orgID uint) (models.Channel, error) {
+	var channel models.Channel
+	err := tx.Where("organization_id = ? AND name = ?", orgID, "ondc").
+		Attrs(models.Channel{Type: "ondc", IsActive: true}).
+		FirstOrCreate(&channel, models.Channel{OrganizationID: orgID, Name: "ondc"}).Error
+	return channel, err
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type orderFlagResp struct {
+	ID              uint            `json:"id"`
+	OrderID         uuid.UUID       `json:"order_id"`
+	OrderExternalID string          `json:"order_external_id,omitempty"`
+	OrderSource     string          `json:"order_source,omitempty"`
+	Reason          string          `json:"reason"`
+	Policy          string          `json:"policy"`
+	Details         json.RawMessage `json:"details,omitempty"`
+	OwedQty         int             `json:"owed_qty"`
+	Status          string          `json:"status"`
+	ResolvedAt      *time.Time      `json:"resolved_at"`
+	ResolvedBy      *uint           `json:"resolved_by"`
+	Note            string          `json:"note"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type resolveOrderFlagReq struct {
+	Note string `json:"note"`
+}
+
+// ListOrderFlags is the review queue of flagged orders (e.g. oversold), newest first.
+// Query params:
+//   - status: open (default), resolved or all
+//   - reason: oversell_rejected, oversell_clamped, backordered or duplicate_order
+func ListOrderFlags(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Table("order_flags AS f").
+			Joins("LEFT JOIN orders ON orders.id = f.order_id").
+			Where("f.organization_id = ? AND f.deleted_at IS NULL", orgID)
+		switch status := strings.TrimSpace(c.DefaultQuery("status", services.FlagStatusOpen)); status {
+		case "all":
+		case services.FlagStatusOpen, services.FlagStatusResolved:
+			query = query.Where("f.status = ?", status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, resolved or all"})
+			return
+		}
+		if reason := strings.TrimSpace(c.Query("reason")); reason != "" {
+			query = query.Where("f.reason = ?", reason)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var rows []struct {
+			models.OrderFlag
+			OrderExternalID string
+			OrderSource     string
+		}
+		if err := query.
+			Select("f.*, orders.external_id AS order_external_id, orders.source AS order_source").
+			Order("f.id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]orderFlagResp, 0, len(rows))
+		for _, r := range rows {
+			resp := toOrderFlagResp(r.OrderFlag)
+			resp.OrderExternalID, resp.OrderSource = r.OrderExternalID, r.OrderSource
+			out = append(out, resp)
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// ResolveOrderFlag takes a flag out of the review queue, recording who resolved it and an
+// optional note. Stock isn't touched; adjust it separately if the order was short-shipped.
+func ResolveOrderFlag(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		flagID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flag ID"})
+			return
+		}
+		var req resolveOrderFlagReq
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var flag models.OrderFlag
+		if err := db.Where("id = ? AND organization_id = ?", flagID, orgID).First(&flag).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Flag not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if flag.Status == services.FlagStatusResolved {
+			c.JSON(http.StatusConflict, gin.H{"error": "Flag already resolved"})
+			return
+		}
+
+		now := time.Now()
+		flag.Status = services.FlagStatusResolved
+		flag.ResolvedAt = &now
+		flag.ResolvedBy = &uid
+		flag.Note = strings.TrimSpace(req.Note)
+		if err := db.Save(&flag).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve flag"})
+			return
+		}
+		c.JSON(http.StatusOK, toOrderFlagResp(flag))
+	}
+}
+
+func toOrderFlagResp(f models.OrderFlag) orderFlagResp {
+	resp := orderFlagResp{
+		ID:         f.ID,
+		OrderID:    f.OrderID,
+		Reason:     f.Reason,
+		Policy:     f.Policy,
+		OwedQty:    f.OwedQty,
+		Status:     f.Status,
+		ResolvedAt: f.ResolvedAt,
+		ResolvedBy: f.ResolvedBy,
+		Note:       f.Note,
+		CreatedAt:  f.CreatedAt,
+	}
+	if len(f.Details) > 0 {
+		resp.Details = json.RawMessage(f.Details)
+	}
+	return resp
+}
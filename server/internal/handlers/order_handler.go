@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+// orderListFilter holds the filters accepted by the order list and export.
+//
+// Query params:
+//   - status: one or more comma-separated statuses, e.g. processing,on-hold
+//   - source: woocommerce or ondc
+//   - from / to: created date range, RFC3339 or YYYY-MM-DD (to is inclusive of the whole day)
+//   - customer_email: case-insensitive exact match
+//   - min_total / max_total: inclusive order total range
+type orderListFilter struct {
+	Statuses      []string
+	Source        string
+	From          *time.Time
+	To            *time.Time
+	CustomerEmail string
+	MinTotal      *float64
+	MaxTotal      *float64
+}
+
+type orderSummaryResp struct {
+	ID            uuid.UUID `json:"id"`
+	ExternalID    string    `json:"external_id"`
+	Source        string    `json:"source"`
+	Status        string    `json:"status"`
+	Currency      string    `json:"currency"`
+	Total         float64   `json:"total"`
+	CustomerName  string    `json:"customer_name"`
+	CustomerEmail string    `json:"customer_email"`
+	ItemCount     int       `json:"item_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type orderLineResp struct {
+	models.OrderLineItem
+	LocalProductID *uint  `json:"local_product_id"`
+	LocalVariantID *uint  `json:"local_variant_id"`
+	LocalName      string `json:"local_name,omitempty"`
+	LocalSKU       string `json:"local_sku,omitempty"`
+}
+
+type orderDetailResp struct {
+	orderSummaryResp
+	BillingAddress  *models.OrderAddress `json:"billing_address"`
+	ShippingAddress *models.OrderAddress `json:"shipping_address"`
+	LineItems       []orderLineResp      `json:"line_items"`
+}
+
+// parseOrderListFilter reads orderListFilter from the query string.
+func parseOrderListFilter(c *gin.Context) (orderListFilter, error) {
+	var f orderListFilter
+	var err error
+
+	if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			if s := strings.TrimSpace(part); s != "" {
+				f.Statuses = append(f.Statuses, s)
+			}
+		}
+	}
+	f.Source = strings.TrimSpace(c.Query("source"))
+	f.CustomerEmail = strings.TrimSpace(c.Query("customer_email"))
+
+	if f.From, err = queryDate(c, "from", false); err != nil {
+		return f, err
+	}
+	if f.To, err = queryDate(c, "to", true); err != nil {
+		return f, err
+	}
+	if f.MinTotal, err = queryFloat(c, "min_total"); err != nil {
+		return f, err
+	}
+	if f.MaxTotal, err = queryFloat(c, "max_total"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// apply narrows query (already scoped to the org's orders) by the filter conditions.
+func (f orderListFilter) apply(query *gorm.DB) *gorm.DB {
+	if len(f.Statuses) > 0 {
+		query = query.Where("orders.status IN ?", f.Statuses)
+	}
+	if f.Source != "" {
+		query = query.Where("orders.source = ?", f.Source)
+	}
+	if f.From != nil {
+		query = query.Where("orders.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("orders.created_at <= ?", *f.To)
+	}
+	if f.CustomerEmail != "" {
+		query = query.Where("LOWER(orders.customer_email) = LOWER(?)", f.CustomerEmail)
+	}
+	if f.MinTotal != nil {
+		query = query.Where("orders.total >= ?", *f.MinTotal)
+	}
+	if f.MaxTotal != nil {
+		query = query.Where("orders.total <= ?", *f.MaxTotal)
+	}
+	return query
+}
+
+// queryDate parses an RFC3339 timestamp or a YYYY-MM-DD date. A bare date is the start of
+// that day (UTC), or its last instant when endOfDay is set.
+func queryDate(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s (use RFC3339 or YYYY-MM-DD)", key)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// ListOrders lists the org's orders from every channel, newest first.
+// Accepts the filters described on orderListFilter.
+func ListOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		filter, err := parseOrderListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		query := filter.apply(db.Model(&models.Order{}).Where("orders.organization_id = ?", orgID)).
+			Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var orders []models.Order
+		if err := query.Omit("raw_data").
+			Order("orders.created_at DESC, orders.id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]orderSummaryResp, 0, len(orders))
+		for _, o := range orders {
+			out = append(out, toOrderSummaryResp(o))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetOrder returns one order with its addresses and line items, each line linked back to
+// the local product (and variant) it was sold from.
+func GetOrder(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		orderID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var order models.Order
+		if err := db.Where("id = ? AND organization_id = ?", orderID, orgID).First(&order).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		lines, err := services.NewOrderService(db).OrderLines(order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// local names/SKUs for the linked lines
+		var productIDs, variantIDs []uint
+		for _, l := range lines {
+			if l.LocalProductID != nil {
+				productIDs = append(productIDs, *l.LocalProductID)
+			}
+			if l.LocalVariantID != nil {
+				variantIDs = append(variantIDs, *l.LocalVariantID)
+			}
+		}
+		products := make(map[uint]models.Product)
+		if len(productIDs) > 0 {
+			var rows []models.Product
+			if err := db.Unscoped().Select("id", "name", "sku").Where("id IN ?", productIDs).Find(&rows).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			for _, p := range rows {
+				products[p.ID] = p
+			}
+		}
+		variants := make(map[uint]models.ProductVariant)
+		if len(variantIDs) > 0 {
+			var rows []models.ProductVariant
+			if err := db.Unscoped().Select("id", "sku").Where("id IN ?", variantIDs).Find(&rows).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			for _, v := range rows {
+				variants[v.ID] = v
+			}
+		}
+
+		resp := orderDetailResp{
+			orderSummaryResp: toOrderSummaryResp(order),
+			BillingAddress:   decodeOrderAddress(order.BillingAddress),
+			ShippingAddress:  decodeOrderAddress(order.ShippingAddress),
+			LineItems:        make([]orderLineResp, 0, len(lines)),
+		}
+		for _, l := range lines {
+			line := orderLineResp{OrderLineItem: l.OrderLineItem, LocalProductID: l.LocalProductID, LocalVariantID: l.LocalVariantID}
+			if l.LocalProductID != nil {
+				p := products[*l.LocalProductID]
+				line.LocalName, line.LocalSKU = p.Name, p.SKU
+			}
+			if l.LocalVariantID != nil {
+				if v, ok := variants[*l.LocalVariantID]; ok && v.SKU != "" {
+					line.LocalSKU = v.SKU
+				}
+			}
+			resp.LineItems = append(resp.LineItems, line)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ExportOrders streams the orders matching ListOrders' filters as CSV, newest first, with
+// buyer-supplied text guarded against spreadsheet formulas (see csvText).
+func ExportOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		filter, err := parseOrderListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query := filter.apply(db.Model(&models.Order{}).Where("orders.organization_id = ?", orgID)).
+			Session(&gorm.Session{})
+
+		filename := fmt.Sprintf("orders-%s", time.Now().UTC().Format("20060102-150405"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		if err := streamOrdersCSV(c, query); err != nil {
+			// Headers are already sent; all we can do is cut the stream short.
+			log.Printf("order export for org %d aborted: %v", orgID, err)
+		}
+	}
+}
+
+func streamOrdersCSV(c *gin.Context, query *gorm.DB) error {
+	header := []string{
+		"id", "external_id", "source", "status", "created_at", "currency", "total",
+		"customer_name", "customer_email", "item_count", "items",
+		"shipping_city", "shipping_state", "shipping_postcode", "shipping_country",
+	}
+
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	// keyset pages, so orders arriving mid-export neither shift rows into a second page
+	// nor push them past the end
+	var last *models.Order
+	for {
+		page := query.Omit("raw_data")
+		if last != nil {
+			page = page.Where("(orders.created_at, orders.id) < (?, ?)", last.CreatedAt, last.ID)
+		}
+		var orders []models.Order
+		if err := page.
+			Order("orders.created_at DESC, orders.id DESC").
+			Limit(exportBatchSize).
+			Find(&orders).Error; err != nil {
+			return err
+		}
+		for _, o := range orders {
+			items := services.DecodeOrderLineItems(o)
+			parts := make([]string, 0, len(items))
+			count := 0
+			for _, item := range items {
+				label := item.SKU
+				if label == "" {
+					label = item.Name
+				}
+				parts = append(parts, fmt.Sprintf("%s x%d", label, item.Quantity))
+				count += item.Quantity
+			}
+			var shipping models.OrderAddress
+			if a := decodeOrderAddress(o.ShippingAddress); a != nil {
+				shipping = *a
+			}
+			row := []string{
+				o.ID.String(), csvText(o.ExternalID), o.Source, o.Status, o.CreatedAt.UTC().Format(time.RFC3339), o.Currency, formatFloat(o.Total),
+				csvText(o.CustomerName), csvText(o.CustomerEmail), strconv.Itoa(count), csvText(strings.Join(parts, "; ")),
+				csvText(shipping.City), csvText(shipping.State), csvText(shipping.Postcode), csvText(shipping.Country),
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		if len(orders) < exportBatchSize {
+			return nil
+		}
+		last = &orders[len(orders)-1]
+	}
+}
+
+// csvText guards a free-text cell (buyer details, imported product text) against spreadsheet
+// formula injection: text that a spreadsheet would evaluate (starting with =, +, -, @, tab or CR) gets a leading '.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func toOrderSummaryResp(o models.Order) orderSummaryResp {
+	count := 0
+	for _, item := range services.DecodeOrderLineItems(o) {
+		count += item.Quantity
+	}
+	return orderSummaryResp{
+		ID:            o.ID,
+		ExternalID:    o.ExternalID,
+		Source:        o.Source,
+		Status:        o.Status,
+		Currency:      o.Currency,
+		Total:         o.Total,
+		CustomerName:  strings.TrimSpace(o.CustomerName),
+		CustomerEmail: o.CustomerEmail,
+		ItemCount:     count,
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+}
+
+// decodeOrderAddress parses a stored billing/shipping address; nil when there is none.
+func decodeOrderAddress(raw []byte) *models.OrderAddress {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var a models.OrderAddress
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil
+	}
+	return &a
+}
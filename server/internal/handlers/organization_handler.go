@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+	"strings"
 
 	"github.com/RvShivam/inventify/internal/models"
 	"github.com/gin-gonic/gin"
@@ -39,10 +40,93 @@ func GetOrganization(db *gorm.DB) gin.HandlerFunc {
 		memberCount := int64(len(org.Users))
 
 		c.JSON(http.StatusOK, gin.H{
-			"id":           org.ID,
-			"name":         org.Name,
-			"referralCode": org.ReferralCode,
-			"memberCount":  memberCount,
+			"id":                          org.ID,
+			"name":                        org.Name,
+			"referralCode":                org.ReferralCode,
+			"memberCount":                 memberCount,
+			"lowStockThreshold":           org.LowStockThreshold,
+			"adjustmentApprovalThreshold": org.AdjustmentApprovalThreshold,
+		})
+	}
+}
+
+type updateOrganizationReq struct {
+	Name              *string `json:"name"`
+	LowStockThreshold *int    `json:"lowStockThreshold"`
+	// null turns approval of stock adjustments off
+	AdjustmentApprovalThreshold nullableInt `json:"adjustmentApprovalThreshold"`
+}
+
+// UpdateOrganization changes the organization's name, default low-stock threshold and/or
+// stock adjustment approval threshold (Admin only)
+func UpdateOrganization(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization ID not found in context"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+
+		var req updateOrganizationReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates := map[string]interface{}{}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+				return
+			}
+			updates["name"] = name
+		}
+		if req.LowStockThreshold != nil {
+			if *req.LowStockThreshold < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "lowStockThreshold must be non-negative"})
+				return
+			}
+			updates["low_stock_threshold"] = *req.LowStockThreshold
+		}
+		if t := req.AdjustmentApprovalThreshold; t.Set {
+			if t.Value != nil && *t.Value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "adjustmentApprovalThreshold must be non-negative"})
+				return
+			}
+			updates["adjustment_approval_threshold"] = t.Value
+		}
+		if len(updates) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update"})
+			return
+		}
+
+		var org models.Organization
+		if err := db.First(&org, orgID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		if err := db.Model(&org).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+			return
+		}
+		if name, ok := updates["name"].(string); ok {
+			org.Name = name
+		}
+		if req.LowStockThreshold != nil {
+			org.LowStockThreshold = *req.LowStockThreshold
+		}
+		if req.AdjustmentApprovalThreshold.Set {
+			org.AdjustmentApprovalThreshold = req.AdjustmentApprovalThreshold.Value
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":                          org.ID,
+			"name":                        org.Name,
+			"lowStockThreshold":           org.LowStockThreshold,
+			"adjustmentApprovalThreshold": org.AdjustmentApprovalThreshold,
 		})
 	}
 }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// pagination holds the parsed ?page=&per_page= query params.
+type pagination struct {
+	Page    int
+	PerPage int
+}
+
+func (p pagination) Offset() int { return (p.Page - 1) * p.PerPage }
+
+// parsePagination reads page/per_page from the query string, falling back to sane defaults.
+func parsePagination(c *gin.Context) pagination {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return pagination{Page: page, PerPage: perPage}
+}
+
+// paginatedResp is the envelope returned by list endpoints.
+type paginatedResp struct {
+	Data    interface{} `json:"data"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int64       `json:"total"`
+}
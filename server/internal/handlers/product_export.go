@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+const exportBatchSize = 200
+
+// ExportProducts streams the org's catalog as CSV, JSON or a WooCommerce product-importer CSV.
+// Accepts the same filters and sorting as ListProducts.
+// Query params:
+//   - format: csv (default), json or woo_csv
+func ExportProducts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		filter, err := parseProductListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		format := strings.ToLower(c.DefaultQuery("format", "csv"))
+		if format != "csv" && format != "json" && format != "woo_csv" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format (use csv, json or woo_csv)"})
+			return
+		}
+
+		query := filter.apply(db.Model(&models.Product{}).Where("products.organization_id = ?", orgID)).
+			Session(&gorm.Session{})
+
+		var locations []models.SellerLocation
+		if err := db.Where("organization_id = ?", orgID).Order("id ASC").Find(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		channelNames := orgChannelNames(db, orgID)
+
+		filename := fmt.Sprintf("products-%s", time.Now().UTC().Format("20060102-150405"))
+		switch format {
+		case "json":
+			c.Header("Content-Type", "application/json")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+			err = streamProductsJSON(c, query, filter, channelNames)
+		case "woo_csv":
+			var maxAttrs int
+			if maxAttrs, err = maxAttributeCount(db, query); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-woocommerce.csv"`, filename))
+			err = streamProductsWooCSV(c, query, filter, maxAttrs)
+		default:
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+			err = streamProductsCSV(c, query, filter, locations, channelNames)
+		}
+		if err != nil {
+			// Headers are already sent; all we can do is cut the stream short.
+			log.Printf("product export for org %d aborted: %v", orgID, err)
+		}
+	}
+}
+
+// eachProductBatch pages through the filtered products in list order by keyset, with every
+// relation an export row needs preloaded, flushing the response between batches.
+func eachProductBatch(c *gin.Context, query *gorm.DB, filter productListFilter, fn func([]models.Product) error) error {
+	var lastID uint
+	for {
+		page := query
+		if lastID != 0 {
+			page = filter.after(query, lastID)
+		}
+		var products []models.Product
+		if err := page.
+			Preload("Images", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+			Preload("LocalCategory").
+			Preload("Attributes", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+			Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Preload("ProductWoo").
+			Preload("ProductONDC").
+			Preload("ProductChannels").
+			Preload("LocationStock").
+			Scopes(filter.order).
+			Limit(exportBatchSize).
+			Find(&products).Error; err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return nil
+		}
+		if err := fn(products); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		if len(products) < exportBatchSize {
+			return nil
+		}
+		lastID = products[len(products)-1].ID
+	}
+}
+
+/* ------------------------------
+   JSON
+   ------------------------------ */
+
+// exportProductJSON is the detail response plus absolute image URLs.
+type exportProductJSON struct {
+	productResp
+	ImageURLs []string `json:"image_urls"`
+}
+
+func streamProductsJSON(c *gin.Context, query *gorm.DB, filter productListFilter, channelNames map[uint]string) error {
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+	first := true
+	err := eachProductBatch(c, query, filter, func(products []models.Product) error {
+		for _, p := range products {
+			item := exportProductJSON{productResp: toProductResp(p, channelNames), ImageURLs: imageURLs(p.Images)}
+			b, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := c.Writer.WriteString(","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := c.Writer.Write(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.WriteString("]")
+	return err
+}
+
+/* ------------------------------
+   CSV
+   ------------------------------ */
+
+// streamProductsCSV writes one row per product and one per variant (type "variation", parent_id set).
+// Core column names match the importer's field names so an export can be re-imported.
+func streamProductsCSV(c *gin.Context, query *gorm.DB, filter productListFilter, locations []models.SellerLocation, channelNames map[uint]string) error {
+	header := []string{
+		"id", "parent_id", "type", "sku", "name", "attributes",
+		"short_description", "description", "brand", "category_name", "hsn_code", "country_of_origin",
+		"regular_price", "sale_price", "manage_stock", "stock_quantity",
+		"weight_kg", "length_cm", "width_cm", "height_cm", "is_featured",
+		"woo_product_id", "woo_status", "woo_type", "woo_catalog_visibility", "woo_custom_price",
+		"ondc_item_id", "ondc_fulfillment_type", "ondc_time_to_ship", "ondc_city_code",
+		"ondc_returnable", "ondc_cancellable", "ondc_warranty",
+		"enabled_channels", "image_urls",
+	}
+	for _, loc := range locations {
+		header = append(header, "stock@"+loc.Name)
+	}
+
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	err := eachProductBatch(c, query, filter, func(products []models.Product) error {
+		for _, p := range products {
+			productType := "simple"
+			if len(p.Variants) > 0 {
+				productType = "variable"
+			}
+			category := ""
+			if p.LocalCategory != nil {
+				category = p.LocalCategory.Name
+			}
+
+			row := []string{
+				strconv.FormatUint(uint64(p.ID), 10), "", productType, csvText(p.SKU), csvText(p.Name), "",
+				csvText(p.ShortDescription), csvText(p.Description), csvText(p.Brand), csvText(category), csvText(p.HSNCode), csvText(p.CountryOfOrigin),
+				formatFloat(p.RegularPrice), formatFloatPtr(p.SalePrice), strconv.FormatBool(p.ManageStock), strconv.Itoa(p.StockQuantity),
+				formatFloatPtr(p.WeightKg), formatFloatPtr(p.LengthCm), formatFloatPtr(p.WidthCm), formatFloatPtr(p.HeightCm), strconv.FormatBool(p.IsFeatured),
+			}
+			if pw := p.ProductWoo; pw.ID != 0 {
+				customPrice := ""
+				if pw.CustomPriceEnabled {
+					customPrice = formatFloatPtr(pw.CustomPriceValue)
+				}
+				row = append(row, formatInt64Ptr(pw.WooProductID), pw.Status, pw.Type, pw.CatalogVisibility, customPrice)
+			} else {
+				row = append(row, "", "", "", "", "")
+			}
+			if o := p.ProductONDC; o.ID != 0 {
+				row = append(row, o.ONDCItemID, csvText(o.FulfillmentType), csvText(o.TimeToShip), csvText(o.CityCode),
+					strconv.FormatBool(o.Returnable), strconv.FormatBool(o.Cancellable), csvText(o.Warranty))
+			} else {
+				row = append(row, "", "", "", "", "", "", "")
+			}
+
+			var enabled []string
+			for _, pc := range p.ProductChannels {
+				if pc.IsEnabled && channelNames[pc.ChannelID] != "" {
+					enabled = append(enabled, channelNames[pc.ChannelID])
+				}
+			}
+			row = append(row, strings.Join(enabled, ","), strings.Join(imageURLs(p.Images), ","))
+
+			row = append(row, locationStockCells(p.LocationStock, nil, locations)...)
+			if err := w.Write(row); err != nil {
+				return err
+			}
+
+			for _, v := range p.Variants {
+				vrow := make([]string, len(header))
+				vrow[0] = strconv.FormatUint(uint64(v.ID), 10)
+				vrow[1] = strconv.FormatUint(uint64(p.ID), 10)
+				vrow[2] = "variation"
+				vrow[3] = csvText(v.SKU)
+				vrow[4] = csvText(p.Name)
+				vrow[5] = csvText(formatVariantAttributes(v, p.Attributes))
+				vrow[12] = formatFloat(v.RegularPrice)
+				vrow[13] = formatFloatPtr(v.SalePrice)
+				vrow[14] = strconv.FormatBool(v.ManageStock)
+				vrow[15] = strconv.Itoa(v.StockQuantity)
+				vrow[34] = strings.Join(imageURLs(variantImages(p.Images, v.ID)), ",")
+				copy(vrow[len(header)-len(locations):], locationStockCells(p.LocationStock, &v.ID, locations))
+				if err := w.Write(vrow); err != nil {
+					return err
+				}
+			}
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// locationStockCells is one stock@ cell per location for the product (variantID nil) or one
+// of its variants; blank where the line has no row at that location.
+func locationStockCells(stock []models.ProductLocationStock, variantID *uint, locations []models.SellerLocation) []string {
+	byLocation := make(map[uint]int, len(stock))
+	for _, ls := range stock {
+		if (ls.VariantID == nil) != (variantID == nil) || (variantID != nil && *ls.VariantID != *variantID) {
+			continue
+		}
+		byLocation[ls.LocationID] += ls.StockQty
+	}
+	cells := make([]string, len(locations))
+	for i, loc := range locations {
+		if qty, ok := byLocation[loc.ID]; ok {
+			cells[i] = strconv.Itoa(qty)
+		}
+	}
+	return cells
+}
+
+/* ------------------------------
+   WooCommerce CSV
+   ------------------------------ */
+
+// streamProductsWooCSV writes the column layout of WooCommerce's built-in product CSV importer
+// (Products → Import). Variations follow their parent and reference it by SKU, or by Woo ID
+// when the parent has no SKU.
+func streamProductsWooCSV(c *gin.Context, query *gorm.DB, filter productListFilter, maxAttrs int) error {
+	header := []string{
+		"ID", "Type", "SKU", "Name", "Published", "Is featured?", "Visibility in catalog",
+		"Short description", "Description", "Tax status", "In stock?", "Stock",
+		"Weight (kg)", "Length (cm)", "Width (cm)", "Height (cm)", "Allow customer reviews?",
+		"Sale price", "Regular price", "Categories", "Images", "Parent",
+	}
+	for i := 1; i <= maxAttrs; i++ {
+		header = append(header,
+			fmt.Sprintf("Attribute %d name", i),
+			fmt.Sprintf("Attribute %d value(s)", i),
+			fmt.Sprintf("Attribute %d visible", i),
+			fmt.Sprintf("Attribute %d global", i),
+		)
+	}
+
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	err := eachProductBatch(c, query, filter, func(products []models.Product) error {
+		for _, p := range products {
+			pw := p.ProductWoo
+			productType := "simple"
+			if len(p.Variants) > 0 {
+				productType = "variable"
+			}
+			price := p.RegularPrice
+			if pw.CustomPriceEnabled && pw.CustomPriceValue != nil {
+				price = *pw.CustomPriceValue
+			}
+			visibility := pw.CatalogVisibility
+			if visibility == "" {
+				visibility = "visible"
+			}
+			category := ""
+			if p.LocalCategory != nil {
+				category = p.LocalCategory.Name
+			}
+
+			row := []string{
+				formatInt64Ptr(pw.WooProductID), productType, csvText(p.SKU), csvText(p.Name), wooPublished(pw.Status),
+				boolDigit(p.IsFeatured), visibility,
+				csvText(p.ShortDescription), csvText(p.Description), "taxable",
+				boolDigit(!p.ManageStock || p.StockQuantity > 0), wooStock(p.ManageStock && productType == "simple", p.StockQuantity),
+				formatFloatPtr(p.WeightKg), formatFloatPtr(p.LengthCm), formatFloatPtr(p.WidthCm), formatFloatPtr(p.HeightCm),
+				boolDigit(p.ReviewsAllowed),
+				formatFloatPtr(p.SalePrice), formatFloat(price), csvText(category),
+				strings.Join(imageURLs(p.Images), ", "), "",
+			}
+			if productType == "variable" {
+				// prices and stock live on the variations
+				row[17], row[18] = "", ""
+			}
+			attrs := toAttributeResps(p.Attributes)
+			for i := 0; i < maxAttrs; i++ {
+				if i < len(attrs) {
+					row = append(row, csvText(attrs[i].Name), csvText(strings.Join(attrs[i].Options, ", ")), "1", "0")
+				} else {
+					row = append(row, "", "", "", "")
+				}
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+
+			parentRef := p.SKU
+			if parentRef == "" && pw.WooProductID != nil {
+				parentRef = fmt.Sprintf("id:%d", *pw.WooProductID)
+			}
+			for _, v := range p.Variants {
+				var values map[string]string
+				_ = json.Unmarshal(v.Attributes, &values)
+				vrow := make([]string, len(header))
+				vrow[0] = formatInt64Ptr(v.WooVariationID)
+				vrow[1] = "variation"
+				vrow[2] = csvText(v.SKU)
+				vrow[3] = csvText(p.Name)
+				vrow[4] = wooPublished(pw.Status)
+				vrow[6] = "visible"
+				vrow[9] = "taxable"
+				vrow[10] = boolDigit(!v.ManageStock || v.StockQuantity > 0)
+				vrow[11] = wooStock(v.ManageStock, v.StockQuantity)
+				vrow[17] = formatFloatPtr(v.SalePrice)
+				vrow[18] = formatFloat(v.RegularPrice)
+				vrow[20] = strings.Join(imageURLs(variantImages(p.Images, v.ID)), ", ")
+				vrow[21] = csvText(parentRef)
+				for i := 0; i < maxAttrs && i < len(attrs); i++ {
+					base := 22 + i*4
+					vrow[base] = csvText(attrs[i].Name)
+					vrow[base+1] = csvText(values[attrs[i].Name])
+					vrow[base+3] = "0"
+				}
+				if err := w.Write(vrow); err != nil {
+					return err
+				}
+			}
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// maxAttributeCount is the largest attribute set among the filtered products,
+// which fixes how many "Attribute N" column groups the Woo CSV needs.
+func maxAttributeCount(db *gorm.DB, query *gorm.DB) (int, error) {
+	counts := db.Model(&models.ProductAttribute{}).
+		Select("COUNT(*) AS n").
+		Where("product_id IN (?)", query.Select("products.id")).
+		Group("product_id")
+	var n int
+	err := db.Table("(?) AS t", counts).Select("COALESCE(MAX(n), 0)").Scan(&n).Error
+	return n, err
+}
+
+/* ------------------------------
+   Formatting helpers
+   ------------------------------ */
+
+func imageURLs(images []models.ProductImage) []string {
+	urls := make([]string, 0, len(images))
+	for _, img := range images {
+		urls = append(urls, services.PublicImageURL(img.Src))
+	}
+	return urls
+}
+
+// variantImages picks the parent gallery images linked to a variant.
+func variantImages(images []models.ProductImage, variantID uint) []models.ProductImage {
+	var out []models.ProductImage
+	for _, img := range images {
+		if img.VariantID != nil && *img.VariantID == variantID {
+			out = append(out, img)
+		}
+	}
+	return out
+}
+
+// formatVariantAttributes renders a variant's combination as "Size=M; Colour=Red" in attribute order.
+func formatVariantAttributes(v models.ProductVariant, attrs []models.ProductAttribute) string {
+	var values map[string]string
+	_ = json.Unmarshal(v.Attributes, &values)
+	parts := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, a := range attrs {
+		if val, ok := values[a.Name]; ok {
+			parts = append(parts, a.Name+"="+val)
+			seen[a.Name] = true
+		}
+	}
+	var rest []string
+	for name, val := range values {
+		if !seen[name] {
+			rest = append(rest, name+"="+val)
+		}
+	}
+	sort.Strings(rest)
+	return strings.Join(append(parts, rest...), "; ")
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatFloatPtr(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
+
+func formatInt64Ptr(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func boolDigit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// wooPublished maps a Woo post status to the importer's Published column (1 / 0 private / -1 draft).
+func wooPublished(status string) string {
+	switch status {
+	case "publish":
+		return "1"
+	case "private":
+		return "0"
+	default:
+		return "-1"
+	}
+}
+
+func wooStock(manage bool, qty int) string {
+	if !manage {
+		return ""
+	}
+	return strconv.Itoa(qty)
+}
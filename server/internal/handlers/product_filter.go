@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// productListFilter holds the search/filter/sort options accepted by the product list.
+//
+// Query params:
+//   - q: search over name, SKU, brand and HSN code (trigram-indexed ILIKE)
+//   - category_id: one or more comma-separated LocalCategoryIDs
+//   - channel + channel_enabled: products enabled (or not) on the named channel, e.g. channel=woocommerce&channel_enabled=true
+//   - min_stock / max_stock: inclusive stock range
+//   - min_price / max_price: inclusive range on the effective price (sale price when set, else regular price)
+//   - featured: true/false
+//   - sort: name, sku, brand, hsn_code, category, price, stock, featured, created_at, updated_at, relevance
+//   - order: asc/desc
+type productListFilter struct {
+	Search         string
+	CategoryIDs    []uint
+	Channel        string
+	ChannelEnabled *bool
+	MinStock       *int
+	MaxStock       *int
+	MinPrice       *float64
+	MaxPrice       *float64
+	Featured       *bool
+	Sort           string
+	Desc           bool
+}
+
+// effectivePriceSQL is the price a customer pays; used for price filters and sorting.
+const effectivePriceSQL = "COALESCE(products.sale_price, products.regular_price)"
+
+// productSortColumns whitelists sortable fields so user input never reaches ORDER BY verbatim.
+var productSortColumns = map[string]string{
+	"name":       "products.name",
+	"sku":        "products.sku",
+	"brand":      "products.brand",
+	"hsn_code":   "products.hsn_code",
+	"category":   "products.local_category_id",
+	"price":      effectivePriceSQL,
+	"stock":      "products.stock_quantity",
+	"featured":   "products.is_featured",
+	"created_at": "products.created_at",
+	"updated_at": "products.updated_at",
+}
+
+// parseProductListFilter reads productListFilter from the query string.
+func parseProductListFilter(c *gin.Context) (productListFilter, error) {
+	var f productListFilter
+	var err error
+
+	f.Search = strings.TrimSpace(c.Query("q"))
+
+	if raw := strings.TrimSpace(c.Query("category_id")); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil {
+				return f, fmt.Errorf("invalid category_id %q", part)
+			}
+			f.CategoryIDs = append(f.CategoryIDs, uint(id))
+		}
+	}
+
+	f.Channel = strings.TrimSpace(c.Query("channel"))
+	if f.ChannelEnabled, err = queryBool(c, "channel_enabled"); err != nil {
+		return f, err
+	}
+	if f.Channel != "" && f.ChannelEnabled == nil {
+		enabled := true
+		f.ChannelEnabled = &enabled
+	}
+	if f.Channel == "" && f.ChannelEnabled != nil {
+		return f, fmt.Errorf("channel_enabled requires channel")
+	}
+
+	if f.MinStock, err = queryInt(c, "min_stock"); err != nil {
+		return f, err
+	}
+	if f.MaxStock, err = queryInt(c, "max_stock"); err != nil {
+		return f, err
+	}
+	if f.MinPrice, err = queryFloat(c, "min_price"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = queryFloat(c, "max_price"); err != nil {
+		return f, err
+	}
+	if f.Featured, err = queryBool(c, "featured"); err != nil {
+		return f, err
+	}
+
+	f.Sort = strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	if f.Sort == "" {
+		// best match first when searching, newest first otherwise
+		f.Desc = true
+		if f.Search != "" {
+			f.Sort = "relevance"
+		} else {
+			f.Sort = "created_at"
+		}
+	} else if _, ok := productSortColumns[f.Sort]; !ok && f.Sort != "relevance" {
+		return f, fmt.Errorf("invalid sort %q", f.Sort)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(c.Query("order"))) {
+	case "":
+	case "asc":
+		f.Desc = false
+	case "desc":
+		f.Desc = true
+	default:
+		return f, fmt.Errorf("invalid order %q (use asc or desc)", c.Query("order"))
+	}
+
+	return f, nil
+}
+
+// apply narrows query (already scoped to the org's products) by the filter conditions.
+func (f productListFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Search != "" {
+		like := "%" + escapeLike(f.Search) + "%"
+		query = query.Where(
+			"products.name ILIKE ? OR products.sku ILIKE ? OR products.brand ILIKE ? OR products.hsn_code ILIKE ?",
+			like, like, like, like,
+		)
+	}
+	if len(f.CategoryIDs) > 0 {
+		query = query.Where("products.local_category_id IN ?", f.CategoryIDs)
+	}
+	if f.Channel != "" {
+		enabledOnChannel := `EXISTS (
+			SELECT 1 FROM product_channels pc
+			JOIN channels ch ON ch.id = pc.channel_id AND ch.deleted_at IS NULL
+			WHERE pc.product_id = products.id AND pc.deleted_at IS NULL
+			  AND pc.is_enabled = TRUE AND ch.name = ?)`
+		if *f.ChannelEnabled {
+			query = query.Where(enabledOnChannel, f.Channel)
+		} else {
+			query = query.Where("NOT "+enabledOnChannel, f.Channel)
+		}
+	}
+	if f.MinStock != nil {
+		query = query.Where("products.stock_quantity >= ?", *f.MinStock)
+	}
+	if f.MaxStock != nil {
+		query = query.Where("products.stock_quantity <= ?", *f.MaxStock)
+	}
+	if f.MinPrice != nil {
+		query = query.Where(effectivePriceSQL+" >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		query = query.Where(effectivePriceSQL+" <= ?", *f.MaxPrice)
+	}
+	if f.Featured != nil {
+		query = query.Where("products.is_featured = ?", *f.Featured)
+	}
+	return query
+}
+
+// sortKey is the ORDER BY expression ahead of the id tie-break, with its bind vars.
+func (f productListFilter) sortKey() (string, []interface{}) {
+	if f.Sort == "relevance" && f.Search != "" {
+		// pg_trgm similarity across the searched columns
+		return "GREATEST(similarity(products.name, ?), similarity(products.sku, ?), similarity(products.brand, ?), similarity(products.hsn_code, ?))",
+			[]interface{}{f.Search, f.Search, f.Search, f.Search}
+	}
+	col, ok := productSortColumns[f.Sort]
+	if !ok {
+		col = "products.created_at"
+	}
+	return col, nil
+}
+
+// order appends the ORDER BY clause; ties are broken by id so pagination is stable.
+func (f productListFilter) order(query *gorm.DB) *gorm.DB {
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+	key, vars := f.sortKey()
+	return query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:                key + " " + dir + ", products.id " + dir,
+		Vars:               vars,
+		WithoutParentheses: true,
+	}})
+}
+
+// after keeps the rows that come after product lastID in order's sequence (keyset paging).
+// lastID's sort key is read back from its row, so every sort works, including nullable
+// columns: Postgres puts NULL keys last ascending and first descending.
+func (f productListFilter) after(query *gorm.DB, lastID uint) *gorm.DB {
+	key, vars := f.sortKey()
+	last := "(SELECT " + key + " FROM products WHERE products.id = ?)"
+	lastVars := append(append([]interface{}{}, vars...), lastID)
+
+	sql := "(" + key + " > " + last + " OR (" + key + " IS NOT DISTINCT FROM " + last + " AND products.id > ?) OR (" +
+		key + " IS NULL AND " + last + " IS NOT NULL))"
+	if f.Desc {
+		sql = "(" + key + " < " + last + " OR (" + key + " IS NOT DISTINCT FROM " + last + " AND products.id < ?) OR (" +
+			key + " IS NOT NULL AND " + last + " IS NULL))"
+	}
+	// every "key ... last" pair binds the key's vars, then the subquery's vars and lastID
+	pair := append(append([]interface{}{}, vars...), lastVars...)
+	args := append(append([]interface{}{}, pair...), pair...)
+	args = append(append(args, lastID), pair...)
+	return query.Where(sql, args...)
+}
+
+// escapeLike escapes LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func queryInt(c *gin.Context, key string) (*int, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &v, nil
+}
+
+func queryFloat(c *gin.Context, key string) (*float64, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &v, nil
+}
+
+func queryBool(c *gin.Context, key string) (*bool, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &v, nil
+}
@@ -2,18 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
 	"github.com/RvShivam/inventify/internal/events"
 	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
 )
 
 // CreateProduct handles the creation of a new product with optional channel configs and images.
@@ -48,6 +55,10 @@ func CreateProduct(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid JSON data: %v", err)})
 			return
 		}
+		if msg := req.validate(); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
 
 		// 3. Handle Image Uploads
 		form, _ := c.MultipartForm()
@@ -78,155 +89,176 @@ func CreateProduct(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		// 4. Database Transaction
-		tx := db.Begin()
-		if tx.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
-		}
-
-		// Find or Create Category
-		var categoryID *uint
-		if req.CategoryName != "" {
-			var cat models.Category
-			// Try to find by name
-			if err := tx.Where("name = ?", req.CategoryName).First(&cat).Error; err == nil {
-				categoryID = &cat.ID
-			} else if err == gorm.ErrRecordNotFound {
-				// Create if not exists (optional, or return error)
-				cat = models.Category{Name: req.CategoryName}
-				if err := tx.Create(&cat).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
-					return
-				}
-				categoryID = &cat.ID
-			} else {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error finding category"})
+		var product models.Product
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			product, err = createProductRecords(tx, orgID, req, savedImagePaths)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, errSKUTaken) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 				return
 			}
-		}
-
-		// Create Product
-		product := models.Product{
-			OrganizationID:   orgID,
-			Name:             req.Name,
-			ShortDescription: req.ShortDescription,
-			Description:      req.Description,
-			SKU:              req.SKU,
-			Brand:            req.Brand,
-			HSNCode:          req.HSNCode,
-			CountryOfOrigin:  req.CountryOfOrigin,
-			LocalCategoryID:  categoryID,
-			RegularPrice:     req.RegularPrice,
-			SalePrice:        req.SalePrice,
-			StockQuantity:    req.StockQuantity,
-			ManageStock:      true,
-			WeightKg:         req.WeightKg,
-			LengthCm:         req.LengthCm,
-			WidthCm:          req.WidthCm,
-			HeightCm:         req.HeightCm,
-		}
-
-		if err := tx.Create(&product).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create product: %v", err)})
+			if isUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A product with this SKU already exists"})
+				return
+			}
+			log.Printf("create product for org %d failed: %v", orgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 			return
 		}
 
-		// Create Images
-		for i, src := range savedImagePaths {
-			img := models.ProductImage{
-				ProductID: product.ID,
-				Src:       src,
-				Position:  i,
-				IsPrimary: i == 0,
-			}
-			if err := tx.Create(&img).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save product images"})
-				return
-			}
+		// Publish Event
+		go publishProductEvent(events.RoutingKeyProductCreated, events.ProductCreatedEvent{
+			BaseEvent: events.BaseEvent{
+				Event:     events.RoutingKeyProductCreated,
+				Version:   1,
+				Timestamp: time.Now().UTC(),
+			},
+			ProductID:      product.ID,
+			OrganizationID: product.OrganizationID,
+		})
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully", "id": product.ID})
+	}
+}
+
+// createProductRecords inserts a product with its images, variants and channel settings inside tx.
+// imagePaths are already-stored upload paths (e.g. "/uploads/<uuid>.png").
+func createProductRecords(tx *gorm.DB, orgID uint, req createProductReq, imagePaths []string) (models.Product, error) {
+	// Find or Create Category
+	categoryID, err := findOrCreateCategory(tx, req.CategoryName)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("failed to resolve category: %w", err)
+	}
+
+	// Product SKUs share one namespace with variant SKUs across the organization
+	if req.SKU != "" {
+		taken, err := skuTakenInOrg(tx, orgID, req.SKU, 0, 0)
+		if err != nil {
+			return models.Product{}, err
 		}
+		if taken {
+			return models.Product{}, errSKUTaken
+		}
+	}
 
-		// Handle WooCommerce
-		if req.Woo != nil && req.Woo.Enabled {
-			woo := models.ProductWoo{
-				ProductID:          product.ID,
-				Status:             "publish", // Default to publish if enabled
-				Type:               "simple",
-				CatalogVisibility:  req.Woo.CatalogVisibility,
-				CustomPriceEnabled: req.Woo.CustomPrice != nil,
-				CustomPriceValue:   req.Woo.CustomPrice,
-			}
-			if err := tx.Create(&woo).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save WooCommerce settings"})
-				return
-			}
+	// Create Product
+	product := models.Product{
+		OrganizationID:    orgID,
+		Name:              req.Name,
+		ShortDescription:  req.ShortDescription,
+		Description:       req.Description,
+		SKU:               req.SKU,
+		Brand:             req.Brand,
+		HSNCode:           req.HSNCode,
+		CountryOfOrigin:   req.CountryOfOrigin,
+		LocalCategoryID:   categoryID,
+		RegularPrice:      req.RegularPrice,
+		SalePrice:         req.SalePrice,
+		StockQuantity:     req.StockQuantity,
+		LowStockThreshold: req.LowStockThreshold,
+		ManageStock:       true,
+		WeightKg:          req.WeightKg,
+		LengthCm:          req.LengthCm,
+		WidthCm:           req.WidthCm,
+		HeightCm:          req.HeightCm,
+	}
 
-			// Also add to ProductChannels
-			// Find Woo Channel ID (assuming it exists)
-			var wooChannel models.Channel
-			if err := tx.Where("name = ? AND organization_id = ?", "woocommerce", orgID).First(&wooChannel).Error; err == nil {
-				pc := models.ProductChannel{
-					ProductID: product.ID,
-					ChannelID: wooChannel.ID,
-					IsEnabled: true,
-				}
-				tx.Create(&pc)
-			}
+	if err := tx.Create(&product).Error; err != nil {
+		return product, fmt.Errorf("failed to create product: %w", err)
+	}
+	if err := services.RecordOpeningStock(tx, product.ID, nil, product.StockQuantity, productStockRef(product.ID)); err != nil {
+		return product, err
+	}
+
+	// Create Images
+	createdImages := make([]models.ProductImage, 0, len(imagePaths))
+	for i, src := range imagePaths {
+		img := models.ProductImage{
+			ProductID: product.ID,
+			Src:       src,
+			Position:  i,
+			IsPrimary: i == 0,
+		}
+		if err := tx.Create(&img).Error; err != nil {
+			return product, fmt.Errorf("failed to save product images: %w", err)
 		}
+		createdImages = append(createdImages, img)
+	}
 
-		// Handle ONDC
-		if req.ONDC != nil && req.ONDC.Enabled {
-			ondc := models.ProductONDC{
-				ProductID:       product.ID,
-				FulfillmentType: req.ONDC.FulfillmentType,
-				TimeToShip:      req.ONDC.TimeToShip,
-				CityCode:        req.ONDC.CityCode,
-				Returnable:      req.ONDC.Returnable,
-				Cancellable:     req.ONDC.Cancellable,
-				Warranty:        req.ONDC.Warranty,
-			}
-			if err := tx.Create(&ondc).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save ONDC settings"})
-				return
-			}
+	// Variable products: attributes + variant children
+	productType := "simple"
+	if len(req.Variants) > 0 {
+		productType = "variable"
+		if err := replaceProductAttributes(tx, product.ID, req.Attributes); err != nil {
+			return product, fmt.Errorf("failed to save product attributes: %w", err)
+		}
+		if err := syncProductVariants(tx, orgID, product.ID, req.Variants, createdImages); err != nil {
+			return product, err
+		}
+	}
 
-			// Also add to ProductChannels
-			var ondcChannel models.Channel
-			if err := tx.Where("name = ? AND organization_id = ?", "ondc", orgID).First(&ondcChannel).Error; err == nil {
-				pc := models.ProductChannel{
-					ProductID: product.ID,
-					ChannelID: ondcChannel.ID,
-					IsEnabled: true,
-				}
-				tx.Create(&pc)
+	// Handle WooCommerce
+	if req.Woo != nil && req.Woo.Enabled {
+		woo := models.ProductWoo{
+			ProductID:          product.ID,
+			Status:             "publish", // Default to publish if enabled
+			Type:               productType,
+			CatalogVisibility:  req.Woo.CatalogVisibility,
+			CustomPriceEnabled: req.Woo.CustomPrice != nil,
+			CustomPriceValue:   req.Woo.CustomPrice,
+		}
+		if err := tx.Create(&woo).Error; err != nil {
+			return product, fmt.Errorf("failed to save WooCommerce settings: %w", err)
+		}
+
+		// Also add to ProductChannels
+		// Find Woo Channel ID (assuming it exists)
+		var wooChannel models.Channel
+		if err := tx.Where("name = ? AND organization_id = ?", "woocommerce", orgID).First(&wooChannel).Error; err == nil {
+			pc := models.ProductChannel{
+				ProductID: product.ID,
+				ChannelID: wooChannel.ID,
+				IsEnabled: true,
 			}
+			tx.Create(&pc)
 		}
+	}
 
-		tx.Commit()
+	// Handle ONDC
+	if req.ONDC != nil && req.ONDC.Enabled {
+		ondc := models.ProductONDC{
+			ProductID:       product.ID,
+			FulfillmentType: req.ONDC.FulfillmentType,
+			TimeToShip:      req.ONDC.TimeToShip,
+			CityCode:        req.ONDC.CityCode,
+			Returnable:      req.ONDC.Returnable,
+			Cancellable:     req.ONDC.Cancellable,
+			Warranty:        req.ONDC.Warranty,
 
-		// Publish Event
-		go func() {
-			ev := events.ProductCreatedEvent{
-				BaseEvent: events.BaseEvent{
-					Event:     events.RoutingKeyProductCreated,
-					Version:   1,
-					Timestamp: time.Now().UTC(),
-				},
-				ProductID:      product.ID,
-				OrganizationID: product.OrganizationID,
-			}
-			if err := events.Publish(events.RoutingKeyProductCreated, ev); err != nil {
-				fmt.Printf("Failed to publish product.created event: %v\n", err)
-			}
-		}()
+			CustomPriceEnabled: req.ONDC.CustomPrice != nil,
+			CustomPriceValue:   req.ONDC.CustomPrice,
+		}
+		if err := tx.Create(&ondc).Error; err != nil {
+			return product, fmt.Errorf("failed to save ONDC settings: %w", err)
+		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully", "id": product.ID})
+		// Also add to ProductChannels
+		ondcChannel, err := ensureONDCChannel(tx, orgID)
+		if err != nil {
+			return product, fmt.Errorf("failed to set up ONDC channel: %w", err)
+		}
+		pc := models.ProductChannel{
+			ProductID: product.ID,
+			ChannelID: ondcChannel.ID,
+			IsEnabled: true,
+		}
+		tx.Create(&pc)
 	}
+
+	return product, nil
 }
 
 type createProductReq struct {
@@ -239,19 +271,45 @@ type createProductReq struct {
 	CountryOfOrigin  string `json:"country_of_origin"`
 	CategoryName     string `json:"category_name"`
 
-	RegularPrice  float64  `json:"regular_price"`
-	SalePrice     *float64 `json:"sale_price"`
-	StockQuantity int      `json:"stock_quantity"`
+	RegularPrice      float64  `json:"regular_price"`
+	SalePrice         *float64 `json:"sale_price"`
+	StockQuantity     int      `json:"stock_quantity"`
+	LowStockThreshold *int     `json:"low_stock_threshold"`
 
 	WeightKg *float64 `json:"weight_kg"`
 	LengthCm *float64 `json:"length_cm"`
 	WidthCm  *float64 `json:"width_cm"`
 	HeightCm *float64 `json:"height_cm"`
 
+	// Variable products only; leave empty for a simple product.
+	Attributes []productAttributeReq `json:"attributes"`
+	Variants   []productVariantReq   `json:"variants"`
+
 	Woo  *wooSettings  `json:"woo"`
 	ONDC *ondcSettings `json:"ondc"`
 }
 
+// validate mirrors the DB constraints (NOT NULL name, non-negative price/stock CHECKs)
+// so callers get a 400 instead of a 500. Shared by CreateProduct and the bulk importer.
+func (r *createProductReq) validate() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return "name is required"
+	}
+	if r.RegularPrice < 0 {
+		return "regular_price must be non-negative"
+	}
+	if r.SalePrice != nil && *r.SalePrice < 0 {
+		return "sale_price must be non-negative"
+	}
+	if r.StockQuantity < 0 {
+		return "stock_quantity must be non-negative"
+	}
+	if r.LowStockThreshold != nil && *r.LowStockThreshold < 0 {
+		return "low_stock_threshold must be non-negative"
+	}
+	return validateVariants(r.Attributes, r.Variants)
+}
+
 type wooSettings struct {
 	Enabled           bool     `json:"enabled"`
 	CustomPrice       *float64 `json:"custom_price"`
@@ -268,3 +326,803 @@ type ondcSettings struct {
 	CityCode        string   `json:"city_code"`
 	Warranty        string   `json:"warranty"`
 }
+
+// ListProducts returns a paginated, org-scoped list of products.
+// Supports search, filtering and sorting; see productListFilter for the accepted query params.
+func ListProducts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		page := parsePagination(c)
+		filter, err := parseProductListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query := filter.apply(db.Model(&models.Product{}).Where("products.organization_id = ?", orgID)).
+			Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		var products []models.Product
+		if err := query.
+			Preload("Images", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+			Preload("LocalCategory").
+			Preload("Attributes", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+			Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Scopes(filter.order).
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Find(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+			return
+		}
+
+		out := make([]productResp, 0, len(products))
+		for _, p := range products {
+			out = append(out, toProductResp(p, nil))
+		}
+
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetProduct returns a single product with images, channel settings and stock preloaded.
+func GetProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		product, err := loadProductDetail(db, orgID, uint(productID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(http.StatusOK, toProductResp(product, orgChannelNames(db, orgID)))
+	}
+}
+
+// UpdateProduct applies a partial update (PUT and PATCH) to the core product fields
+// and its channel sub-records in a single transaction.
+// Expects JSON body of updateProductReq; omitted fields are left untouched.
+func UpdateProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var req updateProductReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid JSON data: %v", err)})
+			return
+		}
+		if msg := req.validate(); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+		var product models.Product
+		err = services.WithActor(db, uid).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id = ? AND organization_id = ?", productID, orgID).First(&product).Error; err != nil {
+				return err
+			}
+
+			if err := req.applyCore(tx, &product); err != nil {
+				return err
+			}
+			if err := tx.Save(&product).Error; err != nil {
+				return fmt.Errorf("failed to update product: %w", err)
+			}
+
+			if req.Attributes != nil {
+				if err := replaceProductAttributes(tx, product.ID, *req.Attributes); err != nil {
+					return err
+				}
+			}
+			if req.Woo != nil {
+				if err := upsertProductWoo(tx, product.ID, req.Woo); err != nil {
+					return err
+				}
+				if err := setProductChannelEnabled(tx, orgID, product.ID, "woocommerce", req.Woo.Enabled); err != nil {
+					return err
+				}
+			}
+			if req.ONDC != nil {
+				if err := upsertProductONDC(tx, product.ID, req.ONDC); err != nil {
+					return err
+				}
+				if req.ONDC.Enabled {
+					if _, err := ensureONDCChannel(tx, orgID); err != nil {
+						return err
+					}
+				}
+				if err := setProductChannelEnabled(tx, orgID, product.ID, "ondc", req.ONDC.Enabled); err != nil {
+					return err
+				}
+			}
+			// after Woo settings so the Woo type follows the variant set
+			if req.Variants != nil {
+				if err := syncProductVariants(tx, orgID, product.ID, *req.Variants, nil); err != nil {
+					return err
+				}
+			}
+			if len(req.ChannelOverrides) > 0 {
+				if err := saveChannelOverrides(tx, product.ID, req.ChannelOverrides); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			if errors.Is(err, errSKUTaken) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if isUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A product with this SKU already exists"})
+				return
+			}
+			log.Printf("update product %d for org %d failed: %v", productID, orgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+			return
+		}
+
+		go publishProductEvent(events.RoutingKeyProductUpdated, events.ProductUpdatedEvent{
+			BaseEvent: events.BaseEvent{
+				Event:     events.RoutingKeyProductUpdated,
+				Version:   1,
+				Timestamp: time.Now().UTC(),
+			},
+			ProductID:      product.ID,
+			OrganizationID: product.OrganizationID,
+		})
+
+		updated, err := loadProductDetail(db, orgID, product.ID)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "id": product.ID})
+			return
+		}
+		c.JSON(http.StatusOK, toProductResp(updated, orgChannelNames(db, orgID)))
+	}
+}
+
+// DeleteProduct soft-deletes a product (gorm.Model DeletedAt) and emits product.deleted.
+func DeleteProduct(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var product models.Product
+		if err := db.Where("id = ? AND organization_id = ?", productID, orgID).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		if err := db.Delete(&product).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+			return
+		}
+
+		go publishProductEvent(events.RoutingKeyProductDeleted, events.ProductDeletedEvent{
+			BaseEvent: events.BaseEvent{
+				Event:     events.RoutingKeyProductDeleted,
+				Version:   1,
+				Timestamp: time.Now().UTC(),
+			},
+			ProductID:      product.ID,
+			OrganizationID: product.OrganizationID,
+			SKU:            product.SKU,
+		})
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+/* ------------------------------
+   Request / response DTOs
+   ------------------------------ */
+
+// nullableFloat distinguishes an omitted field from an explicit null,
+// so PATCH bodies can clear optional values like sale_price.
+type nullableFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (n *nullableFloat) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	n.Value = &v
+	return nil
+}
+
+// nullableInt is nullableFloat for integer fields (e.g. low_stock_threshold).
+type nullableInt struct {
+	Set   bool
+	Value *int
+}
+
+func (n *nullableInt) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var v int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	n.Value = &v
+	return nil
+}
+
+type updateProductReq struct {
+	Name             *string `json:"name"`
+	ShortDescription *string `json:"short_description"`
+	Description      *string `json:"description"`
+	SKU              *string `json:"sku"`
+	Brand            *string `json:"brand"`
+	HSNCode          *string `json:"hsn_code"`
+	CountryOfOrigin  *string `json:"country_of_origin"`
+	CategoryName     *string `json:"category_name"`
+
+	RegularPrice      *float64      `json:"regular_price"`
+	SalePrice         nullableFloat `json:"sale_price"`
+	ManageStock       *bool         `json:"manage_stock"`
+	StockQuantity     *int          `json:"stock_quantity"`
+	LowStockThreshold nullableInt   `json:"low_stock_threshold"` // null: use the organization's
+
+	WeightKg nullableFloat `json:"weight_kg"`
+	LengthCm nullableFloat `json:"length_cm"`
+	WidthCm  nullableFloat `json:"width_cm"`
+	HeightCm nullableFloat `json:"height_cm"`
+
+	IsFeatured     *bool `json:"is_featured"`
+	ReviewsAllowed *bool `json:"reviews_allowed"`
+
+	// When present, replace the attribute set / variant list (variants are matched by id).
+	Attributes *[]productAttributeReq `json:"attributes"`
+	Variants   *[]productVariantReq   `json:"variants"`
+
+	Woo  *wooSettings  `json:"woo"`
+	ONDC *ondcSettings `json:"ondc"`
+
+	// Per-channel override JSON keyed by channel name (see services.ChannelOverride).
+	// An object replaces the stored override; null removes it.
+	ChannelOverrides map[string]json.RawMessage `json:"channel_overrides"`
+}
+
+// validate mirrors the DB CHECK constraints so callers get a 400 instead of a 500.
+func (r *updateProductReq) validate() string {
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		return "name cannot be empty"
+	}
+	if r.RegularPrice != nil && *r.RegularPrice < 0 {
+		return "regular_price must be non-negative"
+	}
+	if r.SalePrice.Value != nil && *r.SalePrice.Value < 0 {
+		return "sale_price must be non-negative"
+	}
+	if r.StockQuantity != nil && *r.StockQuantity < 0 {
+		return "stock_quantity must be non-negative"
+	}
+	if r.LowStockThreshold.Value != nil && *r.LowStockThreshold.Value < 0 {
+		return "low_stock_threshold must be non-negative"
+	}
+	if r.Variants != nil {
+		if r.Attributes == nil && len(*r.Variants) > 0 {
+			return "attributes are required when setting variants"
+		}
+		var attrs []productAttributeReq
+		if r.Attributes != nil {
+			attrs = *r.Attributes
+		}
+		if msg := validateVariants(attrs, *r.Variants); msg != "" {
+			return msg
+		}
+	}
+	for channel, raw := range r.ChannelOverrides {
+		if !services.KnownChannels[channel] {
+			return fmt.Sprintf("unknown channel %q in channel_overrides", channel)
+		}
+		if isJSONNull(raw) {
+			continue
+		}
+		if _, err := services.ParseChannelOverride(raw); err != nil {
+			return fmt.Sprintf("channel_overrides.%s: %v", channel, err)
+		}
+	}
+	return ""
+}
+
+// applyCore copies the provided core fields onto product.
+func (r *updateProductReq) applyCore(tx *gorm.DB, product *models.Product) error {
+	if r.Name != nil {
+		product.Name = *r.Name
+	}
+	if r.ShortDescription != nil {
+		product.ShortDescription = *r.ShortDescription
+	}
+	if r.Description != nil {
+		product.Description = *r.Description
+	}
+	if r.SKU != nil {
+		if *r.SKU != "" && *r.SKU != product.SKU {
+			taken, err := skuTakenInOrg(tx, product.OrganizationID, *r.SKU, product.ID, 0)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return errSKUTaken
+			}
+		}
+		product.SKU = *r.SKU
+	}
+	if r.Brand != nil {
+		product.Brand = *r.Brand
+	}
+	if r.HSNCode != nil {
+		product.HSNCode = *r.HSNCode
+	}
+	if r.CountryOfOrigin != nil {
+		product.CountryOfOrigin = *r.CountryOfOrigin
+	}
+	if r.CategoryName != nil {
+		categoryID, err := findOrCreateCategory(tx, *r.CategoryName)
+		if err != nil {
+			return fmt.Errorf("failed to resolve category: %w", err)
+		}
+		product.LocalCategoryID = categoryID
+		product.LocalCategory = nil
+	}
+	if r.RegularPrice != nil {
+		product.RegularPrice = *r.RegularPrice
+	}
+	if r.SalePrice.Set {
+		product.SalePrice = r.SalePrice.Value
+	}
+	if r.ManageStock != nil {
+		product.ManageStock = *r.ManageStock
+	}
+	if r.StockQuantity != nil && *r.StockQuantity != product.StockQuantity {
+		// through the stock service so per-location rows and the ledger follow
+		if _, err := services.SetStockTotal(tx, product.ID, nil, *r.StockQuantity, "product_edit", productStockRef(product.ID)); err != nil {
+			return fmt.Errorf("failed to set stock: %w", err)
+		}
+		product.StockQuantity = *r.StockQuantity
+	}
+	if r.LowStockThreshold.Set {
+		product.LowStockThreshold = r.LowStockThreshold.Value
+	}
+	if r.WeightKg.Set {
+		product.WeightKg = r.WeightKg.Value
+	}
+	if r.LengthCm.Set {
+		product.LengthCm = r.LengthCm.Value
+	}
+	if r.WidthCm.Set {
+		product.WidthCm = r.WidthCm.Value
+	}
+	if r.HeightCm.Set {
+		product.HeightCm = r.HeightCm.Value
+	}
+	if r.IsFeatured != nil {
+		product.IsFeatured = *r.IsFeatured
+	}
+	if r.ReviewsAllowed != nil {
+		product.ReviewsAllowed = *r.ReviewsAllowed
+	}
+	return nil
+}
+
+type productResp struct {
+	ID                uint     `json:"id"`
+	Name              string   `json:"name"`
+	ShortDescription  string   `json:"short_description"`
+	Description       string   `json:"description"`
+	SKU               string   `json:"sku"`
+	Brand             string   `json:"brand"`
+	HSNCode           string   `json:"hsn_code"`
+	CountryOfOrigin   string   `json:"country_of_origin"`
+	CategoryID        *uint    `json:"category_id"`
+	CategoryName      string   `json:"category_name,omitempty"`
+	RegularPrice      float64  `json:"regular_price"`
+	SalePrice         *float64 `json:"sale_price"`
+	ManageStock       bool     `json:"manage_stock"`
+	StockQuantity     int      `json:"stock_quantity"`
+	LowStockThreshold *int     `json:"low_stock_threshold"`
+	WeightKg          *float64 `json:"weight_kg"`
+	LengthCm          *float64 `json:"length_cm"`
+	WidthCm           *float64 `json:"width_cm"`
+	HeightCm          *float64 `json:"height_cm"`
+	IsFeatured        bool     `json:"is_featured"`
+	ReviewsAllowed    bool     `json:"reviews_allowed"`
+
+	Type          string                 `json:"type"`
+	Images        []productImageResp     `json:"images"`
+	Attributes    []productAttributeResp `json:"attributes,omitempty"`
+	Variants      []productVariantResp   `json:"variants,omitempty"`
+	Woo           *productWooResp        `json:"woo,omitempty"`
+	ONDC          *productONDCResp       `json:"ondc,omitempty"`
+	Channels      []productChannelResp   `json:"channels,omitempty"`
+	LocationStock []locationStockResp    `json:"location_stock,omitempty"`
+
+	ChannelOverrides map[string]json.RawMessage `json:"channel_overrides,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type productImageResp struct {
+	ID        uint   `json:"id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Src       string `json:"src"`
+	Alt       string `json:"alt"`
+	Position  int    `json:"position"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+type productWooResp struct {
+	WooProductID      *int64     `json:"woo_product_id"`
+	Status            string     `json:"status"`
+	Type              string     `json:"type"`
+	CatalogVisibility string     `json:"catalog_visibility"`
+	CustomPrice       *float64   `json:"custom_price"`
+	LastPublishedAt   *time.Time `json:"last_published_at"`
+}
+
+type productONDCResp struct {
+	ONDCItemID      string     `json:"ondc_item_id"`
+	FulfillmentType string     `json:"fulfillment_type"`
+	TimeToShip      string     `json:"time_to_ship"`
+	CityCode        string     `json:"city_code"`
+	Returnable      bool       `json:"returnable"`
+	Cancellable     bool       `json:"cancellable"`
+	Warranty        string     `json:"warranty"`
+	CustomPrice     *float64   `json:"custom_price"`
+	LastPublishedAt *time.Time `json:"last_published_at"`
+}
+
+type productChannelResp struct {
+	ChannelID       uint       `json:"channel_id"`
+	Channel         string     `json:"channel"`
+	IsEnabled       bool       `json:"is_enabled"`
+	LastPublishedAt *time.Time `json:"last_published_at"`
+}
+
+type locationStockResp struct {
+	LocationID uint       `json:"location_id"`
+	VariantID  *uint      `json:"variant_id,omitempty"`
+	StockQty   int        `json:"stock_qty"`
+	LastSynced *time.Time `json:"last_synced"`
+}
+
+func toProductResp(p models.Product, channelNames map[uint]string) productResp {
+	resp := productResp{
+		ID:                p.ID,
+		Name:              p.Name,
+		ShortDescription:  p.ShortDescription,
+		Description:       p.Description,
+		SKU:               p.SKU,
+		Brand:             p.Brand,
+		HSNCode:           p.HSNCode,
+		CountryOfOrigin:   p.CountryOfOrigin,
+		CategoryID:        p.LocalCategoryID,
+		RegularPrice:      p.RegularPrice,
+		SalePrice:         p.SalePrice,
+		ManageStock:       p.ManageStock,
+		StockQuantity:     p.StockQuantity,
+		LowStockThreshold: p.LowStockThreshold,
+		WeightKg:          p.WeightKg,
+		LengthCm:          p.LengthCm,
+		WidthCm:           p.WidthCm,
+		HeightCm:          p.HeightCm,
+		IsFeatured:        p.IsFeatured,
+		ReviewsAllowed:    p.ReviewsAllowed,
+		Images:            make([]productImageResp, 0, len(p.Images)),
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+	if p.LocalCategory != nil {
+		resp.CategoryName = p.LocalCategory.Name
+	}
+	resp.Type = "simple"
+	if len(p.Variants) > 0 {
+		resp.Type = "variable"
+		resp.Attributes = toAttributeResps(p.Attributes)
+		resp.Variants = toVariantResps(p.Variants, p.Images)
+	}
+	for _, img := range p.Images {
+		resp.Images = append(resp.Images, productImageResp{
+			ID:        img.ID,
+			VariantID: img.VariantID,
+			Src:       img.Src,
+			Alt:       img.Alt,
+			Position:  img.Position,
+			IsPrimary: img.IsPrimary,
+		})
+	}
+	if p.ProductWoo.ID != 0 {
+		w := p.ProductWoo
+		resp.Woo = &productWooResp{
+			WooProductID:      w.WooProductID,
+			Status:            w.Status,
+			Type:              w.Type,
+			CatalogVisibility: w.CatalogVisibility,
+			LastPublishedAt:   w.LastPublishedAt,
+		}
+		if w.CustomPriceEnabled {
+			resp.Woo.CustomPrice = w.CustomPriceValue
+		}
+	}
+	if p.ProductONDC.ID != 0 {
+		o := p.ProductONDC
+		resp.ONDC = &productONDCResp{
+			ONDCItemID:      o.ONDCItemID,
+			FulfillmentType: o.FulfillmentType,
+			TimeToShip:      o.TimeToShip,
+			CityCode:        o.CityCode,
+			Returnable:      o.Returnable,
+			Cancellable:     o.Cancellable,
+			Warranty:        o.Warranty,
+			LastPublishedAt: o.LastPublishedAt,
+		}
+		if o.CustomPriceEnabled {
+			resp.ONDC.CustomPrice = o.CustomPriceValue
+		}
+	}
+	for _, pc := range p.ProductChannels {
+		resp.Channels = append(resp.Channels, productChannelResp{
+			ChannelID:       pc.ChannelID,
+			Channel:         channelNames[pc.ChannelID],
+			IsEnabled:       pc.IsEnabled,
+			LastPublishedAt: pc.LastPublishedAt,
+		})
+	}
+	for _, ls := range p.LocationStock {
+		resp.LocationStock = append(resp.LocationStock, locationStockResp{
+			LocationID: ls.LocationID,
+			VariantID:  ls.VariantID,
+			StockQty:   ls.StockQty,
+			LastSynced: ls.LastSynced,
+		})
+	}
+	for _, o := range p.ChannelOverrides {
+		if len(o.Data) == 0 {
+			continue
+		}
+		if resp.ChannelOverrides == nil {
+			resp.ChannelOverrides = make(map[string]json.RawMessage)
+		}
+		resp.ChannelOverrides[o.Channel] = json.RawMessage(o.Data)
+	}
+	return resp
+}
+
+/* ------------------------------
+   Helper functions
+   ------------------------------ */
+
+// loadProductDetail fetches an org-scoped product with every relation the detail view needs.
+func loadProductDetail(db *gorm.DB, orgID, productID uint) (models.Product, error) {
+	var product models.Product
+	err := db.
+		Preload("Images", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("LocalCategory").
+		Preload("Attributes", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Preload("ProductWoo").
+		Preload("ProductONDC").
+		Preload("ProductChannels").
+		Preload("LocationStock").
+		Preload("ChannelOverrides").
+		Where("id = ? AND organization_id = ?", productID, orgID).
+		First(&product).Error
+	if err != nil {
+		return product, err
+	}
+
+	return product, nil
+}
+
+// orgChannelNames maps channel IDs to names so ProductChannel pivots can be labelled.
+func orgChannelNames(db *gorm.DB, orgID uint) map[uint]string {
+	var channels []models.Channel
+	names := make(map[uint]string)
+	if err := db.Where("organization_id = ?", orgID).Find(&channels).Error; err != nil {
+		return names
+	}
+	for _, ch := range channels {
+		names[ch.ID] = ch.Name
+	}
+	return names
+}
+
+// findOrCreateCategory resolves a category by name, creating it when missing.
+// An empty name clears the category.
+func findOrCreateCategory(tx *gorm.DB, name string) (*uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	var cat models.Category
+	err := tx.Where("name = ?", name).First(&cat).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		cat = models.Category{Name: name}
+		if err := tx.Create(&cat).Error; err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &cat.ID, nil
+}
+
+// upsertProductWoo creates or updates the 1-1 ProductWoo settings row.
+func upsertProductWoo(tx *gorm.DB, productID uint, s *wooSettings) error {
+	var woo models.ProductWoo
+	err := tx.Where("product_id = ?", productID).First(&woo).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		woo = models.ProductWoo{ProductID: productID, Type: "simple"}
+	}
+	if s.Enabled {
+		woo.Status = "publish"
+	} else {
+		woo.Status = "draft"
+	}
+	if s.CatalogVisibility != "" {
+		woo.CatalogVisibility = s.CatalogVisibility
+	}
+	woo.CustomPriceEnabled = s.CustomPrice != nil
+	woo.CustomPriceValue = s.CustomPrice
+	if err := tx.Save(&woo).Error; err != nil {
+		return fmt.Errorf("failed to save WooCommerce settings: %w", err)
+	}
+	return nil
+}
+
+// upsertProductONDC creates or updates the 1-1 ProductONDC settings row.
+func upsertProductONDC(tx *gorm.DB, productID uint, s *ondcSettings) error {
+	var ondc models.ProductONDC
+	err := tx.Where("product_id = ?", productID).First(&ondc).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ondc = models.ProductONDC{ProductID: productID}
+	}
+	ondc.FulfillmentType = s.FulfillmentType
+	ondc.TimeToShip = s.TimeToShip
+	ondc.CityCode = s.CityCode
+	ondc.Returnable = s.Returnable
+	ondc.Cancellable = s.Cancellable
+	ondc.Warranty = s.Warranty
+	ondc.CustomPriceEnabled = s.CustomPrice != nil
+	ondc.CustomPriceValue = s.CustomPrice
+	if err := tx.Save(&ondc).Error; err != nil {
+		return fmt.Errorf("failed to save ONDC settings: %w", err)
+	}
+	return nil
+}
+
+// saveChannelOverrides replaces or (for null) removes the product's override JSON per channel.
+// The overrides must already have passed updateProductReq.validate.
+func saveChannelOverrides(tx *gorm.DB, productID uint, overrides map[string]json.RawMessage) error {
+	for channel, raw := range overrides {
+		if isJSONNull(raw) {
+			if err := tx.Where("product_id = ? AND channel = ?", productID, channel).
+				Delete(&models.ProductChannelOverride{}).Error; err != nil {
+				return fmt.Errorf("failed to remove %s override: %w", channel, err)
+			}
+			continue
+		}
+		var row models.ProductChannelOverride
+		err := tx.Where("product_id = ? AND channel = ?", productID, channel).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			row = models.ProductChannelOverride{ProductID: productID, Channel: channel}
+		} else if err != nil {
+			return err
+		}
+		row.Data = datatypes.JSON(raw)
+		if err := tx.Save(&row).Error; err != nil {
+			return fmt.Errorf("failed to save %s override: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	return trimmed == "" || trimmed == "null"
+}
+
+// setProductChannelEnabled toggles the ProductChannel pivot for the org's channel of the given name.
+// It is a no-op when the org has not configured that channel yet.
+func setProductChannelEnabled(tx *gorm.DB, orgID, productID uint, channelName string, enabled bool) error {
+	var channel models.Channel
+	if err := tx.Where("name = ? AND organization_id = ?", channelName, orgID).First(&channel).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var pc models.ProductChannel
+	err := tx.Where("product_id = ? AND channel_id = ?", productID, channel.ID).First(&pc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pc = models.ProductChannel{ProductID: productID, ChannelID: channel.ID}
+	} else if err != nil {
+		return err
+	}
+	pc.IsEnabled = enabled
+	return tx.Save(&pc).Error
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation (e.g. ux_product_org_live_sku).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// publishProductEvent publishes a product lifecycle event (best-effort, logs on failure).
+func publishProductEvent(routingKey string, ev interface{}) {
+	if err := events.Publish(routingKey, ev); err != nil {
+		log.Printf("Failed to publish %s event: %v", routingKey, err)
+	}
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+)
+
+const (
+	importMaxRows   = 50000
+	importBatchSize = 100
+	importMaxBytes  = 32 << 20
+)
+
+// importFields are the product fields a spreadsheet column can be mapped to,
+// with the header aliases used to suggest a mapping.
+var importFields = []struct {
+	Field   string
+	Aliases []string
+}{
+	{"name", []string{"name", "productname", "title", "product"}},
+	{"sku", []string{"sku", "itemcode", "productcode", "code"}},
+	{"short_description", []string{"shortdescription", "summary"}},
+	{"description", []string{"description", "details", "longdescription"}},
+	{"brand", []string{"brand", "manufacturer"}},
+	{"hsn_code", []string{"hsncode", "hsn", "hsnsac"}},
+	{"country_of_origin", []string{"countryoforigin", "origin", "country"}},
+	{"category_name", []string{"categoryname", "category", "categories"}},
+	{"regular_price", []string{"regularprice", "price", "mrp", "sellingprice"}},
+	{"sale_price", []string{"saleprice", "discountprice", "offerprice"}},
+	{"stock_quantity", []string{"stockquantity", "stock", "quantity", "qty", "inventory"}},
+	{"weight_kg", []string{"weightkg", "weight"}},
+	{"length_cm", []string{"lengthcm", "length"}},
+	{"width_cm", []string{"widthcm", "width"}},
+	{"height_cm", []string{"heightcm", "height"}},
+}
+
+// importRowError is one line of the per-row validation report. Row is the 1-based spreadsheet row.
+// Woo catalog imports report WooProductID instead of a row.
+type importRowError struct {
+	Row          int      `json:"row,omitempty"`
+	WooProductID int64    `json:"woo_product_id,omitempty"`
+	SKU          string   `json:"sku,omitempty"`
+	Errors       []string `json:"errors"`
+}
+
+type importRow struct {
+	Row int
+	Req createProductReq
+}
+
+type importJobResp struct {
+	ID            uint             `json:"id"`
+	Source        string           `json:"source"`
+	Status        string           `json:"status"`
+	Filename      string           `json:"filename"`
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	CreatedCount  int              `json:"created_count"`
+	FailedCount   int              `json:"failed_count"`
+	MatchedCount  int              `json:"matched_count"`
+	ConflictCount int              `json:"conflict_count"`
+	RowErrors     []importRowError `json:"row_errors"`
+	ErrorMessage  string           `json:"error_message,omitempty"`
+	StartedAt     *time.Time       `json:"started_at"`
+	FinishedAt    *time.Time       `json:"finished_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// PreviewProductImport is the column-mapping step: it reads the uploaded sheet and returns
+// its headers, a suggested field mapping and a few sample rows.
+// Expects Multipart form with "file" (.csv or .xlsx).
+func PreviewProductImport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := getOrgIDFromContext(c); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'file' field"})
+			return
+		}
+		_, headers, records, err := readSpreadsheet(fh)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sample := records
+		if len(sample) > 5 {
+			sample = sample[:5]
+		}
+		fields := make([]string, 0, len(importFields))
+		for _, f := range importFields {
+			fields = append(fields, f.Field)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"headers":           headers,
+			"fields":            fields,
+			"suggested_mapping": suggestImportMapping(headers),
+			"sample_rows":       sample,
+			"total_rows":        len(records),
+		})
+	}
+}
+
+// ImportProducts validates every row of an uploaded sheet against the createProductReq rules.
+// With dry_run=true it only returns the per-row report; otherwise valid rows are committed
+// in batches by a background job whose progress can be polled via GetImportJob.
+// Expects Multipart form:
+// - "file": .csv or .xlsx
+// - "mapping": JSON object of field -> column header (defaults to the suggested mapping)
+// - "dry_run": "true" to validate only
+func ImportProducts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := c.Request.ParseMultipartForm(importMaxBytes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+			return
+		}
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing 'file' field"})
+			return
+		}
+		format, headers, records, err := readSpreadsheet(fh)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mapping := suggestImportMapping(headers)
+		if raw := c.Request.FormValue("mapping"); raw != "" {
+			mapping = map[string]string{}
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid mapping: %v", err)})
+				return
+			}
+		}
+		if msg := validateImportMapping(mapping, headers); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		rows, rowErrors := buildImportRows(headers, records, mapping)
+		rows, skuErrors, err := checkImportSKUs(db, orgID, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error checking SKUs"})
+			return
+		}
+		rowErrors = append(rowErrors, skuErrors...)
+
+		dryRun, _ := strconv.ParseBool(c.Request.FormValue("dry_run"))
+		if dryRun {
+			c.JSON(http.StatusOK, gin.H{
+				"dry_run":      true,
+				"total_rows":   len(records),
+				"valid_rows":   len(rows),
+				"invalid_rows": len(rowErrors),
+				"row_errors":   rowErrors,
+			})
+			return
+		}
+
+		mappingJSON, _ := json.Marshal(mapping)
+		errorsJSON, _ := json.Marshal(rowErrors)
+		job := models.ImportJob{
+			OrganizationID: orgID,
+			UserID:         uid,
+			Source:         format,
+			Status:         "pending",
+			Filename:       fh.Filename,
+			Mapping:        datatypes.JSON(mappingJSON),
+			TotalRows:      len(records),
+			ProcessedRows:  len(rowErrors),
+			FailedCount:    len(rowErrors),
+			RowErrors:      datatypes.JSON(errorsJSON),
+		}
+		if err := db.Create(&job).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import job"})
+			return
+		}
+
+		go runProductImport(db, job.ID, orgID, rows, rowErrors)
+
+		c.JSON(http.StatusAccepted, toImportJobResp(job))
+	}
+}
+
+// GetImportJob returns the progress of an import job.
+func GetImportJob(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var job models.ImportJob
+		if err := db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).First(&job).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, toImportJobResp(job))
+	}
+}
+
+/* ------------------------------
+   Background job
+   ------------------------------ */
+
+// failImportJobOnPanic, deferred by an import goroutine, marks its job failed if it panics
+// so the job doesn't sit in running forever.
+func failImportJobOnPanic(db *gorm.DB, jobID uint) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	log.Printf("import job %d panicked: %v", jobID, r)
+	db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":        "failed",
+		"error_message": fmt.Sprintf("import aborted: %v", r),
+		"finished_at":   time.Now(),
+	})
+}
+
+// runProductImport commits rows in batches. Each row runs under its own savepoint so one bad row
+// (e.g. a SKU created concurrently) doesn't discard the rest of its batch.
+func runProductImport(db *gorm.DB, jobID, orgID uint, rows []importRow, rowErrors []importRowError) {
+	defer failImportJobOnPanic(db, jobID)
+	started := time.Now()
+	db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":     "running",
+		"started_at": started,
+	})
+
+	processed := len(rowErrors)
+	created := 0
+	var createdIDs []uint
+	var batchErr error
+
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var batchIDs []uint
+		var batchErrors []importRowError
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i, row := range rows[start:end] {
+				sp := fmt.Sprintf("import_row_%d", i)
+				if err := tx.SavePoint(sp).Error; err != nil {
+					return err
+				}
+				product, err := createProductRecords(tx, orgID, row.Req, nil)
+				if err != nil {
+					if rbErr := tx.RollbackTo(sp).Error; rbErr != nil {
+						return rbErr
+					}
+					msg := err.Error()
+					if isUniqueViolation(err) {
+						msg = "sku already exists"
+					}
+					batchErrors = append(batchErrors, importRowError{Row: row.Row, SKU: row.Req.SKU, Errors: []string{msg}})
+					continue
+				}
+				batchIDs = append(batchIDs, product.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			// whole batch rolled back
+			batchErr = err
+			for _, row := range rows[start:end] {
+				batchErrors = append(batchErrors, importRowError{Row: row.Row, SKU: row.Req.SKU, Errors: []string{err.Error()}})
+			}
+			batchIDs = nil
+		}
+
+		processed += end - start
+		created += len(batchIDs)
+		createdIDs = append(createdIDs, batchIDs...)
+		rowErrors = append(rowErrors, batchErrors...)
+
+		errorsJSON, _ := json.Marshal(rowErrors)
+		db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+			"processed_rows": processed,
+			"created_count":  created,
+			"failed_count":   len(rowErrors),
+			"row_errors":     datatypes.JSON(errorsJSON),
+		})
+	}
+
+	final := map[string]interface{}{
+		"status":      "completed",
+		"finished_at": time.Now(),
+	}
+	if batchErr != nil {
+		final["error_message"] = batchErr.Error()
+		if created == 0 {
+			final["status"] = "failed"
+		}
+	}
+	db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(final)
+	log.Printf("import job %d finished: %d created, %d failed in %s", jobID, created, len(rowErrors), time.Since(started))
+
+	for _, id := range createdIDs {
+		publishProductEvent(events.RoutingKeyProductCreated, events.ProductCreatedEvent{
+			BaseEvent: events.BaseEvent{
+				Event:     events.RoutingKeyProductCreated,
+				Version:   1,
+				Timestamp: time.Now().UTC(),
+			},
+			ProductID:      id,
+			OrganizationID: orgID,
+		})
+	}
+}
+
+/* ------------------------------
+   Parsing & validation
+   ------------------------------ */
+
+// readSpreadsheet reads the first sheet of a .csv or .xlsx upload into a header row and data rows.
+// Fully blank rows are skipped.
+func readSpreadsheet(fh *multipart.FileHeader) (string, []string, [][]string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer f.Close()
+
+	var all [][]string
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), ".")
+	switch format {
+	case "csv":
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("invalid CSV: %w", err)
+			}
+			all = append(all, rec)
+		}
+	case "xlsx":
+		book, err := excelize.OpenReader(f)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+		defer book.Close()
+		sheets := book.GetSheetList()
+		if len(sheets) == 0 {
+			return "", nil, nil, errors.New("XLSX has no sheets")
+		}
+		if all, err = book.GetRows(sheets[0]); err != nil {
+			return "", nil, nil, fmt.Errorf("invalid XLSX: %w", err)
+		}
+	default:
+		return "", nil, nil, errors.New("unsupported file type (use .csv or .xlsx)")
+	}
+
+	var rows [][]string
+	for _, rec := range all {
+		blank := true
+		for _, cell := range rec {
+			if strings.TrimSpace(cell) != "" {
+				blank = false
+				break
+			}
+		}
+		if !blank {
+			rows = append(rows, rec)
+		}
+	}
+	if len(rows) == 0 {
+		return "", nil, nil, errors.New("file is empty")
+	}
+	if len(rows)-1 > importMaxRows {
+		return "", nil, nil, fmt.Errorf("too many rows (max %d)", importMaxRows)
+	}
+
+	headers := rows[0]
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff") // Excel CSV BOM
+	}
+	for i := range headers {
+		headers[i] = strings.TrimSpace(headers[i])
+	}
+	return format, headers, rows[1:], nil
+}
+
+// normalizeHeader lowercases and strips everything but letters/digits: "Stock Qty." -> "stockqty".
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// suggestImportMapping guesses field -> header from the header names.
+func suggestImportMapping(headers []string) map[string]string {
+	byNorm := make(map[string]string, len(headers))
+	for _, h := range headers {
+		if n := normalizeHeader(h); n != "" {
+			if _, exists := byNorm[n]; !exists {
+				byNorm[n] = h
+			}
+		}
+	}
+	mapping := make(map[string]string)
+	used := make(map[string]bool)
+	for _, f := range importFields {
+		for _, alias := range f.Aliases {
+			if h, ok := byNorm[alias]; ok && !used[h] {
+				mapping[f.Field] = h
+				used[h] = true
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+func validateImportMapping(mapping map[string]string, headers []string) string {
+	known := make(map[string]bool, len(importFields))
+	for _, f := range importFields {
+		known[f.Field] = true
+	}
+	present := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		present[h] = true
+	}
+	for field, header := range mapping {
+		if !known[field] {
+			return fmt.Sprintf("unknown field %q in mapping", field)
+		}
+		if header != "" && !present[header] {
+			return fmt.Sprintf("column %q (mapped to %s) not found in file", header, field)
+		}
+	}
+	if mapping["name"] == "" {
+		return "mapping must include a column for name"
+	}
+	return ""
+}
+
+// buildImportRows converts data rows into createProductReqs and validates each with the same
+// rules as CreateProduct. Rows with errors are reported and left out.
+func buildImportRows(headers []string, records [][]string, mapping map[string]string) ([]importRow, []importRowError) {
+	colIdx := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if _, exists := colIdx[h]; !exists {
+			colIdx[h] = i
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []importRowError
+	for i, rec := range records {
+		rowNum := i + 2 // header is row 1
+		cell := func(field string) string {
+			idx, ok := colIdx[mapping[field]]
+			if !ok || mapping[field] == "" || idx >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[idx])
+		}
+
+		var errs []string
+		parseFloat := func(field string) *float64 {
+			raw := strings.ReplaceAll(cell(field), ",", "")
+			if raw == "" {
+				return nil
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a number", field, cell(field)))
+				return nil
+			}
+			return &v
+		}
+
+		req := createProductReq{
+			Name:             cell("name"),
+			ShortDescription: cell("short_description"),
+			Description:      cell("description"),
+			SKU:              cell("sku"),
+			Brand:            cell("brand"),
+			HSNCode:          cell("hsn_code"),
+			CountryOfOrigin:  strings.ToUpper(cell("country_of_origin")),
+			CategoryName:     cell("category_name"),
+			SalePrice:        parseFloat("sale_price"),
+			WeightKg:         parseFloat("weight_kg"),
+			LengthCm:         parseFloat("length_cm"),
+			WidthCm:          parseFloat("width_cm"),
+			HeightCm:         parseFloat("height_cm"),
+		}
+		if req.CountryOfOrigin == "" {
+			req.CountryOfOrigin = "IN"
+		} else if len(req.CountryOfOrigin) != 2 {
+			errs = append(errs, "country_of_origin must be a 2-letter code")
+		}
+		if p := parseFloat("regular_price"); p != nil {
+			req.RegularPrice = *p
+		}
+		if raw := cell("stock_quantity"); raw != "" {
+			qty, err := strconv.Atoi(raw)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("stock_quantity: %q is not a whole number", raw))
+			}
+			req.StockQuantity = qty
+		}
+
+		if msg := req.validate(); msg != "" {
+			errs = append(errs, msg)
+		}
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, importRowError{Row: rowNum, SKU: req.SKU, Errors: errs})
+			continue
+		}
+		rows = append(rows, importRow{Row: rowNum, Req: req})
+	}
+	return rows, rowErrors
+}
+
+// checkImportSKUs enforces ux_product_org_live_sku up front: SKUs must be unique within the
+// file and must not already be used by a live product or variant of the organization (a
+// deleted product's SKU is free again).
+func checkImportSKUs(db *gorm.DB, orgID uint, rows []importRow) ([]importRow, []importRowError, error) {
+	skus := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if r.Req.SKU != "" {
+			skus = append(skus, r.Req.SKU)
+		}
+	}
+
+	existing := make(map[string]bool)
+	for start := 0; start < len(skus); start += 1000 {
+		end := start + 1000
+		if end > len(skus) {
+			end = len(skus)
+		}
+		var found []string
+		if err := db.Model(&models.Product{}).
+			Where("organization_id = ? AND sku IN ?", orgID, skus[start:end]).
+			Pluck("sku", &found).Error; err != nil {
+			return nil, nil, err
+		}
+		var foundVariants []string
+		if err := db.Model(&models.ProductVariant{}).
+			Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+			Where("products.organization_id = ? AND product_variants.sku IN ?", orgID, skus[start:end]).
+			Pluck("product_variants.sku", &foundVariants).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, s := range append(found, foundVariants...) {
+			existing[s] = true
+		}
+	}
+
+	var valid []importRow
+	var rowErrors []importRowError
+	seen := make(map[string]int)
+	for _, r := range rows {
+		sku := r.Req.SKU
+		switch {
+		case sku == "":
+			valid = append(valid, r)
+		case existing[sku]:
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, SKU: sku, Errors: []string{"sku already exists in this organization"}})
+		case seen[sku] != 0:
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, SKU: sku, Errors: []string{fmt.Sprintf("duplicate sku (first seen on row %d)", seen[sku])}})
+		default:
+			seen[sku] = r.Row
+			valid = append(valid, r)
+		}
+	}
+	return valid, rowErrors, nil
+}
+
+func toImportJobResp(job models.ImportJob) importJobResp {
+	resp := importJobResp{
+		ID:            job.ID,
+		Source:        job.Source,
+		Status:        job.Status,
+		Filename:      job.Filename,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		CreatedCount:  job.CreatedCount,
+		FailedCount:   job.FailedCount,
+		MatchedCount:  job.MatchedCount,
+		ConflictCount: job.ConflictCount,
+		RowErrors:     []importRowError{},
+		ErrorMessage:  job.ErrorMessage,
+		StartedAt:     job.StartedAt,
+		FinishedAt:    job.FinishedAt,
+		CreatedAt:     job.CreatedAt,
+	}
+	if len(job.RowErrors) > 0 {
+		_ = json.Unmarshal(job.RowErrors, &resp.RowErrors)
+	}
+	return resp
+}
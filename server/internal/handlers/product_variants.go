@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+// productAttributeReq defines a variation axis, e.g. {"name": "Size", "options": ["S","M","L"]}.
+type productAttributeReq struct {
+	Name    string   `json:"name"`
+	Options []string `json:"options"`
+}
+
+// productVariantReq describes one variant of a variable product.
+// On create, image_indexes point into the uploaded "images" files;
+// on update, image_ids point at existing ProductImage rows of the parent.
+type productVariantReq struct {
+	ID            *uint             `json:"id"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	RegularPrice  float64           `json:"regular_price"`
+	SalePrice     *float64          `json:"sale_price"`
+	StockQuantity int               `json:"stock_quantity"`
+	ManageStock   *bool             `json:"manage_stock"`
+	ImageIndexes  []int             `json:"image_indexes"`
+	ImageIDs      []uint            `json:"image_ids"`
+}
+
+// validateVariants checks that every variant uses declared attribute options (an empty
+// value means any option, like Woo's "Any ..." variations), has non-negative price/stock
+// and that no two variants share a combination or SKU.
+func validateVariants(attrs []productAttributeReq, variants []productVariantReq) string {
+	if len(variants) == 0 {
+		return ""
+	}
+	if len(attrs) == 0 {
+		return "variants require at least one attribute"
+	}
+
+	options := make(map[string]map[string]bool, len(attrs))
+	for _, a := range attrs {
+		name := strings.TrimSpace(a.Name)
+		if name == "" || len(a.Options) == 0 {
+			return "each attribute needs a name and at least one option"
+		}
+		if _, dup := options[name]; dup {
+			return fmt.Sprintf("duplicate attribute %q", name)
+		}
+		options[name] = make(map[string]bool, len(a.Options))
+		for _, o := range a.Options {
+			options[name][o] = true
+		}
+	}
+
+	seenCombo := make(map[string]bool, len(variants))
+	seenSKU := make(map[string]bool, len(variants))
+	for i, v := range variants {
+		if v.RegularPrice < 0 || (v.SalePrice != nil && *v.SalePrice < 0) {
+			return fmt.Sprintf("variant %d: prices must be non-negative", i)
+		}
+		if v.StockQuantity < 0 {
+			return fmt.Sprintf("variant %d: stock_quantity must be non-negative", i)
+		}
+		if len(v.Attributes) != len(options) {
+			return fmt.Sprintf("variant %d: must set a value for every attribute", i)
+		}
+		keyParts := make([]string, 0, len(attrs))
+		for _, a := range attrs {
+			val, ok := v.Attributes[strings.TrimSpace(a.Name)]
+			if !ok || (val != "" && !options[strings.TrimSpace(a.Name)][val]) {
+				return fmt.Sprintf("variant %d: invalid value for attribute %q", i, a.Name)
+			}
+			keyParts = append(keyParts, val)
+		}
+		key := strings.Join(keyParts, "\x00")
+		if seenCombo[key] {
+			return fmt.Sprintf("variant %d: duplicate attribute combination", i)
+		}
+		seenCombo[key] = true
+
+		if sku := strings.TrimSpace(v.SKU); sku != "" {
+			if seenSKU[sku] {
+				return fmt.Sprintf("variant %d: duplicate sku %q", i, sku)
+			}
+			seenSKU[sku] = true
+		}
+	}
+	return ""
+}
+
+// replaceProductAttributes swaps the attribute set of a product.
+func replaceProductAttributes(tx *gorm.DB, productID uint, attrs []productAttributeReq) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductAttribute{}).Error; err != nil {
+		return err
+	}
+	for i, a := range attrs {
+		opts, _ := json.Marshal(a.Options)
+		attr := models.ProductAttribute{
+			ProductID: productID,
+			Name:      strings.TrimSpace(a.Name),
+			Options:   datatypes.JSON(opts),
+			Position:  i,
+		}
+		if err := tx.Create(&attr).Error; err != nil {
+			return fmt.Errorf("failed to save attribute %q: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// syncProductVariants upserts variants by id and removes any variant not present in the list.
+// images maps image_indexes (create) to freshly inserted ProductImage rows.
+func syncProductVariants(tx *gorm.DB, orgID, productID uint, variants []productVariantReq, images []models.ProductImage) error {
+	keep := make([]uint, 0, len(variants))
+	for i, v := range variants {
+		sku := strings.TrimSpace(v.SKU)
+		var excludeVariant uint
+		if v.ID != nil {
+			excludeVariant = *v.ID
+		}
+		if sku != "" {
+			taken, err := skuTakenInOrg(tx, orgID, sku, 0, excludeVariant)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("variant %d: %w", i, errSKUTaken)
+			}
+		}
+
+		var variant models.ProductVariant
+		if v.ID != nil {
+			if err := tx.Where("id = ? AND product_id = ?", *v.ID, productID).First(&variant).Error; err != nil {
+				return fmt.Errorf("variant %d: %w", i, err)
+			}
+		} else {
+			variant = models.ProductVariant{ProductID: productID, ManageStock: true}
+		}
+
+		attrs, _ := json.Marshal(v.Attributes)
+		variant.SKU = sku
+		variant.Attributes = datatypes.JSON(attrs)
+		variant.RegularPrice = v.RegularPrice
+		variant.SalePrice = v.SalePrice
+		if variant.ID != 0 && v.StockQuantity != variant.StockQuantity {
+			if _, err := services.SetStockTotal(tx, productID, &variant.ID, v.StockQuantity, "product_edit", productStockRef(productID)); err != nil {
+				return fmt.Errorf("variant %d: failed to set stock: %w", i, err)
+			}
+		}
+		variant.StockQuantity = v.StockQuantity
+		if v.ManageStock != nil {
+			variant.ManageStock = *v.ManageStock
+		}
+		isNew := variant.ID == 0
+		if err := tx.Save(&variant).Error; err != nil {
+			return fmt.Errorf("failed to save variant %d: %w", i, err)
+		}
+		if isNew {
+			if err := services.RecordOpeningStock(tx, productID, &variant.ID, variant.StockQuantity, productStockRef(productID)); err != nil {
+				return fmt.Errorf("variant %d: %w", i, err)
+			}
+		}
+		keep = append(keep, variant.ID)
+
+		imageIDs := append([]uint(nil), v.ImageIDs...)
+		for _, idx := range v.ImageIndexes {
+			if idx < 0 || idx >= len(images) {
+				return fmt.Errorf("variant %d: image index %d out of range", i, idx)
+			}
+			imageIDs = append(imageIDs, images[idx].ID)
+		}
+		if len(imageIDs) > 0 {
+			if err := tx.Model(&models.ProductImage{}).
+				Where("product_id = ? AND id IN ?", productID, imageIDs).
+				Update("variant_id", variant.ID).Error; err != nil {
+				return fmt.Errorf("failed to link variant %d images: %w", i, err)
+			}
+		}
+	}
+
+	stale := tx.Where("product_id = ?", productID)
+	if len(keep) > 0 {
+		stale = stale.Where("id NOT IN ?", keep)
+	}
+	if err := stale.Delete(&models.ProductVariant{}).Error; err != nil {
+		return err
+	}
+
+	// Images of removed variants fall back to the parent gallery.
+	unlink := tx.Model(&models.ProductImage{}).Where("product_id = ? AND variant_id IS NOT NULL", productID)
+	if len(keep) > 0 {
+		unlink = unlink.Where("variant_id NOT IN ?", keep)
+	}
+	if err := unlink.Update("variant_id", nil).Error; err != nil {
+		return err
+	}
+
+	// Keep the Woo product type in step with the variant set.
+	productType := "simple"
+	if len(variants) > 0 {
+		productType = "variable"
+	}
+	return tx.Model(&models.ProductWoo{}).Where("product_id = ?", productID).Update("type", productType).Error
+}
+
+var errSKUTaken = errors.New("sku already used by another product or variant")
+
+// skuTakenInOrg reports whether sku is already used by a product or a variant in the org.
+// excludeProductID / excludeVariantID skip the row being edited (0 excludes nothing).
+func skuTakenInOrg(tx *gorm.DB, orgID uint, sku string, excludeProductID, excludeVariantID uint) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.Product{}).
+		Where("organization_id = ? AND sku = ? AND id <> ?", orgID, sku, excludeProductID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := tx.Model(&models.ProductVariant{}).
+		Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+		Where("products.organization_id = ? AND product_variants.sku = ? AND product_variants.id <> ?", orgID, sku, excludeVariantID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+type productAttributeResp struct {
+	ID       uint     `json:"id"`
+	Name     string   `json:"name"`
+	Options  []string `json:"options"`
+	Position int      `json:"position"`
+}
+
+type productVariantResp struct {
+	ID             uint              `json:"id"`
+	SKU            string            `json:"sku"`
+	Attributes     map[string]string `json:"attributes"`
+	RegularPrice   float64           `json:"regular_price"`
+	SalePrice      *float64          `json:"sale_price"`
+	ManageStock    bool              `json:"manage_stock"`
+	StockQuantity  int               `json:"stock_quantity"`
+	WooVariationID *int64            `json:"woo_variation_id"`
+	ImageIDs       []uint            `json:"image_ids"`
+}
+
+func toAttributeResps(attrs []models.ProductAttribute) []productAttributeResp {
+	out := make([]productAttributeResp, 0, len(attrs))
+	for _, a := range attrs {
+		var opts []string
+		_ = json.Unmarshal(a.Options, &opts)
+		out = append(out, productAttributeResp{ID: a.ID, Name: a.Name, Options: opts, Position: a.Position})
+	}
+	return out
+}
+
+func toVariantResps(variants []models.ProductVariant, images []models.ProductImage) []productVariantResp {
+	out := make([]productVariantResp, 0, len(variants))
+	for _, v := range variants {
+		var attrs map[string]string
+		_ = json.Unmarshal(v.Attributes, &attrs)
+		resp := productVariantResp{
+			ID:             v.ID,
+			SKU:            v.SKU,
+			Attributes:     attrs,
+			RegularPrice:   v.RegularPrice,
+			SalePrice:      v.SalePrice,
+			ManageStock:    v.ManageStock,
+			StockQuantity:  v.StockQuantity,
+			WooVariationID: v.WooVariationID,
+			ImageIDs:       []uint{},
+		}
+		for _, img := range images {
+			if img.VariantID != nil && *img.VariantID == v.ID {
+				resp.ImageIDs = append(resp.ImageIDs, img.ID)
+			}
+		}
+		out = append(out, resp)
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/models"
+)
+
+type publishLogResp struct {
+	ID                uint            `json:"id"`
+	ProductID         uint            `json:"product_id"`
+	ProductName       string          `json:"product_name,omitempty"`
+	ProductSKU        string          `json:"product_sku,omitempty"`
+	Channel           string          `json:"channel"`
+	Success           bool            `json:"success"`
+	ChannelResourceID string          `json:"channel_resource_id"`
+	ErrorMessage      string          `json:"error_message"`
+	RequestPayload    json.RawMessage `json:"request_payload,omitempty"`
+	ResponsePayload   json.RawMessage `json:"response_payload,omitempty"`
+	AttemptAt         time.Time       `json:"attempt_at"`
+}
+
+// ListProductPublishLogs returns a product's channel publish attempts, newest first.
+// Query params:
+//   - channel: only attempts for this channel (e.g. woocommerce)
+//   - success: true/false
+func ListProductPublishLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		success, err := queryBool(c, "success")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		var product models.Product
+		if err := db.Select("id").Where("id = ? AND organization_id = ?", productID, orgID).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		query := db.Model(&models.ChannelPublishLog{}).Where("product_id = ?", product.ID)
+		if channel := strings.TrimSpace(c.Query("channel")); channel != "" {
+			query = query.Where("channel = ?", channel)
+		}
+		if success != nil {
+			query = query.Where("success = ?", *success)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var logs []models.ChannelPublishLog
+		if err := query.Order("id DESC").Limit(page.PerPage).Offset(page.Offset()).Find(&logs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]publishLogResp, 0, len(logs))
+		for _, l := range logs {
+			out = append(out, toPublishLogResp(l, "", ""))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// ListPublishFailures is the org-wide view of failed publish attempts, newest first,
+// labelled with the product name and SKU. Payloads are omitted; fetch them per product.
+// Query params:
+//   - channel: only failures on this channel
+//   - current: true (default) keeps only products whose latest attempt on the channel failed;
+//     false returns every failed attempt
+func ListPublishFailures(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		current, err := queryBool(c, "current")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Table("channel_publish_logs AS l").
+			Joins("JOIN products ON products.id = l.product_id AND products.deleted_at IS NULL").
+			Where("products.organization_id = ? AND l.success = ? AND l.deleted_at IS NULL", orgID, false)
+		if channel := strings.TrimSpace(c.Query("channel")); channel != "" {
+			query = query.Where("l.channel = ?", channel)
+		}
+		if current == nil || *current {
+			query = query.Where(`NOT EXISTS (
+				SELECT 1 FROM channel_publish_logs newer
+				WHERE newer.product_id = l.product_id AND newer.channel = l.channel
+				  AND newer.id > l.id AND newer.deleted_at IS NULL)`)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		var rows []struct {
+			models.ChannelPublishLog
+			ProductName string
+			ProductSKU  string
+		}
+		if err := query.
+			Select("l.id, l.product_id, l.channel, l.success, l.channel_resource_id, l.error_message, l.attempt_at, products.name AS product_name, products.sku AS product_sku").
+			Order("l.id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]publishLogResp, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, toPublishLogResp(r.ChannelPublishLog, r.ProductName, r.ProductSKU))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+func toPublishLogResp(l models.ChannelPublishLog, productName, productSKU string) publishLogResp {
+	resp := publishLogResp{
+		ID:                l.ID,
+		ProductID:         l.ProductID,
+		ProductName:       productName,
+		ProductSKU:        productSKU,
+		Channel:           l.Channel,
+		Success:           l.Success,
+		ChannelResourceID: l.ChannelResourceID,
+		ErrorMessage:      l.ErrorMessage,
+		AttemptAt:         l.AttemptAt,
+	}
+	if len(l.RequestPayload) > 0 {
+		resp.RequestPayload = json.RawMessage(l.RequestPayload)
+	}
+	if len(l.ResponsePayload) > 0 {
+		resp.ResponsePayload = json.RawMessage(l.ResponsePayload)
+	}
+	return resp
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type productSalesResp struct {
+	ProductID uint    `json:"product_id"`
+	Name      string  `json:"name"`
+	SKU       string  `json:"sku"`
+	Units     int     `json:"units"`
+	Orders    int     `json:"orders"`
+	Revenue   float64 `json:"revenue"`
+}
+
+// ProductSalesReport sums units sold and revenue per local product from order_items,
+// best sellers first. Cancelled, refunded and failed orders don't count.
+// Query params:
+//   - from / to: order created date range, RFC3339 or YYYY-MM-DD (to is inclusive of the whole day)
+//   - product_id: only this product
+//   - source: only orders from this channel (woocommerce, ondc)
+func ProductSalesReport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		from, err := queryDate(c, "from", false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := queryDate(c, "to", true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		productID, err := queryInt(c, "product_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Table("order_items AS oi").
+			Joins("JOIN orders ON orders.id = oi.order_id AND orders.deleted_at IS NULL").
+			Joins("JOIN products ON products.id = oi.product_id").
+			Where("oi.organization_id = ? AND oi.product_id IS NOT NULL", orgID).
+			Where("orders.status NOT IN ?", []string{services.OrderStatusCancelled, services.OrderStatusRefunded, services.OrderStatusFailed})
+		if from != nil {
+			query = query.Where("orders.created_at >= ?", *from)
+		}
+		if to != nil {
+			query = query.Where("orders.created_at <= ?", *to)
+		}
+		if productID != nil {
+			query = query.Where("oi.product_id = ?", *productID)
+		}
+		if source := strings.TrimSpace(c.Query("source")); source != "" {
+			query = query.Where("orders.source = ?", source)
+		}
+		query = query.Group("oi.product_id, products.name, products.sku").Session(&gorm.Session{})
+
+		var total int64
+		if err := db.Table("(?) AS grouped", query.Select("oi.product_id")).Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]productSalesResp, 0)
+		if err := query.
+			Select("oi.product_id, products.name, products.sku, " +
+				"SUM(oi.quantity) AS units, COUNT(DISTINCT oi.order_id) AS orders, " +
+				"SUM(CASE WHEN oi.total <> 0 THEN oi.total ELSE oi.unit_price * oi.quantity END) AS revenue").
+			Order("units DESC, oi.product_id ASC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Scan(&out).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
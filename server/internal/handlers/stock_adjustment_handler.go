@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type createStockAdjustmentReq struct {
+	Reason string                   `json:"reason"` // for lines that don't give their own
+	Note   string                   `json:"note"`
+	Lines  []stockAdjustmentLineReq `json:"lines" binding:"required"`
+}
+
+// stockAdjustmentLineReq gives exactly one of Set (the new stock) and Delta (the change).
+// Without a location_id it adjusts the line's total on-hand.
+type stockAdjustmentLineReq struct {
+	ProductID  uint   `json:"product_id"`
+	VariantID  *uint  `json:"variant_id"`
+	LocationID *uint  `json:"location_id"`
+	Reason     string `json:"reason"`
+	Set        *int   `json:"set"`
+	Delta      *int   `json:"delta"`
+}
+
+type reviewStockAdjustmentReq struct {
+	Note string `json:"note"`
+}
+
+type stockAdjustmentResp struct {
+	ID          uint                      `json:"id"`
+	Status      string                    `json:"status"`
+	Note        string                    `json:"note"`
+	LineCount   int                       `json:"line_count"`
+	RequestedBy *uint                     `json:"requested_by"`
+	ReviewedBy  *uint                     `json:"reviewed_by"`
+	ReviewedAt  *time.Time                `json:"reviewed_at"`
+	ReviewNote  string                    `json:"review_note"`
+	AppliedAt   *time.Time                `json:"applied_at"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+	Lines       []stockAdjustmentLineResp `json:"lines,omitempty"`
+}
+
+type stockAdjustmentLineResp struct {
+	ID         uint   `json:"id"`
+	ProductID  uint   `json:"product_id"`
+	VariantID  *uint  `json:"variant_id,omitempty"`
+	Name       string `json:"name"`
+	SKU        string `json:"sku"`
+	LocationID *uint  `json:"location_id"`
+	Location   string `json:"location,omitempty"`
+	Reason     string `json:"reason"`
+	Mode       string `json:"mode"`
+	Quantity   int    `json:"quantity"`
+	ChangeQty  *int   `json:"change_qty"` // what the stock moved by, once applied
+}
+
+// ListStockAdjustments lists the org's stock adjustments, newest first.
+// Query params:
+//   - status: pending_approval, applied or rejected (comma-separated for several)
+func ListStockAdjustments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Model(&models.StockAdjustment{}).Where("organization_id = ?", orgID)
+		if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+			query = query.Where("status IN ?", strings.Split(raw, ","))
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var adjustments []models.StockAdjustment
+		if err := query.Preload("Lines").
+			Order("id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Find(&adjustments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]stockAdjustmentResp, 0, len(adjustments))
+		for _, a := range adjustments {
+			out = append(out, toStockAdjustmentResp(a, nil, nil))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetStockAdjustment returns an adjustment with its lines.
+func GetStockAdjustment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		adjustmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adjustment ID"})
+			return
+		}
+		var a models.StockAdjustment
+		if err := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Where("id = ? AND organization_id = ?", adjustmentID, orgID).
+			First(&a).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, toStockAdjustmentResp(a, orgLocationNames(db, orgID), adjustmentLineLabels(db, a.Lines)))
+	}
+}
+
+// CreateStockAdjustment corrects stock in a batch: each line sets a product's (or
+// variant's) stock, at a location or in total, or moves it by a delta, under a reason
+// code (damage, theft, count_correction, found). When the org has an approval threshold
+// and a non-admin's line moves more than it, the batch waits for an admin as
+// pending_approval; otherwise it is applied at once, all lines or none.
+func CreateStockAdjustment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var req createStockAdjustmentReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Lines) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines must not be empty"})
+			return
+		}
+		if msg, err := validateAdjustmentLines(db, orgID, &req); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		} else if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+		admin, err := isOrgAdmin(db, orgID, uid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var org models.Organization
+		if err := db.Select("id", "adjustment_approval_threshold").First(&org, orgID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
+		a := models.StockAdjustment{
+			OrganizationID: orgID,
+			Status:         services.AdjustmentPending,
+			Note:           strings.TrimSpace(req.Note),
+			RequestedBy:    &uid,
+		}
+		for _, l := range req.Lines {
+			line := models.StockAdjustmentLine{ProductID: l.ProductID, VariantID: l.VariantID, LocationID: l.LocationID, Reason: l.Reason}
+			if l.Set != nil {
+				line.Mode, line.Quantity = services.AdjustModeSet, *l.Set
+			} else {
+				line.Mode, line.Quantity = services.AdjustModeDelta, *l.Delta
+			}
+			a.Lines = append(a.Lines, line)
+		}
+
+		var changes []services.StockChange
+		err = db.Transaction(func(tx *gorm.DB) error {
+			planned, err := services.PlanAdjustment(tx, orgID, a.Lines)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&a).Error; err != nil {
+				return err
+			}
+			if !admin && services.AdjustmentNeedsApproval(planned, org.AdjustmentApprovalThreshold) {
+				return nil
+			}
+			changes, err = services.ApplyAdjustment(tx, &a, nil, time.Now())
+			return err
+		})
+		if respondAdjustmentError(c, err) {
+			return
+		}
+
+		if len(changes) > 0 {
+			go events.PublishStockChanged(orgID, "adjustment", changes)
+		}
+		c.JSON(http.StatusCreated, toStockAdjustmentResp(a, orgLocationNames(db, orgID), adjustmentLineLabels(db, a.Lines)))
+	}
+}
+
+// ApproveStockAdjustment applies a pending adjustment (Admin only). Set lines are measured
+// against the stock as it is now.
+func ApproveStockAdjustment(db *gorm.DB) gin.HandlerFunc {
+	return stockAdjustmentReview(db, func(c *gin.Context, tx *gorm.DB, a *models.StockAdjustment, uid *uint) ([]services.StockChange, error) {
+		return services.ApplyAdjustment(tx, a, uid, time.Now())
+	})
+}
+
+// RejectStockAdjustment turns down a pending adjustment (Admin only); the body may give a
+// note saying why.
+func RejectStockAdjustment(db *gorm.DB) gin.HandlerFunc {
+	return stockAdjustmentReview(db, func(c *gin.Context, tx *gorm.DB, a *models.StockAdjustment, uid *uint) ([]services.StockChange, error) {
+		var req reviewStockAdjustmentReq
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				return nil, badRequestError(err.Error())
+			}
+		}
+		return nil, services.RejectAdjustment(tx, a, uid, strings.TrimSpace(req.Note), time.Now())
+	})
+}
+
+// stockAdjustmentReview runs an admin's review step on the :id adjustment, locked, in a
+// transaction, then pushes the stock changes to the channels and answers with the
+// updated adjustment.
+func stockAdjustmentReview(db *gorm.DB, step func(c *gin.Context, tx *gorm.DB, a *models.StockAdjustment, uid *uint) ([]services.StockChange, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		adjustmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid adjustment ID"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var a models.StockAdjustment
+		var changes []services.StockChange
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND organization_id = ?", adjustmentID, orgID).
+				First(&a).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("adjustment_id = ?", a.ID).Order("id ASC").Find(&a.Lines).Error; err != nil {
+				return err
+			}
+			var err error
+			changes, err = step(c, tx, &a, &uid)
+			return err
+		})
+		if respondAdjustmentError(c, err) {
+			return
+		}
+
+		if len(changes) > 0 {
+			go events.PublishStockChanged(orgID, "adjustment", changes)
+		}
+		a.UpdatedAt = time.Now()
+		c.JSON(http.StatusOK, toStockAdjustmentResp(a, orgLocationNames(db, orgID), adjustmentLineLabels(db, a.Lines)))
+	}
+}
+
+// respondAdjustmentError answers the request for a failed adjustment step and reports
+// whether it did; it does nothing for a nil err.
+func respondAdjustmentError(c *gin.Context, err error) bool {
+	var badReq badRequestError
+	var lineErr *services.AdjustmentLineError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
+	case errors.As(err, &badReq):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &lineErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "product_id": lineErr.ProductID, "variant_id": lineErr.VariantID, "location_id": lineErr.LocationID})
+	case errors.Is(err, services.ErrAdjustmentStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust stock"})
+	}
+	return true
+}
+
+// validateAdjustmentLines checks an adjustment's lines and fills in each line's reason
+// from the batch's: a known reason code, exactly one of set (non-negative) and delta
+// (non-zero), the org's products, variants and locations, and no line adjusting the same
+// stock twice. It returns a message for the client, empty when the lines are fine.
+func validateAdjustmentLines(db *gorm.DB, orgID uint, req *createStockAdjustmentReq) (string, error) {
+	refs := make([]stockLineRef, 0, len(req.Lines))
+	var locationIDs []uint
+	type key struct{ product, variant, location uint }
+	seen := make(map[key]bool, len(req.Lines))
+	for i := range req.Lines {
+		l := &req.Lines[i]
+		prefix := "lines[" + strconv.Itoa(i) + "]: "
+		if l.Reason = strings.TrimSpace(l.Reason); l.Reason == "" {
+			l.Reason = strings.TrimSpace(req.Reason)
+		}
+		switch {
+		case l.Reason == "":
+			return prefix + "reason is required (damage, theft, count_correction or found)", nil
+		case !services.IsValidAdjustmentReason(l.Reason):
+			return prefix + "reason must be damage, theft, count_correction or found", nil
+		case (l.Set == nil) == (l.Delta == nil):
+			return prefix + "give exactly one of set and delta", nil
+		case l.Set != nil && *l.Set < 0:
+			return prefix + "set must be non-negative", nil
+		case l.Delta != nil && *l.Delta == 0:
+			return prefix + "delta must not be zero", nil
+		}
+
+		k := key{product: l.ProductID}
+		if l.VariantID != nil {
+			k.variant = *l.VariantID
+		}
+		if l.LocationID != nil {
+			k.location = *l.LocationID
+			locationIDs = append(locationIDs, *l.LocationID)
+		}
+		if seen[k] {
+			return prefix + "product listed twice for the same location", nil
+		}
+		seen[k] = true
+		refs = append(refs, stockLineRef{ProductID: l.ProductID, VariantID: l.VariantID})
+	}
+	if msg, err := validateStockLineRefs(db, orgID, refs); msg != "" || err != nil {
+		return msg, err
+	}
+
+	if len(locationIDs) > 0 {
+		var found []uint
+		if err := db.Model(&models.SellerLocation{}).
+			Where("organization_id = ? AND id IN ?", orgID, locationIDs).
+			Pluck("id", &found).Error; err != nil {
+			return "", err
+		}
+		ours := make(map[uint]bool, len(found))
+		for _, id := range found {
+			ours[id] = true
+		}
+		for i, l := range req.Lines {
+			if l.LocationID != nil && !ours[*l.LocationID] {
+				return "lines[" + strconv.Itoa(i) + "]: location_id is not one of your locations", nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// adjustmentLineLabels looks up the product name and SKU (the variant's, if set) per line ID.
+func adjustmentLineLabels(db *gorm.DB, lines []models.StockAdjustmentLine) map[uint]stockLineLabel {
+	ids := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ID)
+	}
+	return stockLineLabels(db, "stock_adjustment_lines", ids)
+}
+
+// toStockAdjustmentResp renders an adjustment; lines are included when labels is non-nil.
+func toStockAdjustmentResp(a models.StockAdjustment, locationNames map[uint]string, labels map[uint]stockLineLabel) stockAdjustmentResp {
+	resp := stockAdjustmentResp{
+		ID:          a.ID,
+		Status:      a.Status,
+		Note:        a.Note,
+		LineCount:   len(a.Lines),
+		RequestedBy: a.RequestedBy,
+		ReviewedBy:  a.ReviewedBy,
+		ReviewedAt:  a.ReviewedAt,
+		ReviewNote:  a.ReviewNote,
+		AppliedAt:   a.AppliedAt,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+	if labels == nil {
+		return resp
+	}
+	for _, l := range a.Lines {
+		line := stockAdjustmentLineResp{
+			ID:         l.ID,
+			ProductID:  l.ProductID,
+			VariantID:  l.VariantID,
+			Name:       labels[l.ID].Name,
+			SKU:        labels[l.ID].SKU,
+			LocationID: l.LocationID,
+			Reason:     l.Reason,
+			Mode:       l.Mode,
+			Quantity:   l.Quantity,
+			ChangeQty:  l.ChangeQty,
+		}
+		if l.LocationID != nil {
+			line.Location = locationNames[*l.LocationID]
+		}
+		resp.Lines = append(resp.Lines, line)
+	}
+	return resp
+}
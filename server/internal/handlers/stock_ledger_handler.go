@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type movementResp struct {
+	ID          string    `json:"id"`
+	ProductID   uint      `json:"product_id"`
+	VariantID   *uint     `json:"variant_id,omitempty"`
+	Name        string    `json:"name"`
+	SKU         string    `json:"sku"`
+	LocationID  *uint     `json:"location_id"`
+	Location    string    `json:"location,omitempty"`
+	ChangeQty   int       `json:"change_qty"`
+	Reason      string    `json:"reason"`
+	Ref         string    `json:"ref"`
+	ActorUserID *uint     `json:"actor_user_id"`
+	Actor       string    `json:"actor,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type stockDriftResp struct {
+	ProductID uint   `json:"product_id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku"`
+	Stock     int    `json:"stock"`
+	Ledger    int    `json:"ledger"`
+	Drift     int    `json:"drift"` // stock - ledger
+	Repaired  bool   `json:"repaired"`
+}
+
+// ListProductMovements returns a product's stock ledger, newest first. Takes the filters
+// of ListMovements but product_id.
+func ListProductMovements(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+		var product models.Product
+		if err := db.Select("id").Where("id = ? AND organization_id = ?", productID, orgID).First(&product).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		listMovements(c, db, orgID, &product.ID)
+	}
+}
+
+// ListMovements returns the org's stock ledger: every recorded stock change, newest first.
+// Query params:
+//   - product_id, variant_id, location_id: only changes to this product, variant or location
+//   - reason: e.g. order_sync_woo, adjust_damage, transfer_in (comma-separated for several)
+//   - ref: e.g. stock_adjustment:12
+//   - from, to: created on or after / on or before (RFC3339 or YYYY-MM-DD)
+func ListMovements(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		productID, err := queryInt(c, "product_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var product *uint
+		if productID != nil {
+			id := uint(*productID)
+			product = &id
+		}
+		listMovements(c, db, orgID, product)
+	}
+}
+
+// listMovements answers a ledger request for the org, or for one of its products.
+func listMovements(c *gin.Context, db *gorm.DB, orgID uint, productID *uint) {
+	variantID, err := queryInt(c, "variant_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locationID, err := queryInt(c, "location_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := queryDate(c, "from", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := queryDate(c, "to", true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page := parsePagination(c)
+
+	query := db.Table("inventory_movements AS m").
+		Joins("JOIN products ON products.id = m.product_id").
+		Where("products.organization_id = ?", orgID)
+	if productID != nil {
+		query = query.Where("m.product_id = ?", *productID)
+	}
+	if variantID != nil {
+		query = query.Where("m.variant_id = ?", *variantID)
+	}
+	if locationID != nil {
+		query = query.Where("m.location_id = ?", *locationID)
+	}
+	if raw := strings.TrimSpace(c.Query("reason")); raw != "" {
+		query = query.Where("m.reason IN ?", strings.Split(raw, ","))
+	}
+	if ref := strings.TrimSpace(c.Query("ref")); ref != "" {
+		query = query.Where("m.ref = ?", ref)
+	}
+	if from != nil {
+		query = query.Where("m.created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("m.created_at <= ?", *to)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	out := make([]movementResp, 0, page.PerPage)
+	if err := query.
+		Select("m.id, m.product_id, m.variant_id, products.name, COALESCE(NULLIF(v.sku, ''), products.sku, '') AS sku, " +
+			"m.location_id, COALESCE(sl.name, '') AS location, m.change_qty, m.reason, m.ref, m.actor_user_id, " +
+			"COALESCE(users.name, '') AS actor, m.created_at").
+		Joins("LEFT JOIN product_variants v ON v.id = m.variant_id").
+		Joins("LEFT JOIN seller_locations sl ON sl.id = m.location_id").
+		Joins("LEFT JOIN users ON users.id = m.actor_user_id").
+		Order("m.created_at DESC, m.id").
+		Limit(page.PerPage).
+		Offset(page.Offset()).
+		Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+}
+
+// GetStockReconciliation reports the org's stock lines whose on-hand doesn't match their
+// ledger. Nothing is changed.
+func GetStockReconciliation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		drift, _, err := services.ReconcileStock(db, orgID, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": toStockDriftResp(db, drift), "total": len(drift)})
+	}
+}
+
+// RepairStockReconciliation sets the org's drifting stock back to what the ledger says
+// (Admin only) and reports each line it looked at, repaired or not.
+func RepairStockReconciliation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		drift, byOrg, err := services.ReconcileStock(db, orgID, true)
+		if changes := byOrg[orgID]; len(changes) > 0 {
+			go events.PublishStockChanged(orgID, "reconcile", changes)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to repair stock"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": toStockDriftResp(db, drift), "total": len(drift)})
+	}
+}
+
+// toStockDriftResp renders drift with each line's product name and SKU.
+func toStockDriftResp(db *gorm.DB, drift []services.StockDrift) []stockDriftResp {
+	out := make([]stockDriftResp, 0, len(drift))
+	if len(drift) == 0 {
+		return out
+	}
+	productIDs := make([]uint, 0, len(drift))
+	for _, d := range drift {
+		productIDs = append(productIDs, d.ProductID)
+	}
+	var products []models.Product
+	db.Select("id", "name", "sku").Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Select("id", "product_id", "sku") }).
+		Where("id IN ?", productIDs).
+		Find(&products)
+	labels := make(map[uint]models.Product, len(products))
+	for _, p := range products {
+		labels[p.ID] = p
+	}
+
+	for _, d := range drift {
+		p := labels[d.ProductID]
+		sku := p.SKU
+		if d.VariantID != nil {
+			for _, v := range p.Variants {
+				if v.ID == *d.VariantID && v.SKU != "" {
+					sku = v.SKU
+				}
+			}
+		}
+		out = append(out, stockDriftResp{
+			ProductID: d.ProductID,
+			VariantID: d.VariantID,
+			Name:      p.Name,
+			SKU:       sku,
+			Stock:     d.Stock,
+			Ledger:    d.Ledger,
+			Drift:     d.Drift,
+			Repaired:  d.Repaired,
+		})
+	}
+	return out
+}
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type createStockTransferReq struct {
+	FromLocationID uint                   `json:"from_location_id" binding:"required"`
+	ToLocationID   uint                   `json:"to_location_id" binding:"required"`
+	Note           string                 `json:"note"`
+	Lines          []stockTransferLineReq `json:"lines" binding:"required"`
+}
+
+type stockTransferLineReq struct {
+	ProductID uint  `json:"product_id"`
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity"`
+}
+
+type receiveStockTransferReq struct {
+	Lines []struct {
+		LineID      uint `json:"line_id"`
+		ReceivedQty *int `json:"received_qty"`
+	} `json:"lines"`
+}
+
+type stockTransferResp struct {
+	ID             uint                    `json:"id"`
+	FromLocationID uint                    `json:"from_location_id"`
+	FromLocation   string                  `json:"from_location"`
+	ToLocationID   uint                    `json:"to_location_id"`
+	ToLocation     string                  `json:"to_location"`
+	Status         string                  `json:"status"`
+	Note           string                  `json:"note"`
+	LineCount      int                     `json:"line_count"`
+	Units          int                     `json:"units"`
+	CreatedBy      *uint                   `json:"created_by"`
+	DispatchedAt   *time.Time              `json:"dispatched_at"`
+	DispatchedBy   *uint                   `json:"dispatched_by"`
+	ReceivedAt     *time.Time              `json:"received_at"`
+	ReceivedBy     *uint                   `json:"received_by"`
+	CancelledAt    *time.Time              `json:"cancelled_at"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+	Lines          []stockTransferLineResp `json:"lines,omitempty"`
+}
+
+type stockTransferLineResp struct {
+	ID          uint   `json:"id"`
+	ProductID   uint   `json:"product_id"`
+	VariantID   *uint  `json:"variant_id,omitempty"`
+	Name        string `json:"name"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity"`
+	ReceivedQty *int   `json:"received_qty"`
+	Variance    *int   `json:"variance"` // received - sent, once received
+}
+
+// ListStockTransfers lists the org's transfers, newest first.
+// Query params:
+//   - status: draft, dispatched, received or cancelled (comma-separated for several)
+//   - location_id: transfers from or to this location
+func ListStockTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		locationID, err := queryInt(c, "location_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Model(&models.StockTransfer{}).Where("organization_id = ?", orgID)
+		if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+			query = query.Where("status IN ?", strings.Split(raw, ","))
+		}
+		if locationID != nil {
+			query = query.Where("(from_location_id = ? OR to_location_id = ?)", *locationID, *locationID)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var transfers []models.StockTransfer
+		if err := query.Preload("Lines").
+			Order("id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Find(&transfers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		names := orgLocationNames(db, orgID)
+		out := make([]stockTransferResp, 0, len(transfers))
+		for _, t := range transfers {
+			out = append(out, toStockTransferResp(t, names, nil))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetStockTransfer returns a transfer with its lines.
+func GetStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		transferID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+		var t models.StockTransfer
+		if err := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Where("id = ? AND organization_id = ?", transferID, orgID).
+			First(&t).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, toStockTransferResp(t, orgLocationNames(db, orgID), transferLineLabels(db, t.Lines)))
+	}
+}
+
+// CreateStockTransfer drafts a transfer between two of the org's locations. Nothing moves
+// until it is dispatched. A variable product's lines name the variant; each product (or
+// variant) appears once.
+func CreateStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var req createStockTransferReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.FromLocationID == req.ToLocationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_location_id and to_location_id must differ"})
+			return
+		}
+		if len(req.Lines) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines must not be empty"})
+			return
+		}
+		var locations int64
+		if err := db.Model(&models.SellerLocation{}).
+			Where("organization_id = ? AND id IN ?", orgID, []uint{req.FromLocationID, req.ToLocationID}).
+			Count(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if locations != 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_location_id and to_location_id must be your locations"})
+			return
+		}
+		if msg, err := validateTransferLines(db, orgID, req.Lines); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		} else if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+		t := models.StockTransfer{
+			OrganizationID: orgID,
+			FromLocationID: req.FromLocationID,
+			ToLocationID:   req.ToLocationID,
+			Status:         services.TransferDraft,
+			Note:           strings.TrimSpace(req.Note),
+			CreatedBy:      &uid,
+		}
+		for _, l := range req.Lines {
+			t.Lines = append(t.Lines, models.StockTransferLine{ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity})
+		}
+		if err := db.Create(&t).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+			return
+		}
+		c.JSON(http.StatusCreated, toStockTransferResp(t, orgLocationNames(db, orgID), transferLineLabels(db, t.Lines)))
+	}
+}
+
+// DispatchStockTransfer sends a draft transfer: its lines leave the source location and
+// are in transit, not sellable anywhere, until received.
+func DispatchStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return stockTransferStep(db, func(c *gin.Context, tx *gorm.DB, t *models.StockTransfer, uid *uint) ([]services.StockChange, error) {
+		return services.DispatchTransfer(tx, t, uid, time.Now())
+	})
+}
+
+// ReceiveStockTransfer lands a dispatched transfer at its destination. The body may give
+// received_qty per line_id for short or over receipts; lines left out arrived in full.
+func ReceiveStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return stockTransferStep(db, func(c *gin.Context, tx *gorm.DB, t *models.StockTransfer, uid *uint) ([]services.StockChange, error) {
+		var req receiveStockTransferReq
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				return nil, badRequestError(err.Error())
+			}
+		}
+		onTransfer := make(map[uint]bool, len(t.Lines))
+		for _, l := range t.Lines {
+			onTransfer[l.ID] = true
+		}
+		received := make(map[uint]int, len(req.Lines))
+		for _, l := range req.Lines {
+			if !onTransfer[l.LineID] {
+				return nil, badRequestError("line " + strconv.FormatUint(uint64(l.LineID), 10) + " is not on this transfer")
+			}
+			if l.ReceivedQty == nil || *l.ReceivedQty < 0 {
+				return nil, badRequestError("received_qty must be a non-negative number")
+			}
+			received[l.LineID] = *l.ReceivedQty
+		}
+		return services.ReceiveTransfer(tx, t, received, uid, time.Now())
+	})
+}
+
+// CancelStockTransfer cancels a draft or dispatched transfer; a dispatched one's stock goes
+// back to the source location.
+func CancelStockTransfer(db *gorm.DB) gin.HandlerFunc {
+	return stockTransferStep(db, func(c *gin.Context, tx *gorm.DB, t *models.StockTransfer, uid *uint) ([]services.StockChange, error) {
+		return services.CancelTransfer(tx, t, time.Now())
+	})
+}
+
+// badRequestError is a request problem found inside a transfer step, answered with a 400.
+type badRequestError string
+
+func (e badRequestError) Error() string { return string(e) }
+
+// stockTransferStep runs step on the :id transfer, locked, in a transaction, then pushes
+// the stock changes to the channels and answers with the updated transfer.
+func stockTransferStep(db *gorm.DB, step func(c *gin.Context, tx *gorm.DB, t *models.StockTransfer, uid *uint) ([]services.StockChange, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		transferID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var t models.StockTransfer
+		var changes []services.StockChange
+		err = services.WithActor(db, uid).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND organization_id = ?", transferID, orgID).
+				First(&t).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("transfer_id = ?", t.ID).Order("id ASC").Find(&t.Lines).Error; err != nil {
+				return err
+			}
+			var err error
+			changes, err = step(c, tx, &t, &uid)
+			return err
+		})
+		var badReq badRequestError
+		var short *services.TransferShortError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+			return
+		case errors.As(err, &badReq):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.As(err, &short):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "product_id": short.ProductID, "variant_id": short.VariantID, "available": short.Available})
+			return
+		case errors.Is(err, services.ErrTransferStatus), errors.Is(err, services.ErrTransferUnmanaged):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+			return
+		}
+
+		if len(changes) > 0 {
+			go events.PublishStockChanged(orgID, "transfer", changes)
+		}
+		t.UpdatedAt = time.Now()
+		c.JSON(http.StatusOK, toStockTransferResp(t, orgLocationNames(db, orgID), transferLineLabels(db, t.Lines)))
+	}
+}
+
+// validateTransferLines checks a transfer's lines: positive quantities, products and
+// variants of the org's catalog, and no repeats. It returns a message for the client,
+// empty when the lines are fine.
+func validateTransferLines(db *gorm.DB, orgID uint, lines []stockTransferLineReq) (string, error) {
+	refs := make([]stockLineRef, 0, len(lines))
+	type key struct{ product, variant uint }
+	seen := make(map[key]bool, len(lines))
+	for i, l := range lines {
+		if l.Quantity <= 0 {
+			return "lines[" + strconv.Itoa(i) + "]: quantity must be positive", nil
+		}
+		k := key{product: l.ProductID}
+		if l.VariantID != nil {
+			k.variant = *l.VariantID
+		}
+		if seen[k] {
+			return "lines[" + strconv.Itoa(i) + "]: product listed twice", nil
+		}
+		seen[k] = true
+		refs = append(refs, stockLineRef{ProductID: l.ProductID, VariantID: l.VariantID})
+	}
+	return validateStockLineRefs(db, orgID, refs)
+}
+
+// stockLineRef is the product (and variant) a request line names.
+type stockLineRef struct {
+	ProductID uint
+	VariantID *uint
+}
+
+// validateStockLineRefs checks request lines against the org's catalog: every product
+// exists, and a variable product's line names one of its variants. It returns a message
+// for the client, empty when the lines are fine.
+func validateStockLineRefs(db *gorm.DB, orgID uint, refs []stockLineRef) (string, error) {
+	productIDs := make([]uint, 0, len(refs))
+	for _, r := range refs {
+		productIDs = append(productIDs, r.ProductID)
+	}
+	var products []models.Product
+	if err := db.Select("id").Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Select("id", "product_id") }).
+		Where("organization_id = ? AND id IN ?", orgID, productIDs).
+		Find(&products).Error; err != nil {
+		return "", err
+	}
+	variantsOf := make(map[uint]map[uint]bool, len(products))
+	for _, p := range products {
+		variantsOf[p.ID] = make(map[uint]bool, len(p.Variants))
+		for _, v := range p.Variants {
+			variantsOf[p.ID][v.ID] = true
+		}
+	}
+
+	for i, r := range refs {
+		variants, ok := variantsOf[r.ProductID]
+		switch {
+		case !ok:
+			return "lines[" + strconv.Itoa(i) + "]: product not found", nil
+		case r.VariantID == nil && len(variants) > 0:
+			return "lines[" + strconv.Itoa(i) + "]: variant_id is required for a variable product", nil
+		case r.VariantID != nil && !variants[*r.VariantID]:
+			return "lines[" + strconv.Itoa(i) + "]: variant_id is not a variant of this product", nil
+		}
+	}
+	return "", nil
+}
+
+// orgLocationNames maps the org's location IDs (deleted ones included) to names.
+func orgLocationNames(db *gorm.DB, orgID uint) map[uint]string {
+	var locations []models.SellerLocation
+	db.Unscoped().Select("id", "name").Where("organization_id = ?", orgID).Find(&locations)
+	names := make(map[uint]string, len(locations))
+	for _, l := range locations {
+		names[l.ID] = l.Name
+	}
+	return names
+}
+
+type stockLineLabel struct {
+	Name string
+	SKU  string
+}
+
+// transferLineLabels looks up the product name and SKU (the variant's, if set) per line ID.
+func transferLineLabels(db *gorm.DB, lines []models.StockTransferLine) map[uint]stockLineLabel {
+	ids := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ID)
+	}
+	return stockLineLabels(db, "stock_transfer_lines", ids)
+}
+
+// stockLineLabels looks up the product name and SKU (the variant's, if set) of rows of a
+// line table with product_id and variant_id columns, per line ID.
+func stockLineLabels(db *gorm.DB, table string, lineIDs []uint) map[uint]stockLineLabel {
+	labels := make(map[uint]stockLineLabel, len(lineIDs))
+	if len(lineIDs) == 0 {
+		return labels
+	}
+	var rows []struct {
+		ID   uint
+		Name string
+		SKU  string
+	}
+	db.Table(table+" AS l").
+		Select("l.id, products.name, COALESCE(NULLIF(v.sku, ''), products.sku) AS sku").
+		Joins("JOIN products ON products.id = l.product_id").
+		Joins("LEFT JOIN product_variants v ON v.id = l.variant_id").
+		Where("l.id IN ?", lineIDs).
+		Scan(&rows)
+	for _, r := range rows {
+		labels[r.ID] = stockLineLabel{Name: r.Name, SKU: r.SKU}
+	}
+	return labels
+}
+
+// toStockTransferResp renders a transfer; lines are included when labels is non-nil.
+func toStockTransferResp(t models.StockTransfer, locationNames map[uint]string, labels map[uint]stockLineLabel) stockTransferResp {
+	resp := stockTransferResp{
+		ID:             t.ID,
+		FromLocationID: t.FromLocationID,
+		FromLocation:   locationNames[t.FromLocationID],
+		ToLocationID:   t.ToLocationID,
+		ToLocation:     locationNames[t.ToLocationID],
+		Status:         t.Status,
+		Note:           t.Note,
+		LineCount:      len(t.Lines),
+		CreatedBy:      t.CreatedBy,
+		DispatchedAt:   t.DispatchedAt,
+		DispatchedBy:   t.DispatchedBy,
+		ReceivedAt:     t.ReceivedAt,
+		ReceivedBy:     t.ReceivedBy,
+		CancelledAt:    t.CancelledAt,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+	}
+	for _, l := range t.Lines {
+		resp.Units += l.Quantity
+		if labels == nil {
+			continue
+		}
+		line := stockTransferLineResp{
+			ID:          l.ID,
+			ProductID:   l.ProductID,
+			VariantID:   l.VariantID,
+			Name:        labels[l.ID].Name,
+			SKU:         labels[l.ID].SKU,
+			Quantity:    l.Quantity,
+			ReceivedQty: l.ReceivedQty,
+		}
+		if l.ReceivedQty != nil {
+			variance := *l.ReceivedQty - l.Quantity
+			line.Variance = &variance
+		}
+		resp.Lines = append(resp.Lines, line)
+	}
+	return resp
+}
@@ -0,0 +1,658 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/RvShivam/inventify/internal/events"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type createStocktakeReq struct {
+	LocationID *uint  `json:"location_id"`
+	CategoryID *uint  `json:"category_id"`
+	Note       string `json:"note"`
+}
+
+type submitStocktakeCountsReq struct {
+	Counts []stocktakeCountReq `json:"counts" binding:"required"`
+}
+
+// stocktakeCountReq names its line by line_id, by sku (what a barcode scanner reads) or by
+// product_id and variant_id. Quantity defaults to 1, a single scan; a negative one takes
+// back mistaken scans.
+type stocktakeCountReq struct {
+	LineID    *uint  `json:"line_id"`
+	SKU       string `json:"sku"`
+	ProductID *uint  `json:"product_id"`
+	VariantID *uint  `json:"variant_id"`
+	Quantity  *int   `json:"quantity"`
+}
+
+type approveStocktakeReq struct {
+	ZeroUncounted bool `json:"zero_uncounted"` // lines nobody counted count as none
+}
+
+type stocktakeResp struct {
+	ID            uint                `json:"id"`
+	LocationID    *uint               `json:"location_id"`
+	Location      string              `json:"location,omitempty"`
+	CategoryID    *uint               `json:"category_id"`
+	Category      string              `json:"category,omitempty"`
+	Status        string              `json:"status"`
+	Note          string              `json:"note"`
+	LineCount     int                 `json:"line_count"`
+	CountedLines  int                 `json:"counted_lines"`
+	VarianceLines int                 `json:"variance_lines"` // counted lines that differ from expected
+	CreatedBy     *uint               `json:"created_by"`
+	FrozenAt      time.Time           `json:"frozen_at"`
+	ApprovedAt    *time.Time          `json:"approved_at"`
+	ApprovedBy    *uint               `json:"approved_by"`
+	CancelledAt   *time.Time          `json:"cancelled_at"`
+	AdjustmentID  *uint               `json:"adjustment_id"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+	Lines         []stocktakeLineResp `json:"lines,omitempty"`
+}
+
+type stocktakeLineResp struct {
+	ID          uint   `json:"id"`
+	ProductID   uint   `json:"product_id"`
+	VariantID   *uint  `json:"variant_id,omitempty"`
+	Name        string `json:"name"`
+	SKU         string `json:"sku"`
+	ExpectedQty int    `json:"expected_qty"` // frozen when the count opened
+	CountedQty  *int   `json:"counted_qty"`
+	// stock movements between the count opening and the line's first count; the line
+	// held expected_qty plus these when it was counted
+	MovedBeforeCount *int `json:"moved_before_count"`
+	Variance         *int `json:"variance"`           // counted - (expected + moved_before_count): what approval posts
+	MovedSince       *int `json:"moved_since_freeze"` // stock movements since the count opened
+}
+
+type stocktakeCountResp struct {
+	ID        uint      `json:"id"`
+	LineID    uint      `json:"line_id"`
+	SKU       string    `json:"sku"`
+	UserID    *uint     `json:"user_id"`
+	User      string    `json:"user,omitempty"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListStocktakes lists the org's stocktakes, newest first.
+// Query params:
+//   - status: counting, approved or cancelled (comma-separated for several)
+//   - location_id: stocktakes of this location
+func ListStocktakes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		locationID, err := queryInt(c, "location_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Model(&models.Stocktake{}).Where("organization_id = ?", orgID)
+		if raw := strings.TrimSpace(c.Query("status")); raw != "" {
+			query = query.Where("status IN ?", strings.Split(raw, ","))
+		}
+		if locationID != nil {
+			query = query.Where("location_id = ?", *locationID)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var stocktakes []models.Stocktake
+		if err := query.Preload("Lines").
+			Order("id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Find(&stocktakes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		ids := make([]uint, 0, len(stocktakes))
+		for _, st := range stocktakes {
+			ids = append(ids, st.ID)
+		}
+		beforeCount, err := services.StocktakeMovedBeforeCount(db, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		locations, categories := orgLocationNames(db, orgID), stocktakeCategoryNames(db, stocktakes)
+		out := make([]stocktakeResp, 0, len(stocktakes))
+		for _, st := range stocktakes {
+			out = append(out, toStocktakeResp(st, locations, categories, nil, beforeCount, nil))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetStocktake returns a stocktake with its lines, their variances and how much each
+// line's stock moved before it was counted and since the count opened.
+// Query params:
+//   - variance_only: true for only counted lines that differ from expected
+//   - uncounted: true for only lines nobody has counted yet
+func GetStocktake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		stocktakeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+			return
+		}
+		varianceOnly, err := queryBool(c, "variance_only")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		uncounted, err := queryBool(c, "uncounted")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var st models.Stocktake
+		if err := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+			Where("id = ? AND organization_id = ?", stocktakeID, orgID).
+			First(&st).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		moved, err := services.StocktakeMovedSince(db, &st)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		beforeCount, err := services.StocktakeMovedBeforeCount(db, []uint{st.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		resp := toStocktakeResp(st, orgLocationNames(db, orgID), stocktakeCategoryNames(db, []models.Stocktake{st}), stocktakeLineLabels(db, st.Lines), beforeCount, moved)
+		onlyVariance := varianceOnly != nil && *varianceOnly
+		onlyUncounted := uncounted != nil && *uncounted
+		if onlyVariance || onlyUncounted {
+			lines := resp.Lines[:0]
+			for _, l := range resp.Lines {
+				if onlyVariance && (l.Variance == nil || *l.Variance == 0) {
+					continue
+				}
+				if onlyUncounted && l.CountedQty != nil {
+					continue
+				}
+				lines = append(lines, l)
+			}
+			resp.Lines = lines
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// CreateStocktake opens a count of a location's stock, a category's, or a category's at
+// a location, freezing what each stock-tracked line is expected to hold. A product can be
+// on only one open count of the same stock at a time.
+func CreateStocktake(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		var req createStocktakeReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.LocationID == nil && req.CategoryID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "location_id or category_id is required"})
+			return
+		}
+		if req.LocationID != nil {
+			if err := db.Select("id").Where("id = ? AND organization_id = ?", *req.LocationID, orgID).First(&models.SellerLocation{}).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "location_id is not one of your locations"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+		}
+		if req.CategoryID != nil {
+			if err := db.Select("id").First(&models.Category{}, *req.CategoryID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "category_id not found"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+		}
+
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+		st := models.Stocktake{
+			OrganizationID: orgID,
+			LocationID:     req.LocationID,
+			CategoryID:     req.CategoryID,
+			Note:           strings.TrimSpace(req.Note),
+			CreatedBy:      &uid,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return services.OpenStocktake(tx, &st, time.Now())
+		})
+		var overlap *services.StocktakeOverlapError
+		switch {
+		case errors.Is(err, services.ErrStocktakeEmpty):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.As(err, &overlap):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "stocktake_id": overlap.StocktakeID})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open stocktake"})
+			return
+		}
+		c.JSON(http.StatusCreated, toStocktakeResp(st, orgLocationNames(db, orgID), stocktakeCategoryNames(db, []models.Stocktake{st}), stocktakeLineLabels(db, st.Lines), nil, nil))
+	}
+}
+
+// SubmitStocktakeCounts adds counts to an open stocktake; any member may count, and counts
+// from several people add up. Answers with the counted lines' new totals.
+func SubmitStocktakeCounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		stocktakeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+			return
+		}
+		var req submitStocktakeCountsReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Counts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "counts must not be empty"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var st models.Stocktake
+		var counted []uint
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := lockStocktake(tx, orgID, stocktakeID, &st); err != nil {
+				return err
+			}
+			entries, err := resolveStocktakeCounts(st.Lines, req.Counts)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				counted = append(counted, e.LineID)
+			}
+			return services.SubmitStocktakeCounts(tx, &st, entries, &uid)
+		})
+		if respondStocktakeError(c, err) {
+			return
+		}
+
+		touched := make(map[uint]bool, len(counted))
+		for _, id := range counted {
+			touched[id] = true
+		}
+		var lines []models.StocktakeLine
+		for _, l := range st.Lines {
+			if touched[l.ID] {
+				lines = append(lines, l)
+			}
+		}
+		st.Lines = lines
+		beforeCount, err := services.StocktakeMovedBeforeCount(db, []uint{st.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		resp := toStocktakeResp(st, nil, nil, stocktakeLineLabels(db, lines), beforeCount, nil)
+		c.JSON(http.StatusOK, gin.H{"lines": resp.Lines})
+	}
+}
+
+// ListStocktakeCounts lists the counts submitted to a stocktake, newest first: who counted
+// what, and when. Query param line_id narrows it to one line.
+func ListStocktakeCounts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		stocktakeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+			return
+		}
+		lineID, err := queryInt(c, "line_id")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page := parsePagination(c)
+
+		var st models.Stocktake
+		if err := db.Select("id").Where("id = ? AND organization_id = ?", stocktakeID, orgID).First(&st).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		query := db.Table("stocktake_counts AS sc").Where("sc.stocktake_id = ?", st.ID)
+		if lineID != nil {
+			query = query.Where("sc.line_id = ?", *lineID)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		out := make([]stocktakeCountResp, 0, page.PerPage)
+		if err := query.
+			Select("sc.id, sc.line_id, l.sku, sc.user_id, COALESCE(users.name, '') AS user, sc.quantity, sc.created_at").
+			Joins("JOIN stocktake_lines l ON l.id = sc.line_id").
+			Joins("LEFT JOIN users ON users.id = sc.user_id").
+			Order("sc.id DESC").
+			Limit(page.PerPage).
+			Offset(page.Offset()).
+			Scan(&out).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// ApproveStocktake closes a count and posts its variances as a count_correction stock
+// adjustment (Admin only). Lines move by their variance, counted less what they held when
+// counted, so orders taken while the count ran are deducted once. Body: {"zero_uncounted": true} counts unscanned lines as none.
+func ApproveStocktake(db *gorm.DB) gin.HandlerFunc {
+	return stocktakeStep(db, func(c *gin.Context, tx *gorm.DB, st *models.Stocktake, uid *uint) ([]services.StockChange, error) {
+		var req approveStocktakeReq
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				return nil, badRequestError(err.Error())
+			}
+		}
+		return services.ApproveStocktake(tx, st, uid, req.ZeroUncounted, time.Now())
+	})
+}
+
+// CancelStocktake drops an open count without changing stock (Admin only).
+func CancelStocktake(db *gorm.DB) gin.HandlerFunc {
+	return stocktakeStep(db, func(c *gin.Context, tx *gorm.DB, st *models.Stocktake, uid *uint) ([]services.StockChange, error) {
+		return nil, services.CancelStocktake(tx, st, time.Now())
+	})
+}
+
+// stocktakeStep runs an admin's step on the :id stocktake, locked, in a transaction, then
+// pushes the stock changes to the channels and answers with the updated stocktake.
+func stocktakeStep(db *gorm.DB, step func(c *gin.Context, tx *gorm.DB, st *models.Stocktake, uid *uint) ([]services.StockChange, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		if !requireOrgAdmin(c, db, orgID) {
+			return
+		}
+		stocktakeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var st models.Stocktake
+		var changes []services.StockChange
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := lockStocktake(tx, orgID, stocktakeID, &st); err != nil {
+				return err
+			}
+			var err error
+			changes, err = step(c, tx, &st, &uid)
+			return err
+		})
+		if respondStocktakeError(c, err) {
+			return
+		}
+
+		if len(changes) > 0 {
+			go events.PublishStockChanged(orgID, "stocktake", changes)
+		}
+		st.UpdatedAt = time.Now()
+		beforeCount, err := services.StocktakeMovedBeforeCount(db, []uint{st.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, toStocktakeResp(st, orgLocationNames(db, orgID), stocktakeCategoryNames(db, []models.Stocktake{st}), stocktakeLineLabels(db, st.Lines), beforeCount, nil))
+	}
+}
+
+// lockStocktake loads the org's stocktake id into st with its lines, holding its row lock.
+func lockStocktake(tx *gorm.DB, orgID uint, id uint64, st *models.Stocktake) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND organization_id = ?", id, orgID).
+		First(st).Error; err != nil {
+		return err
+	}
+	return tx.Where("stocktake_id = ?", st.ID).Order("id ASC").Find(&st.Lines).Error
+}
+
+// respondStocktakeError answers the request for a failed stocktake step and reports
+// whether it did; it does nothing for a nil err.
+func respondStocktakeError(c *gin.Context, err error) bool {
+	var badReq badRequestError
+	var lineErr *services.AdjustmentLineError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+	case errors.As(err, &badReq), errors.Is(err, services.ErrStocktakeNegativeCount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &lineErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "product_id": lineErr.ProductID, "variant_id": lineErr.VariantID})
+	case errors.Is(err, services.ErrStocktakeStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stocktake"})
+	}
+	return true
+}
+
+// resolveStocktakeCounts matches each count to its line by line_id, SKU (case-insensitive)
+// or product_id/variant_id.
+func resolveStocktakeCounts(lines []models.StocktakeLine, counts []stocktakeCountReq) ([]services.StocktakeCountEntry, error) {
+	byID := make(map[uint]bool, len(lines))
+	bySKU := make(map[string][]uint, len(lines))
+	type key struct{ product, variant uint }
+	byKey := make(map[key]uint, len(lines))
+	for _, l := range lines {
+		byID[l.ID] = true
+		if sku := strings.ToLower(strings.TrimSpace(l.SKU)); sku != "" {
+			bySKU[sku] = append(bySKU[sku], l.ID)
+		}
+		k := key{product: l.ProductID}
+		if l.VariantID != nil {
+			k.variant = *l.VariantID
+		}
+		byKey[k] = l.ID
+	}
+
+	entries := make([]services.StocktakeCountEntry, 0, len(counts))
+	for i, ct := range counts {
+		prefix := "counts[" + strconv.Itoa(i) + "]: "
+		qty := 1
+		if ct.Quantity != nil {
+			qty = *ct.Quantity
+		}
+		var lineID uint
+		switch sku := strings.ToLower(strings.TrimSpace(ct.SKU)); {
+		case ct.LineID != nil:
+			if !byID[*ct.LineID] {
+				return nil, badRequestError(prefix + "line_id is not on this stocktake")
+			}
+			lineID = *ct.LineID
+		case sku != "":
+			ids := bySKU[sku]
+			if len(ids) == 0 {
+				return nil, badRequestError(prefix + "sku " + ct.SKU + " is not on this stocktake")
+			}
+			if len(ids) > 1 {
+				return nil, badRequestError(prefix + "sku " + ct.SKU + " matches several lines; use line_id")
+			}
+			lineID = ids[0]
+		case ct.ProductID != nil:
+			k := key{product: *ct.ProductID}
+			if ct.VariantID != nil {
+				k.variant = *ct.VariantID
+			}
+			id, ok := byKey[k]
+			if !ok {
+				return nil, badRequestError(prefix + "product is not on this stocktake")
+			}
+			lineID = id
+		default:
+			return nil, badRequestError(prefix + "line_id, sku or product_id is required")
+		}
+		entries = append(entries, services.StocktakeCountEntry{LineID: lineID, Quantity: qty})
+	}
+	return entries, nil
+}
+
+// stocktakeCategoryNames maps the stocktakes' category IDs to names.
+func stocktakeCategoryNames(db *gorm.DB, stocktakes []models.Stocktake) map[uint]string {
+	var ids []uint
+	for _, st := range stocktakes {
+		if st.CategoryID != nil {
+			ids = append(ids, *st.CategoryID)
+		}
+	}
+	names := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return names
+	}
+	var categories []models.Category
+	db.Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&categories)
+	for _, cat := range categories {
+		names[cat.ID] = cat.Name
+	}
+	return names
+}
+
+// stocktakeLineLabels looks up the product name and SKU (the variant's, if set) per line ID.
+func stocktakeLineLabels(db *gorm.DB, lines []models.StocktakeLine) map[uint]stockLineLabel {
+	ids := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ID)
+	}
+	return stockLineLabels(db, "stocktake_lines", ids)
+}
+
+// toStocktakeResp renders a stocktake; lines are included when labels is non-nil, with
+// their movement since the freeze when moved is. beforeCount (see
+// services.StocktakeMovedBeforeCount) goes into the variances.
+func toStocktakeResp(st models.Stocktake, locationNames, categoryNames map[uint]string, labels map[uint]stockLineLabel, beforeCount, moved map[uint]int) stocktakeResp {
+	resp := stocktakeResp{
+		ID:           st.ID,
+		LocationID:   st.LocationID,
+		CategoryID:   st.CategoryID,
+		Status:       st.Status,
+		Note:         st.Note,
+		LineCount:    len(st.Lines),
+		CreatedBy:    st.CreatedBy,
+		FrozenAt:     st.FrozenAt,
+		ApprovedAt:   st.ApprovedAt,
+		ApprovedBy:   st.ApprovedBy,
+		CancelledAt:  st.CancelledAt,
+		AdjustmentID: st.AdjustmentID,
+		CreatedAt:    st.CreatedAt,
+		UpdatedAt:    st.UpdatedAt,
+	}
+	if st.LocationID != nil {
+		resp.Location = locationNames[*st.LocationID]
+	}
+	if st.CategoryID != nil {
+		resp.Category = categoryNames[*st.CategoryID]
+	}
+	for _, l := range st.Lines {
+		var variance, movedBefore *int
+		if l.CountedQty != nil {
+			before := beforeCount[l.ID]
+			v := *l.CountedQty - l.ExpectedQty - before
+			variance, movedBefore = &v, &before
+			resp.CountedLines++
+			if v != 0 {
+				resp.VarianceLines++
+			}
+		}
+		if labels == nil {
+			continue
+		}
+		line := stocktakeLineResp{
+			ID:          l.ID,
+			ProductID:   l.ProductID,
+			VariantID:   l.VariantID,
+			Name:        labels[l.ID].Name,
+			SKU:         l.SKU,
+			ExpectedQty: l.ExpectedQty,
+			CountedQty:  l.CountedQty,
+			Variance:    variance,
+		}
+		line.MovedBeforeCount = movedBefore
+		if m, ok := moved[l.ID]; ok {
+			line.MovedSince = &m
+		}
+		resp.Lines = append(resp.Lines, line)
+	}
+	return resp
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/channels"
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/services"
+)
+
+type webhookInboxResp struct {
+	ID             uint            `json:"id"`
+	Channel        string          `json:"channel"`
+	DeliveryID     string          `json:"delivery_id"`
+	Topic          string          `json:"topic"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	DuplicateCount int             `json:"duplicate_count"`
+	LastError      string          `json:"last_error"`
+	Source         json.RawMessage `json:"source,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+	LastSeenAt     *time.Time      `json:"last_seen_at"`
+	ProcessedAt    *time.Time      `json:"processed_at"`
+}
+
+// ListWebhookInbox lists the org's channel webhook deliveries, newest first. Payloads are
+// left out; fetch a single delivery to see it.
+// Query params:
+//   - status: received, processing, processed or failed
+//   - topic: e.g. order.created
+//   - channel: e.g. woocommerce
+func ListWebhookInbox(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		page := parsePagination(c)
+
+		query := db.Model(&models.WebhookInbox{}).Where("organization_id = ?", orgID)
+		if status := strings.TrimSpace(c.Query("status")); status != "" {
+			switch status {
+			case services.InboxReceived, services.InboxProcessing, services.InboxProcessed, services.InboxFailed:
+				query = query.Where("status = ?", status)
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "status must be received, processing, processed or failed"})
+				return
+			}
+		}
+		if topic := strings.TrimSpace(c.Query("topic")); topic != "" {
+			query = query.Where("topic = ?", topic)
+		}
+		if channel := strings.TrimSpace(c.Query("channel")); channel != "" {
+			query = query.Where("channel = ?", channel)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		var rows []models.WebhookInbox
+		if err := query.Omit("payload").Order("id DESC").Limit(page.PerPage).Offset(page.Offset()).Find(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		out := make([]webhookInboxResp, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, toWebhookInboxResp(r))
+		}
+		c.JSON(http.StatusOK, paginatedResp{Data: out, Page: page.Page, PerPage: page.PerPage, Total: total})
+	}
+}
+
+// GetWebhookInbox returns one delivery including its payload.
+func GetWebhookInbox(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inbox, ok := loadWebhookInbox(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, toWebhookInboxResp(inbox))
+	}
+}
+
+// ReplayWebhookInbox hands a received or failed delivery to the consumers again, with a
+// fresh set of attempts, as well as one left in processing past the claim timeout by a
+// consumer that died. Processed deliveries aren't replayed. Org admins only.
+func ReplayWebhookInbox(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inbox, ok := loadWebhookInbox(c, db)
+		if !ok {
+			return
+		}
+		if !requireOrgAdmin(c, db, inbox.OrganizationID) {
+			return
+		}
+		if inbox.Channel != channels.WooName {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Replay isn't supported for channel " + inbox.Channel})
+			return
+		}
+
+		res := services.ReplayableWebhook(db.Model(&inbox), time.Now()).
+			Updates(map[string]interface{}{"status": services.InboxReceived, "attempts": 0, "last_error": ""})
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only received, failed or stuck deliveries can be replayed"})
+			return
+		}
+		inbox.Status, inbox.Attempts, inbox.LastError = services.InboxReceived, 0, ""
+
+		if err := publishWooWebhook(inbox); err != nil {
+			log.Println("webhook inbox replay: failed to publish event:", err)
+			db.Model(&inbox).Update("last_error", "publish: "+err.Error())
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue delivery"})
+			return
+		}
+		c.JSON(http.StatusAccepted, toWebhookInboxResp(inbox))
+	}
+}
+
+func loadWebhookInbox(c *gin.Context, db *gorm.DB) (models.WebhookInbox, bool) {
+	var inbox models.WebhookInbox
+	orgID, ok := getOrgIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+		return inbox, false
+	}
+	inboxID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return inbox, false
+	}
+	if err := db.Where("id = ? AND organization_id = ?", inboxID, orgID).First(&inbox).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return inbox, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return inbox, false
+	}
+	return inbox, true
+}
+
+func toWebhookInboxResp(w models.WebhookInbox) webhookInboxResp {
+	resp := webhookInboxResp{
+		ID:             w.ID,
+		Channel:        w.Channel,
+		DeliveryID:     w.DeliveryID,
+		Topic:          w.Topic,
+		Status:         w.Status,
+		Attempts:       w.Attempts,
+		DuplicateCount: w.DuplicateCount,
+		LastError:      w.LastError,
+		ReceivedAt:     w.CreatedAt,
+		LastSeenAt:     w.LastSeenAt,
+		ProcessedAt:    w.ProcessedAt,
+	}
+	if len(w.Source) > 0 {
+		resp.Source = json.RawMessage(w.Source)
+	}
+	if len(w.Payload) > 0 {
+		resp.Payload = json.RawMessage(w.Payload)
+	}
+	return resp
+}
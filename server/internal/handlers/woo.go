@@ -1,13 +1,9 @@
 package handlers
 
 import (
-	"bytes"
 	"crypto/rand"
-	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
@@ -16,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/RvShivam/inventify/internal/channels"
 	"github.com/RvShivam/inventify/internal/crypto"
 	"github.com/RvShivam/inventify/internal/events"
 	"github.com/RvShivam/inventify/internal/models"
@@ -60,13 +57,8 @@ func CreateWooStore(db *gorm.DB) gin.HandlerFunc {
 			verifySSL = *req.VerifySSL
 		}
 
-		ok, permErr := testWooConnection(site, req.ConsumerKey, req.ConsumerSecret, verifySSL)
-		if !ok {
-			msg := "failed to validate credentials"
-			if permErr != nil {
-				msg = permErr.Error()
-			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		if err := channels.NewWooClient(site, req.ConsumerKey, req.ConsumerSecret, verifySSL).Test(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
@@ -173,25 +165,13 @@ func TestWooStore(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
-		ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
-			return
-		}
-		cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+		client, err := channels.NewWooClientForStore(store)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
 			return
 		}
-
-		ok, permErr := testWooConnection(store.SiteURL, ck, cs, store.VerifySSL)
-		if !ok {
-			msg := "validation failed"
-			if permErr != nil {
-				msg = permErr.Error()
-			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		if err := client.Test(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
@@ -232,22 +212,17 @@ func RegisterWooWebhooks(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
-		ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
-			return
-		}
-		cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+		client, err := channels.NewWooClientForStore(store)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
 			return
 		}
+		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
 
 		created := make([]models.WooStoreWebhook, 0, len(req.Topics))
 		for _, topic := range req.Topics {
 			secret := randomSecret(32)
-			webhookID, err := createWooWebhook(store.SiteURL, ck, cs, req.DeliveryURL, topic, secret, store.VerifySSL)
+			webhookID, err := client.CreateWebhook(req.DeliveryURL, topic, secret)
 			if err != nil {
 				// On failure, return error and do NOT attempt cleanup (caller may retry)
 				c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to create webhook: %v", err)})
@@ -284,148 +259,6 @@ func RegisterWooWebhooks(db *gorm.DB) gin.HandlerFunc {
    Helper functions
    ------------------------------ */
 
-// testWooConnection: tries a read (products) and returns ok + optional permission error
-func testWooConnection(site, consumerKey, consumerSecret string, verifySSL bool) (bool, error) {
-	url := fmt.Sprintf("%s/wp-json/wc/v3/products?per_page=1", strings.TrimRight(site, "/"))
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(consumerKey, consumerSecret)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	if !verifySSL {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		return true, nil
-	}
-	// 401/403 => likely bad creds or insufficient perms
-	if resp.StatusCode == 401 || resp.StatusCode == 403 {
-		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("authentication failed (%d): %s", resp.StatusCode, string(body))
-	}
-	return false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
-}
-
-// createWooWebhook: creates a webhook and returns the webhook id (string)
-func createWooWebhook(site, consumerKey, consumerSecret, deliveryURL, topic, secret string, verifySSL bool) (string, error) {
-	url := fmt.Sprintf("%s/wp-json/wc/v3/webhooks", strings.TrimRight(site, "/"))
-
-	payload := map[string]interface{}{
-		"name":         "inventify-" + topic,
-		"topic":        topic,
-		"delivery_url": deliveryURL,
-		"secret":       secret,
-		"status":       "active",
-	}
-	b, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(b))
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(consumerKey, consumerSecret)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	if !verifySSL {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 201 && resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("webhook create returned %d: %s", resp.StatusCode, string(body))
-	}
-	var respJSON map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&respJSON); err != nil {
-		return "", err
-	}
-	// woo returns id field (number or string); normalize to string
-	if id, ok := respJSON["id"]; ok {
-		return fmt.Sprintf("%v", id), nil
-	}
-	return "", errors.New("no id in webhook response")
-}
-
-// getWooWebhookID finds a webhook by topic and delivery_url
-func getWooWebhookID(site, consumerKey, consumerSecret, topic, deliveryURL string, verifySSL bool) (string, error) {
-	// List webhooks (filtering by topic if possible, but Woo V3 doesn't always support strict filtering by topic in list, so we might need to fetch all or filter manually)
-	// V3 supports ?status=active etc. Let's just fetch all (default 10) or increase limit.
-	// Better: ?topic=... is supported in some versions. Let's try listing.
-	url := fmt.Sprintf("%s/wp-json/wc/v3/webhooks?per_page=100", strings.TrimRight(site, "/"))
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(consumerKey, consumerSecret)
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	if !verifySSL {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to list webhooks: %d", resp.StatusCode)
-	}
-
-	var webhooks []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&webhooks); err != nil {
-		return "", err
-	}
-
-	for _, wh := range webhooks {
-		t, _ := wh["topic"].(string)
-		d, _ := wh["delivery_url"].(string)
-		if t == topic && d == deliveryURL {
-			if id, ok := wh["id"]; ok {
-				return fmt.Sprintf("%v", id), nil
-			}
-		}
-	}
-	return "", nil // not found
-}
-
-// deleteWooWebhook deletes a webhook by ID
-func deleteWooWebhook(site, consumerKey, consumerSecret, webhookID string, verifySSL bool) error {
-	url := fmt.Sprintf("%s/wp-json/wc/v3/webhooks/%s?force=true", strings.TrimRight(site, "/"), webhookID)
-	req, _ := http.NewRequest("DELETE", url, nil)
-	req.SetBasicAuth(consumerKey, consumerSecret)
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	if !verifySSL {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 && resp.StatusCode != 204 { // 200 or 204 is success
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to delete webhook %s: %d %s", webhookID, resp.StatusCode, string(body))
-	}
-	return nil
-}
-
 // randomSecret returns a url-safe base64-like secret (hex)
 func randomSecret(n int) string {
 	b := make([]byte, n)
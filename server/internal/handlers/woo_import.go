@@ -0,0 +1,638 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/RvShivam/inventify/internal/channels"
+	"github.com/RvShivam/inventify/internal/models"
+)
+
+const (
+	wooImportPageSize  = 100
+	wooImageMaxBytes   = 10 << 20
+	wooImportUploadDir = "uploads"
+	// a pending or running import whose job row hasn't moved for this long is taken to have
+	// died with its process and no longer blocks a new one
+	wooImportStaleAfter = 30 * time.Minute
+)
+
+var errImportRunning = errors.New("an import is already running for this store")
+
+// ImportWooProducts starts a background job that pulls the store's existing Woo catalog into
+// Inventify. Products are matched to local ones by SKU and linked via ProductWoo.WooProductID;
+// unmatched products are created with their images downloaded into our upload storage.
+// Progress is polled via GET /api/products/import/:id.
+func ImportWooProducts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, ok := getOrgIDFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No organization found"})
+			return
+		}
+		userID, _ := c.Get("user_Id")
+		uid, _ := userID.(uint)
+
+		var store models.WooStore
+		if err := db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).First(&store).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "store not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+
+		job, err := startWooCatalogImport(db, store, uid)
+		if err != nil {
+			if errors.Is(err, errImportRunning) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, toImportJobResp(job))
+	}
+}
+
+// InternalImportWooProducts is the worker-facing variant of ImportWooProducts.
+// Protected endpoint for worker usage; ensure it's mounted with RequireServiceToken middleware.
+func InternalImportWooProducts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var store models.WooStore
+		if err := db.First(&store, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "store not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
+			return
+		}
+
+		job, err := startWooCatalogImport(db, store, 0)
+		if err != nil {
+			if errors.Is(err, errImportRunning) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, toImportJobResp(job))
+	}
+}
+
+// startWooCatalogImport creates the ImportJob and kicks off the importer goroutine. Earlier
+// jobs of the store that stopped making progress are marked failed first.
+func startWooCatalogImport(db *gorm.DB, store models.WooStore, userID uint) (models.ImportJob, error) {
+	if err := db.Model(&models.ImportJob{}).
+		Where("woo_store_id = ? AND status IN ? AND updated_at < ?", store.ID, []string{"pending", "running"}, time.Now().Add(-wooImportStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":        "failed",
+			"error_message": "import stopped making progress",
+			"finished_at":   time.Now(),
+		}).Error; err != nil {
+		return models.ImportJob{}, fmt.Errorf("db error: %w", err)
+	}
+	var running int64
+	if err := db.Model(&models.ImportJob{}).
+		Where("woo_store_id = ? AND status IN ?", store.ID, []string{"pending", "running"}).
+		Count(&running).Error; err != nil {
+		return models.ImportJob{}, fmt.Errorf("db error: %w", err)
+	}
+	if running > 0 {
+		return models.ImportJob{}, errImportRunning
+	}
+
+	client, err := channels.NewWooClientForStore(store)
+	if err != nil {
+		return models.ImportJob{}, errors.New("decrypt failed")
+	}
+
+	job := models.ImportJob{
+		OrganizationID: store.OrganizationID,
+		UserID:         userID,
+		Source:         "woocommerce",
+		WooStoreID:     &store.ID,
+		Status:         "pending",
+		Filename:       store.SiteURL,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return job, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	// Make sure imported products can be enabled on the channel.
+	ensureWooChannel(db, store.OrganizationID)
+
+	imp := &wooCatalogImporter{
+		db:     db,
+		jobID:  job.ID,
+		orgID:  store.OrganizationID,
+		store:  store,
+		client: client,
+	}
+	go imp.run()
+
+	return job, nil
+}
+
+/* ----------------------------
+   Woo REST types
+   ---------------------------- */
+
+type wooImage struct {
+	Src string `json:"src"`
+	Alt string `json:"alt"`
+}
+
+type wooProduct struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	Status            string `json:"status"`
+	Featured          bool   `json:"featured"`
+	CatalogVisibility string `json:"catalog_visibility"`
+	Description       string `json:"description"`
+	ShortDescription  string `json:"short_description"`
+	SKU               string `json:"sku"`
+	RegularPrice      string `json:"regular_price"`
+	SalePrice         string `json:"sale_price"`
+	ManageStock       bool   `json:"manage_stock"`
+	StockQuantity     *int   `json:"stock_quantity"`
+	Weight            string `json:"weight"`
+	Dimensions        struct {
+		Length string `json:"length"`
+		Width  string `json:"width"`
+		Height string `json:"height"`
+	} `json:"dimensions"`
+	Categories []struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"categories"`
+	Images     []wooImage `json:"images"`
+	Attributes []struct {
+		Name      string   `json:"name"`
+		Variation bool     `json:"variation"`
+		Options   []string `json:"options"`
+	} `json:"attributes"`
+	Variations []int64 `json:"variations"`
+}
+
+type wooVariation struct {
+	ID           int64  `json:"id"`
+	SKU          string `json:"sku"`
+	RegularPrice string `json:"regular_price"`
+	SalePrice    string `json:"sale_price"`
+	// manage_stock is true, false or "parent" for variations
+	ManageStock   interface{} `json:"manage_stock"`
+	StockQuantity *int        `json:"stock_quantity"`
+	Image         *wooImage   `json:"image"`
+	Attributes    []struct {
+		Name   string `json:"name"`
+		Option string `json:"option"`
+	} `json:"attributes"`
+}
+
+/* ----------------------------
+   Importer
+   ---------------------------- */
+
+type wooCatalogImporter struct {
+	db     *gorm.DB
+	jobID  uint
+	orgID  uint
+	store  models.WooStore
+	client *channels.WooClient
+
+	processed, created, matched, conflicts int
+	issues                                 []importRowError
+}
+
+func (imp *wooCatalogImporter) run() {
+	defer failImportJobOnPanic(imp.db, imp.jobID)
+	started := time.Now()
+	imp.updateJob(map[string]interface{}{"status": "running", "started_at": started})
+
+	for page := 1; ; page++ {
+		var products []wooProduct
+		q := url.Values{}
+		q.Set("per_page", strconv.Itoa(wooImportPageSize))
+		q.Set("page", strconv.Itoa(page))
+		q.Set("status", "any")
+		q.Set("orderby", "id")
+		q.Set("order", "asc")
+		header, err := imp.client.GetJSON("/products", q, &products)
+		if err != nil {
+			imp.finish("failed", fmt.Sprintf("failed to fetch products page %d: %v", page, err))
+			return
+		}
+		if page == 1 {
+			if total, err := strconv.Atoi(header.Get("X-WP-Total")); err == nil {
+				imp.updateJob(map[string]interface{}{"total_rows": total})
+			}
+		}
+
+		for _, wp := range products {
+			imp.importProduct(wp)
+			imp.processed++
+		}
+		imp.saveProgress()
+
+		if len(products) < wooImportPageSize {
+			break
+		}
+	}
+
+	now := time.Now()
+	imp.db.Model(&models.WooStore{}).Where("id = ?", imp.store.ID).Update("last_synced_at", now)
+	imp.finish("completed", "")
+	log.Printf("woo catalog import %d (store %d): %d created, %d matched, %d conflicts, %d failed in %s",
+		imp.jobID, imp.store.ID, imp.created, imp.matched, imp.conflicts, len(imp.issues)-imp.conflicts, time.Since(started))
+}
+
+// importProduct links or creates the local product for one Woo product and records the outcome.
+// Products created here already exist on Woo, so no product.created event is published for them.
+func (imp *wooCatalogImporter) importProduct(wp wooProduct) {
+	issue := func(msg string) {
+		imp.issues = append(imp.issues, importRowError{WooProductID: wp.ID, SKU: wp.SKU, Errors: []string{msg}})
+	}
+	if wp.Type != "simple" && wp.Type != "variable" {
+		issue(fmt.Sprintf("unsupported product type %q", wp.Type))
+		return
+	}
+
+	var variations []wooVariation
+	if wp.Type == "variable" && len(wp.Variations) > 0 {
+		var err error
+		if variations, err = imp.fetchVariations(wp.ID); err != nil {
+			issue(fmt.Sprintf("failed to fetch variations: %v", err))
+			return
+		}
+	}
+
+	// Already linked by an earlier import or a sync from Inventify.
+	var linked models.ProductWoo
+	err := imp.db.Joins("JOIN products ON products.id = product_woos.product_id AND products.deleted_at IS NULL").
+		Where("products.organization_id = ? AND product_woos.woo_product_id = ?", imp.orgID, wp.ID).
+		First(&linked).Error
+	if err == nil {
+		if err := linkWooVariations(imp.db, linked.ProductID, variations); err != nil {
+			issue(err.Error())
+			return
+		}
+		imp.matched++
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		issue(err.Error())
+		return
+	}
+
+	if sku := strings.TrimSpace(wp.SKU); sku != "" {
+		var local models.Product
+		err := imp.db.Preload("ProductWoo").Where("organization_id = ? AND sku = ?", imp.orgID, sku).First(&local).Error
+		switch {
+		case err == nil:
+			if local.ProductWoo.WooProductID != nil && *local.ProductWoo.WooProductID != wp.ID {
+				imp.conflicts++
+				issue(fmt.Sprintf("conflict: local product %d with this SKU is already linked to Woo product %d", local.ID, *local.ProductWoo.WooProductID))
+				return
+			}
+			if err := imp.db.Transaction(func(tx *gorm.DB) error {
+				return linkWooProduct(tx, imp.orgID, local.ID, wp, variations)
+			}); err != nil {
+				issue(err.Error())
+				return
+			}
+			imp.matched++
+			return
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			issue(err.Error())
+			return
+		}
+
+		taken, err := skuTakenInOrg(imp.db, imp.orgID, sku, 0, 0)
+		if err != nil {
+			issue(err.Error())
+			return
+		}
+		if taken {
+			imp.conflicts++
+			issue("conflict: SKU belongs to a variant of another local product")
+			return
+		}
+	}
+
+	req, imagePaths := imp.buildCreateReq(wp, variations)
+	if msg := req.validate(); msg != "" {
+		removeImportedImages(imagePaths)
+		issue(msg)
+		return
+	}
+	err = imp.db.Transaction(func(tx *gorm.DB) error {
+		product, err := createProductRecords(tx, imp.orgID, req, imagePaths)
+		if err != nil {
+			return err
+		}
+		if wp.Featured {
+			if err := tx.Model(&product).Update("is_featured", true).Error; err != nil {
+				return err
+			}
+		}
+		// variants were inserted in variation order
+		var variants []models.ProductVariant
+		if err := tx.Where("product_id = ?", product.ID).Order("id ASC").Find(&variants).Error; err != nil {
+			return err
+		}
+		for i := range variants {
+			if i < len(variations) {
+				if err := tx.Model(&variants[i]).Update("woo_variation_id", variations[i].ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return linkWooProduct(tx, imp.orgID, product.ID, wp, nil)
+	})
+	if err != nil {
+		removeImportedImages(imagePaths)
+		if errors.Is(err, errSKUTaken) || isUniqueViolation(err) {
+			imp.conflicts++
+			issue("conflict: " + err.Error())
+			return
+		}
+		issue(err.Error())
+		return
+	}
+	imp.created++
+}
+
+// buildCreateReq maps a Woo product (and its variations) onto createProductReq,
+// downloading gallery and variation images.
+func (imp *wooCatalogImporter) buildCreateReq(wp wooProduct, variations []wooVariation) (createProductReq, []string) {
+	req := createProductReq{
+		Name:             wp.Name,
+		ShortDescription: wp.ShortDescription,
+		Description:      wp.Description,
+		SKU:              strings.TrimSpace(wp.SKU),
+		CountryOfOrigin:  "IN",
+		RegularPrice:     parseWooPrice(wp.RegularPrice),
+		SalePrice:        parseWooPricePtr(wp.SalePrice),
+		WeightKg:         parseWooPricePtr(wp.Weight),
+		LengthCm:         parseWooPricePtr(wp.Dimensions.Length),
+		WidthCm:          parseWooPricePtr(wp.Dimensions.Width),
+		HeightCm:         parseWooPricePtr(wp.Dimensions.Height),
+		Woo: &wooSettings{
+			Enabled:           true,
+			CatalogVisibility: wp.CatalogVisibility,
+		},
+	}
+	if wp.StockQuantity != nil && *wp.StockQuantity > 0 {
+		req.StockQuantity = *wp.StockQuantity
+	}
+	if len(wp.Categories) > 0 {
+		req.CategoryName = wp.Categories[0].Name
+	}
+
+	var imagePaths []string
+	imageIndex := make(map[string]int)
+	addImage := func(src string) (int, bool) {
+		if idx, ok := imageIndex[src]; ok {
+			return idx, true
+		}
+		stored, err := imp.downloadImage(src)
+		if err != nil {
+			log.Printf("woo import %d: skipping image %s: %v", imp.jobID, src, err)
+			return 0, false
+		}
+		imagePaths = append(imagePaths, stored)
+		imageIndex[src] = len(imagePaths) - 1
+		return len(imagePaths) - 1, true
+	}
+	for _, img := range wp.Images {
+		addImage(img.Src)
+	}
+
+	if len(variations) == 0 {
+		return req, imagePaths
+	}
+	for _, a := range wp.Attributes {
+		if a.Variation {
+			req.Attributes = append(req.Attributes, productAttributeReq{Name: a.Name, Options: a.Options})
+		}
+	}
+	for _, v := range variations {
+		manage := v.ManageStock == true
+		variant := productVariantReq{
+			SKU:          strings.TrimSpace(v.SKU),
+			Attributes:   make(map[string]string, len(v.Attributes)),
+			RegularPrice: parseWooPrice(v.RegularPrice),
+			SalePrice:    parseWooPricePtr(v.SalePrice),
+			ManageStock:  &manage,
+		}
+		if v.StockQuantity != nil && *v.StockQuantity > 0 {
+			variant.StockQuantity = *v.StockQuantity
+		}
+		for _, a := range v.Attributes {
+			variant.Attributes[a.Name] = a.Option
+		}
+		// Woo leaves out the attributes an "Any ..." variation doesn't pin down
+		for _, a := range req.Attributes {
+			if _, ok := variant.Attributes[a.Name]; !ok {
+				variant.Attributes[a.Name] = ""
+			}
+		}
+		if v.Image != nil && v.Image.Src != "" {
+			if idx, ok := addImage(v.Image.Src); ok {
+				variant.ImageIndexes = []int{idx}
+			}
+		}
+		req.Variants = append(req.Variants, variant)
+	}
+	return req, imagePaths
+}
+
+func (imp *wooCatalogImporter) fetchVariations(productID int64) ([]wooVariation, error) {
+	var all []wooVariation
+	for page := 1; ; page++ {
+		var batch []wooVariation
+		q := url.Values{}
+		q.Set("per_page", strconv.Itoa(wooImportPageSize))
+		q.Set("page", strconv.Itoa(page))
+		if _, err := imp.client.GetJSON(fmt.Sprintf("/products/%d/variations", productID), q, &batch); err != nil {
+			return nil, err
+		}
+		all = append(all, batch...)
+		if len(batch) < wooImportPageSize {
+			return all, nil
+		}
+	}
+}
+
+// downloadImage stores a remote image under uploads/ and returns its served path.
+func (imp *wooCatalogImporter) downloadImage(src string) (string, error) {
+	resp, err := imp.client.HTTP.Get(src)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	ext := ""
+	if u, err := url.Parse(src); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+	default:
+		ext = ""
+		if exts, _ := mime.ExtensionsByType(resp.Header.Get("Content-Type")); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	if _, err := os.Stat(wooImportUploadDir); os.IsNotExist(err) {
+		os.Mkdir(wooImportUploadDir, 0755)
+	}
+	filePath := filepath.Join(wooImportUploadDir, uuid.New().String()+ext)
+	f, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, wooImageMaxBytes+1))
+	f.Close()
+	if err == nil && n > wooImageMaxBytes {
+		err = fmt.Errorf("image larger than %d bytes", wooImageMaxBytes)
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return "/" + filePath, nil
+}
+
+// removeImportedImages deletes images downloaded for a product that was then not created.
+func removeImportedImages(paths []string) {
+	for _, p := range paths {
+		if err := os.Remove(strings.TrimPrefix(p, "/")); err != nil && !os.IsNotExist(err) {
+			log.Printf("woo import: failed to remove image %s: %v", p, err)
+		}
+	}
+}
+
+func (imp *wooCatalogImporter) updateJob(fields map[string]interface{}) {
+	if err := imp.db.Model(&models.ImportJob{}).Where("id = ?", imp.jobID).Updates(fields).Error; err != nil {
+		log.Printf("woo import %d: failed to update job: %v", imp.jobID, err)
+	}
+}
+
+func (imp *wooCatalogImporter) saveProgress() {
+	issuesJSON, _ := json.Marshal(imp.issues)
+	imp.updateJob(map[string]interface{}{
+		"processed_rows": imp.processed,
+		"created_count":  imp.created,
+		"matched_count":  imp.matched,
+		"conflict_count": imp.conflicts,
+		"failed_count":   len(imp.issues) - imp.conflicts,
+		"row_errors":     datatypes.JSON(issuesJSON),
+	})
+}
+
+func (imp *wooCatalogImporter) finish(status, errMsg string) {
+	imp.saveProgress()
+	fields := map[string]interface{}{"status": status, "finished_at": time.Now()}
+	if errMsg != "" {
+		fields["error_message"] = errMsg
+	}
+	imp.updateJob(fields)
+}
+
+/* ----------------------------
+   Linking helpers
+   ---------------------------- */
+
+// linkWooProduct points the local product's ProductWoo at the remote product, mirrors its
+// status and enables the woocommerce channel. The type follows the local variant set.
+func linkWooProduct(tx *gorm.DB, orgID, productID uint, wp wooProduct, variations []wooVariation) error {
+	var pw models.ProductWoo
+	err := tx.Where("product_id = ?", productID).First(&pw).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pw = models.ProductWoo{ProductID: productID, CatalogVisibility: "visible"}
+	} else if err != nil {
+		return err
+	}
+	wooID := wp.ID
+	now := time.Now()
+	pw.WooProductID = &wooID
+	pw.Status = wp.Status
+	var variantCount int64
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&variantCount).Error; err != nil {
+		return err
+	}
+	pw.Type = "simple"
+	if variantCount > 0 {
+		pw.Type = "variable"
+	}
+	if wp.CatalogVisibility != "" {
+		pw.CatalogVisibility = wp.CatalogVisibility
+	}
+	pw.LastPublishedAt = &now
+	if err := tx.Save(&pw).Error; err != nil {
+		return fmt.Errorf("failed to link Woo product: %w", err)
+	}
+	if err := setProductChannelEnabled(tx, orgID, productID, "woocommerce", true); err != nil {
+		return err
+	}
+	return linkWooVariations(tx, productID, variations)
+}
+
+// linkWooVariations sets WooVariationID on local variants with a matching SKU.
+func linkWooVariations(tx *gorm.DB, productID uint, variations []wooVariation) error {
+	for _, v := range variations {
+		sku := strings.TrimSpace(v.SKU)
+		if sku == "" {
+			continue
+		}
+		if err := tx.Model(&models.ProductVariant{}).
+			Where("product_id = ? AND sku = ?", productID, sku).
+			Update("woo_variation_id", v.ID).Error; err != nil {
+			return fmt.Errorf("failed to link variation %d: %w", v.ID, err)
+		}
+	}
+	return nil
+}
+
+func parseWooPrice(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+func parseWooPricePtr(s string) *float64 {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
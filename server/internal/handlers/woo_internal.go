@@ -1,16 +1,14 @@
 package handlers
 
 import (
-	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/RvShivam/inventify/internal/channels"
 	"github.com/RvShivam/inventify/internal/crypto"
 	"github.com/RvShivam/inventify/internal/models"
 	"github.com/gin-gonic/gin"
@@ -23,11 +21,6 @@ type registerWebhooksInternalReq struct {
 	Topics      []string `json:"topics,omitempty"`
 }
 
-type syncCategoriesResp struct {
-	Imported int `json:"imported"`
-	Skipped  int `json:"skipped"`
-}
-
 // SyncWooCategories: fetches categories from the Woo store and writes Category + CategoryMapping.
 // Protected endpoint for worker usage; ensure it's mounted with RequireServiceToken middleware.
 func SyncWooCategories(db *gorm.DB) gin.HandlerFunc {
@@ -44,79 +37,24 @@ func SyncWooCategories(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// decrypt keys
-		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
-		ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
-			return
-		}
-		cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+		client, err := channels.NewWooClientForStore(store)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
 			return
 		}
 
 		// fetch categories from Woo
-		wooCats, err := fetchWooCategories(store.SiteURL, ck, cs, store.VerifySSL)
+		wooCats, err := client.ListCategories()
 		if err != nil {
 			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch categories", "detail": err.Error()})
 			return
 		}
 
-		imported := 0
-		skipped := 0
-		// iterate and upsert into Category & CategoryMapping
-		for _, wc := range wooCats {
-			// find or create Category by name (case-insensitive)
-			var cat models.Category
-			name := strings.TrimSpace(wc.Name)
-			if name == "" {
-				// skip empty names
-				skipped++
-				continue
-			}
-			err := db.Where("LOWER(name) = LOWER(?)", name).First(&cat).Error
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				// create new category
-				cat = models.Category{
-					Name:        name,
-					Description: wc.Description,
-				}
-				if err := db.Create(&cat).Error; err != nil {
-					// skip on error but continue
-					skipped++
-					continue
-				}
-			} else if err != nil {
-				// DB error
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "detail": err.Error()})
-				return
-			}
-
-			// create mapping if not exists
-			var mapping models.CategoryMapping
-			if err := db.Where("category_id = ? AND channel = ? AND channel_category_id = ?", cat.ID, "woocommerce", fmt.Sprintf("%d", wc.ID)).First(&mapping).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-				// not found -> create
-				mapping = models.CategoryMapping{
-					CategoryID:        cat.ID,
-					Channel:           "woocommerce",
-					ChannelCategoryID: fmt.Sprintf("%d", wc.ID),
-					IsDefault:         false,
-				}
-				if err := db.Create(&mapping).Error; err != nil {
-					// ignore duplicate constraint or other transient error and continue
-					skipped++
-					continue
-				}
-				imported++
-			} else if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "detail": err.Error()})
-				return
-			} else {
-				// mapping exists -> skip
-				skipped++
-			}
+		// upsert into Category & CategoryMapping
+		res, err := channels.ImportWooCategories(db, wooCats)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "detail": err.Error()})
+			return
 		}
 
 		// update store last synced
@@ -124,7 +62,7 @@ func SyncWooCategories(db *gorm.DB) gin.HandlerFunc {
 		store.LastSyncedAt = &now
 		_ = db.Save(&store)
 
-		c.JSON(http.StatusOK, syncCategoriesResp{Imported: imported, Skipped: skipped})
+		c.JSON(http.StatusOK, res)
 	}
 }
 
@@ -167,17 +105,12 @@ func InternalRegisterWebhooks(db *gorm.DB) gin.HandlerFunc {
 			topics = []string{"product.created", "product.updated", "product.deleted", "order.created", "order.updated"}
 		}
 
-		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
-		ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
-			return
-		}
-		cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+		client, err := channels.NewWooClientForStore(store)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "decrypt failed"})
 			return
 		}
+		appKey := []byte(os.Getenv("APP_SECRET_KEY"))
 
 		created := make([]models.WooStoreWebhook, 0, len(topics))
 		skipped := 0
@@ -197,16 +130,16 @@ func InternalRegisterWebhooks(db *gorm.DB) gin.HandlerFunc {
 
 			// create webhook on Woo
 			secret := randomSecret(32)
-			webhookID, err := createWooWebhook(store.SiteURL, ck, cs, deliveryURL, topic, secret, store.VerifySSL)
+			webhookID, err := client.CreateWebhook(deliveryURL, topic, secret)
 			if err != nil {
 				// Attempt to handle "already exists" scenario
 				// 1. Check if it exists on Woo
-				existingID, findErr := getWooWebhookID(store.SiteURL, ck, cs, topic, deliveryURL, store.VerifySSL)
+				existingID, findErr := client.FindWebhook(topic, deliveryURL)
 				if findErr == nil && existingID != "" {
 					// 2. Delete it
-					if delErr := deleteWooWebhook(store.SiteURL, ck, cs, existingID, store.VerifySSL); delErr == nil {
+					if delErr := client.DeleteWebhook(existingID); delErr == nil {
 						// 3. Retry creation
-						webhookID, err = createWooWebhook(store.SiteURL, ck, cs, deliveryURL, topic, secret, store.VerifySSL)
+						webhookID, err = client.CreateWebhook(deliveryURL, topic, secret)
 					}
 				}
 				
@@ -246,50 +179,3 @@ func InternalRegisterWebhooks(db *gorm.DB) gin.HandlerFunc {
 		})
 	}
 }
-
-/* ----------------------------
-   Helper: fetch categories from Woo
-   ---------------------------- */
-
-// wooCategory is a small struct matching the Woo REST response for categories
-type wooCategory struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Slug        string `json:"slug"`
-	Description string `json:"description"`
-	Parent      int    `json:"parent"`
-	Count       int    `json:"count"`
-}
-
-// fetchWooCategories retrieves categories list from the Woo REST API
-func fetchWooCategories(site, consumerKey, consumerSecret string, verifySSL bool) ([]wooCategory, error) {
-	url := fmt.Sprintf("%s/wp-json/wc/v3/products/categories?per_page=100", strings.TrimRight(site, "/"))
-	req, _ := http.NewRequest("GET", url, nil)
-	req.SetBasicAuth(consumerKey, consumerSecret)
-
-	// support simple HTTP client with optional insecure skip verify
-	client := &http.Client{Timeout: 15 * time.Second}
-	if !verifySSL {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("woo categories returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var out []wooCategory
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
-	}
-	return out, nil
-}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/RvShivam/inventify/internal/ondc"
+)
+
+// RequireBecknSignature verifies the Authorization signature of inbound ONDC requests
+// (and X-Gateway-Authorization when a gateway relayed the request). Failures are answered
+// with a Beckn NACK and 401. The raw body is put back for the handler.
+func RequireBecknSignature(cfg *ondc.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ondc.Nack(ondc.ErrorTypeJSONSchema, "10000", "failed to read body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if cfg.SkipVerify {
+			c.Next()
+			return
+		}
+
+		auth, err := ondc.Verify(c.GetHeader("Authorization"), body, cfg.Keys, time.Now())
+		if err != nil {
+			log.Printf("ondc: rejected %s: %v", c.Request.URL.Path, err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ondc.Nack(ondc.ErrorTypePolicy, "10001", "invalid signature"))
+			return
+		}
+		if gw := c.GetHeader("X-Gateway-Authorization"); gw != "" {
+			if _, err := ondc.Verify(gw, body, cfg.Keys, time.Now()); err != nil {
+				log.Printf("ondc: rejected gateway signature on %s: %v", c.Request.URL.Path, err)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, ondc.Nack(ondc.ErrorTypePolicy, "10001", "invalid gateway signature"))
+				return
+			}
+		}
+
+		// the signer, so handlers can check it against context.bap_id
+		c.Set("beckn_subscriber_id", auth.SubscriberID)
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ImportJob tracks a background product import so the client can poll its progress.
+type ImportJob struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"index;not null"`
+	UserID         uint   `gorm:"index"`
+	Source         string `gorm:"size:30;not null"`          // 'csv', 'xlsx', 'woocommerce'
+	WooStoreID     *uint  `gorm:"index"`                     // set for 'woocommerce' imports
+	Status         string `gorm:"size:20;default:'pending'"` // pending, running, completed, failed
+	Filename       string
+	Mapping        datatypes.JSON `gorm:"type:jsonb"` // field -> column header
+
+	TotalRows     int `gorm:"default:0"`
+	ProcessedRows int `gorm:"default:0"`
+	CreatedCount  int `gorm:"default:0"`
+	FailedCount   int `gorm:"default:0"`
+	MatchedCount  int `gorm:"default:0"` // existing local products linked by SKU
+	ConflictCount int `gorm:"default:0"` // SKU matches that could not be linked
+
+	RowErrors    datatypes.JSON `gorm:"type:jsonb"` // [{row, sku, errors}]
+	ErrorMessage string
+
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
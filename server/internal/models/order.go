@@ -45,6 +45,7 @@ type OrderAddress struct {
 	Country   string `json:"country"`
 	Email     string `json:"email"`
 	Phone     string `json:"phone"`
+	GPS       string `json:"gps,omitempty"` // "lat,lng" when the channel sends one (ONDC)
 }
 
 type OrderLineItem struct {
@@ -61,3 +62,42 @@ type OrderLineItem struct {
 	SKU         string  `json:"sku"`
 	Price       float64 `json:"price"`
 }
+
+// OrderItem is one line of an order, normalized out of Order.LineItems and linked to the
+// local product it was sold from (nil when the channel product isn't linked).
+// Rewritten whenever the order's lines change.
+type OrderItem struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	OrderID            uuid.UUID `gorm:"type:uuid;index;not null" json:"order_id"`
+	OrganizationID     uint      `gorm:"index;not null" json:"organization_id"`
+	Position           int       `gorm:"not null;default:0" json:"position"`
+	ExternalLineID     int64     `json:"external_line_id"`     // e.g. Woo line item ID
+	ChannelProductID   int64     `json:"channel_product_id"`   // product ID as the channel sent it
+	ChannelVariationID int64     `json:"channel_variation_id"` // variation ID as the channel sent it
+	ProductID          *uint     `gorm:"index" json:"product_id"`
+	VariantID          *uint     `gorm:"index" json:"variant_id"`
+	SKU                string    `json:"sku"`
+	Name               string    `json:"name"`
+	Quantity           int       `gorm:"not null" json:"quantity"`
+	UnitPrice          float64   `json:"unit_price"`
+	Subtotal           float64   `json:"subtotal"`
+	Tax                float64   `json:"tax"`
+	Total              float64   `json:"total"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// OrderFlag puts an order in the review queue, e.g. when it asked for more stock than
+// was on hand. Open flags stay in the queue until someone resolves them.
+type OrderFlag struct {
+	gorm.Model
+	OrganizationID uint           `gorm:"index;not null"`
+	OrderID        uuid.UUID      `gorm:"type:uuid;index;not null"`
+	Reason         string         `gorm:"size:50;not null"`                      // oversell_rejected, oversell_clamped, backordered, duplicate_order
+	Policy         string         `gorm:"size:30"`                               // oversell policy in force
+	Details        datatypes.JSON `gorm:"type:jsonb"`                            // per-line requested/on hand/deducted/short
+	OwedQty        int            `gorm:"not null;default:0"`                    // units still to ship (backordered)
+	Status         string         `gorm:"size:20;not null;default:'open';index"` // open, resolved
+	ResolvedAt     *time.Time
+	ResolvedBy     *uint
+	Note           string `gorm:"type:text"`
+}
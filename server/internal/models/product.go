@@ -52,8 +52,9 @@ type Product struct {
 	SalePrice    *float64 `gorm:"type:decimal(10,2)"`
 
 	// Stock
-	ManageStock   bool `gorm:"default:true"`
-	StockQuantity int  `gorm:"default:0;not null"`
+	ManageStock       bool `gorm:"default:true"`
+	StockQuantity     int  `gorm:"default:0;not null"`
+	LowStockThreshold *int // nil: the organization's LowStockThreshold
 
 	// Weight & Dimensions
 	WeightKg *float64
@@ -68,6 +69,8 @@ type Product struct {
 	ReviewsAllowed bool `gorm:"default:true"`
 
 	Images            []ProductImage
+	Attributes        []ProductAttribute
+	Variants          []ProductVariant
 	ProductWoo        ProductWoo
 	ProductONDC       ProductONDC
 	ChannelOverrides  []ProductChannelOverride
@@ -80,12 +83,47 @@ type Product struct {
 type ProductImage struct {
 	gorm.Model
 	ProductID uint   `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	VariantID *uint  `gorm:"index"` // set when the image belongs to a specific variant
 	Src       string `gorm:"not null"`
 	Alt       string
 	Position  int  `gorm:"default:0"`
 	IsPrimary bool `gorm:"default:false"`
 }
 
+//
+// ─────────────────────────────────────────────────────────────
+// PRODUCT VARIANTS (size/colour etc. on a variable product)
+// ─────────────────────────────────────────────────────────────
+//
+
+// ProductAttribute is a variation axis on a variable product, e.g. Size: [S, M, L].
+type ProductAttribute struct {
+	gorm.Model
+	ProductID uint           `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name      string         `gorm:"not null"`
+	Options   datatypes.JSON `gorm:"type:jsonb"` // ["S","M","L"]
+	Position  int            `gorm:"default:0"`
+}
+
+// ProductVariant is a sellable child of a variable product with its own SKU, price and stock.
+type ProductVariant struct {
+	gorm.Model
+	ProductID  uint           `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	SKU        string         `gorm:"index"`
+	Attributes datatypes.JSON `gorm:"type:jsonb"` // {"Size":"M","Colour":"Red"}
+
+	RegularPrice float64  `gorm:"type:decimal(10,2);default:0;not null"`
+	SalePrice    *float64 `gorm:"type:decimal(10,2)"`
+
+	ManageStock   bool `gorm:"default:true"`
+	StockQuantity int  `gorm:"default:0;not null"`
+
+	WooVariationID *int64 `gorm:"index"`
+	ONDCItemID     string
+
+	Images []ProductImage `gorm:"foreignKey:VariantID"`
+}
+
 //
 // ─────────────────────────────────────────────────────────────
 // CHANNELS (Normalized, every org has channels)
@@ -188,6 +226,9 @@ type ProductONDC struct {
 	Cancellable bool `gorm:"default:true"`
 	Warranty    string
 
+	CustomPriceEnabled bool `gorm:"default:false"`
+	CustomPriceValue   *float64
+
 	LastPublishedAt *time.Time
 }
 
@@ -225,13 +266,17 @@ type SellerLocation struct {
 	CityCode       string
 	Phone          string
 	IsActive       bool `gorm:"default:true"`
+	IsDefault      bool `gorm:"default:false"` // one per org; unmatched stock goes here
 }
 
+// ProductLocationStock is a stock line's on-hand at one location. Once a product (or
+// variant) has rows, its StockQuantity is their sum.
 type ProductLocationStock struct {
 	gorm.Model
-	ProductID  uint `gorm:"index;not null"`
-	LocationID uint `gorm:"index;not null"`
-	StockQty   int  `gorm:"default:0"`
+	ProductID  uint  `gorm:"index;not null"`
+	VariantID  *uint `gorm:"index"`
+	LocationID uint  `gorm:"index;not null"`
+	StockQty   int   `gorm:"default:0"`
 	LastSynced *time.Time
 }
 
@@ -244,6 +289,7 @@ type ProductLocationStock struct {
 type InventoryReservation struct {
 	ID          string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	ProductID   uint      `gorm:"index;not null"`
+	VariantID   *uint     `gorm:"index"`
 	Source      string    `gorm:"size:64;not null"`
 	ContextID   string    `gorm:"not null;index"` // for idempotency
 	ReservedQty int       `gorm:"not null"`
@@ -254,12 +300,15 @@ type InventoryReservation struct {
 }
 
 type InventoryMovement struct {
-	ID        string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	ProductID uint   `gorm:"index;not null"`
-	ChangeQty int    `gorm:"not null"`
-	Reason    string
-	Ref       string
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID          string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ProductID   uint   `gorm:"index;not null"`
+	VariantID   *uint  `gorm:"index"`
+	LocationID  *uint  `gorm:"index"` // set when the change hit a location's stock
+	ChangeQty   int    `gorm:"not null"`
+	Reason      string
+	Ref         string
+	ActorUserID *uint     `gorm:"index"` // the user who made the change; nil for channel and system changes
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
 }
 
 //
@@ -279,3 +328,24 @@ type ChannelPublishLog struct {
 	ErrorMessage      string
 	AttemptAt         time.Time `gorm:"autoCreateTime"`
 }
+
+//
+// ─────────────────────────────────────────────────────────────
+// CHANNEL SYNC DECISIONS (inbound changes from channels)
+// ─────────────────────────────────────────────────────────────
+//
+
+// ProductSyncDecision records how an inbound channel change (e.g. a Woo product webhook)
+// was reconciled with the local product under the org's conflict policy.
+type ProductSyncDecision struct {
+	gorm.Model
+	OrganizationID   uint   `gorm:"index;not null"`
+	ProductID        uint   `gorm:"index;not null"`
+	Channel          string `gorm:"size:50;not null"` // 'woocommerce'
+	Topic            string `gorm:"size:50"`          // 'product.updated', 'product.deleted'
+	Policy           string `gorm:"size:30"`          // inventify_wins, woo_wins, newest_wins
+	Decision         string `gorm:"size:30;not null"` // applied_remote, kept_local, unlinked
+	RemoteModifiedAt *time.Time
+	LocalUpdatedAt   *time.Time
+	Changes          datatypes.JSON `gorm:"type:jsonb"` // {"field": {"local": .., "remote": ..}}
+}
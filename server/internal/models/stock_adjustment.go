@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockAdjustment is a batch of manual stock corrections (damage, theft, a count, stock
+// found). It applies at once, or waits as pending_approval when a line moves more than the
+// org's AdjustmentApprovalThreshold and the requester isn't an admin.
+type StockAdjustment struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"index;not null"`
+	Status         string `gorm:"size:20;not null;default:'pending_approval';index"` // pending_approval, applied, rejected
+	Note           string `gorm:"type:text"`
+	RequestedBy    *uint
+	ReviewedBy     *uint // the admin who approved or rejected it
+	ReviewedAt     *time.Time
+	ReviewNote     string `gorm:"type:text"`
+	AppliedAt      *time.Time
+
+	Lines []StockAdjustmentLine `gorm:"foreignKey:AdjustmentID"`
+}
+
+// StockAdjustmentLine corrects one product (or variant), at one location or across the
+// line's total, either to an absolute quantity (set) or by a delta.
+type StockAdjustmentLine struct {
+	ID           uint   `gorm:"primaryKey"`
+	AdjustmentID uint   `gorm:"index;not null"`
+	ProductID    uint   `gorm:"index;not null"`
+	VariantID    *uint  `gorm:"index"`
+	LocationID   *uint  `gorm:"index"`
+	Reason       string `gorm:"size:30;not null"` // damage, theft, count_correction, found
+	Mode         string `gorm:"size:10;not null"` // set, delta
+	Quantity     int    `gorm:"not null"`         // the target for set, the change for delta
+	ChangeQty    *int   // what the line actually moved by; set when applied
+	CreatedAt    time.Time
+}
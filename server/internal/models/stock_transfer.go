@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockTransfer moves stock from one SellerLocation to another. Dispatching takes the lines
+// out of the source location (they are then in transit, part of no location's on-hand and
+// so not sellable); receiving puts what actually arrived into the destination.
+type StockTransfer struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"index;not null"`
+	FromLocationID uint   `gorm:"index;not null"`
+	ToLocationID   uint   `gorm:"index;not null"`
+	Status         string `gorm:"size:20;not null;default:'draft';index"` // draft, dispatched, received, cancelled
+	Note           string `gorm:"type:text"`
+	CreatedBy      *uint
+	DispatchedAt   *time.Time
+	DispatchedBy   *uint
+	ReceivedAt     *time.Time
+	ReceivedBy     *uint
+	CancelledAt    *time.Time
+
+	Lines []StockTransferLine `gorm:"foreignKey:TransferID"`
+}
+
+// StockTransferLine is a quantity of a product (or one of its variants) on a transfer.
+type StockTransferLine struct {
+	ID          uint  `gorm:"primaryKey"`
+	TransferID  uint  `gorm:"index;not null"`
+	ProductID   uint  `gorm:"index;not null"`
+	VariantID   *uint `gorm:"index"`
+	Quantity    int   `gorm:"not null"` // sent
+	ReceivedQty *int  // what arrived; set on receipt, may differ from Quantity
+	CreatedAt   time.Time
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stocktake is a count of part of the stock: one location's, one category's, or one
+// category's at one location (without a location it counts the lines' whole on-hand).
+// Opening it freezes each line's expected quantity; staff then submit counts until an
+// admin approves it, which posts the differences as a stock adjustment.
+type Stocktake struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"index;not null"`
+	LocationID     *uint  `gorm:"index"`
+	CategoryID     *uint  `gorm:"index"`
+	Status         string `gorm:"size:20;not null;default:'counting';index"` // counting, approved, cancelled
+	Note           string `gorm:"type:text"`
+	CreatedBy      *uint
+	FrozenAt       time.Time `gorm:"not null"` // when the expected quantities were taken
+	ApprovedAt     *time.Time
+	ApprovedBy     *uint
+	CancelledAt    *time.Time
+	AdjustmentID   *uint // the StockAdjustment the differences were posted as
+
+	Lines []StocktakeLine `gorm:"foreignKey:StocktakeID"`
+}
+
+// StocktakeLine is a product (or variant) on a stocktake, with what the stock said when
+// the count opened and what has been counted since.
+type StocktakeLine struct {
+	ID          uint   `gorm:"primaryKey"`
+	StocktakeID uint   `gorm:"index;not null"`
+	ProductID   uint   `gorm:"index;not null"`
+	VariantID   *uint  `gorm:"index"`
+	SKU         string `gorm:"index"` // the variant's, else the product's; what scanners send
+	ExpectedQty int    `gorm:"not null"`
+	CountedQty  *int   // the sum of the line's counts; nil until first counted
+	CreatedAt   time.Time
+}
+
+// StocktakeCount is one submission of a counted quantity for a line. A line's counts add
+// up, so several people can count the same product on different shelves, and a negative
+// count takes back a mistaken scan.
+type StocktakeCount struct {
+	ID          uint `gorm:"primaryKey"`
+	StocktakeID uint `gorm:"index;not null"`
+	LineID      uint `gorm:"index;not null"`
+	UserID      *uint
+	Quantity    int `gorm:"not null"`
+	CreatedAt   time.Time
+}
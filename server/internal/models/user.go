@@ -29,6 +29,13 @@ type Organization struct {
 	OwnerID      uint
 	ReferralCode string `gorm:"unique"`
 	Users        []User `gorm:"many2many:organization_members;"`
+
+	// Products at or below this stock count as low stock, unless they set their own.
+	LowStockThreshold int `gorm:"default:10;not null"`
+
+	// Stock adjustments by non-admins that move a line by more than this many units wait
+	// for an admin's approval. Nil means they never do.
+	AdjustmentApprovalThreshold *int
 }
 
 type OrganizationMember struct {
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// WebhookInbox records every verified channel webhook delivery before it is handed to the
+// consumers, so retried deliveries can be dropped and failed ones inspected and replayed.
+// A delivery is identified by the channel's delivery ID plus a hash of the raw body.
+type WebhookInbox struct {
+	gorm.Model
+	OrganizationID uint           `gorm:"index"`
+	Channel        string         `gorm:"size:50;not null"`
+	DeliveryID     string         `gorm:"size:100;not null;default:''"` // X-WC-Webhook-Delivery-ID for Woo
+	PayloadHash    string         `gorm:"size:64;not null"`             // sha256 of the raw body, hex
+	Topic          string         `gorm:"size:100"`
+	Source         datatypes.JSON `gorm:"type:jsonb"` // the registration that delivered it (e.g. WooStoreWebhook)
+	Payload        datatypes.JSON `gorm:"type:jsonb"`
+	Status         string         `gorm:"size:20;not null;default:'received';index"` // received, processing, processed, failed
+	Attempts       int            `gorm:"default:0"`
+	DuplicateCount int            `gorm:"default:0"` // redeliveries dropped at the receiver
+	LastError      string         `gorm:"type:text"`
+	LastSeenAt     *time.Time
+	ProcessedAt    *time.Time
+}
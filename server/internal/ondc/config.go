@@ -0,0 +1,143 @@
+// Package ondc implements the seller (BPP) side of the ONDC Beckn protocol:
+// message types, request signing/verification and the signed HTTP client for callbacks.
+package ondc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config is the seller app's identity on the network, read from ONDC_* env vars:
+//
+//	ONDC_SUBSCRIBER_ID          our bpp_id (required to enable ONDC)
+//	ONDC_SUBSCRIBER_URL         our bpp_uri, where buyers send /search etc. (e.g. https://host/ondc)
+//	ONDC_UNIQUE_KEY_ID          id of our signing key in the registry
+//	ONDC_SIGNING_PRIVATE_KEY    base64 ed25519 seed or private key
+//	ONDC_REGISTRY_URL           registry for looking up buyer keys (optional)
+//	ONDC_TRUSTED_KEYS           static buyer keys "sub|ukid=base64pub;..." (optional)
+//	ONDC_TRUSTED_URLS           static buyer subscriber URLs "sub=https://...;..." (optional)
+//	ONDC_SKIP_SIGNATURE_VERIFY  "true" accepts unsigned requests (local development only)
+type Config struct {
+	SubscriberID  string
+	SubscriberURL string
+	UniqueKeyID   string
+	PrivateKey    ed25519.PrivateKey
+	Keys          KeyResolver
+	Subscribers   SubscriberResolver
+	SkipVerify    bool
+	SignatureTTL  time.Duration
+}
+
+// ErrNotConfigured means ONDC_SUBSCRIBER_ID is unset, i.e. ONDC is switched off.
+var ErrNotConfigured = errors.New("ONDC is not configured (ONDC_SUBSCRIBER_ID unset)")
+
+// LoadConfig reads the ONDC_* env vars.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		SubscriberID:  strings.TrimSpace(os.Getenv("ONDC_SUBSCRIBER_ID")),
+		SubscriberURL: strings.TrimRight(strings.TrimSpace(os.Getenv("ONDC_SUBSCRIBER_URL")), "/"),
+		UniqueKeyID:   strings.TrimSpace(os.Getenv("ONDC_UNIQUE_KEY_ID")),
+		SkipVerify:    os.Getenv("ONDC_SKIP_SIGNATURE_VERIFY") == "true",
+		SignatureTTL:  5 * time.Minute,
+	}
+	if cfg.SubscriberID == "" {
+		return nil, ErrNotConfigured
+	}
+	if cfg.SubscriberURL == "" || cfg.UniqueKeyID == "" {
+		return nil, errors.New("ONDC_SUBSCRIBER_URL and ONDC_UNIQUE_KEY_ID are required")
+	}
+	key, err := ParsePrivateKey(os.Getenv("ONDC_SIGNING_PRIVATE_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("ONDC_SIGNING_PRIVATE_KEY: %w", err)
+	}
+	cfg.PrivateKey = key
+
+	var chain KeyChain
+	var subscribers SubscriberChain
+	if s := os.Getenv("ONDC_TRUSTED_KEYS"); s != "" {
+		static, err := ParseStaticKeys(s)
+		if err != nil {
+			return nil, fmt.Errorf("ONDC_TRUSTED_KEYS: %w", err)
+		}
+		chain = append(chain, static)
+	}
+	if s := os.Getenv("ONDC_TRUSTED_URLS"); s != "" {
+		static, err := ParseStaticURLs(s)
+		if err != nil {
+			return nil, fmt.Errorf("ONDC_TRUSTED_URLS: %w", err)
+		}
+		subscribers = append(subscribers, static)
+	}
+	if u := strings.TrimSpace(os.Getenv("ONDC_REGISTRY_URL")); u != "" {
+		registry := NewRegistryKeys(u)
+		chain = append(chain, registry)
+		subscribers = append(subscribers, registry)
+	}
+	if len(chain) == 0 && !cfg.SkipVerify {
+		return nil, errors.New("set ONDC_REGISTRY_URL or ONDC_TRUSTED_KEYS to verify buyer signatures")
+	}
+	if len(subscribers) == 0 && !cfg.SkipVerify {
+		return nil, errors.New("set ONDC_REGISTRY_URL or ONDC_TRUSTED_URLS to know where buyer callbacks go")
+	}
+	cfg.Keys = chain
+	cfg.Subscribers = subscribers
+	return cfg, nil
+}
+
+// PublicKey is our signing public key, base64 (what goes in the registry).
+func (c *Config) PublicKey() string {
+	return EncodePublicKey(c.PrivateKey.Public().(ed25519.PublicKey))
+}
+
+// CallbackURL is where bapID's callbacks go: its subscriber URL in the registry (or
+// ONDC_TRUSTED_URLS), never the request's own bap_uri, so a request can't point our signed
+// callbacks at another host. With signature checks off (local development) an unknown
+// buyer's bap_uri is used as-is.
+func (c *Config) CallbackURL(bapID, bapURI string) (string, error) {
+	url, err := c.Subscribers.SubscriberURL(bapID)
+	if err == nil {
+		return url, nil
+	}
+	if c.SkipVerify && errors.Is(err, ErrUnknownSubscriber) {
+		return bapURI, nil
+	}
+	return "", fmt.Errorf("subscriber url of %s: %w", bapID, err)
+}
+
+// Callback POSTs a signed message to {baseURI}/{action}, e.g. the BAP's /on_search.
+func (c *Config) Callback(baseURI, action string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(baseURI, "/")+"/"+action, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", Sign(body, c.SubscriberID, c.UniqueKeyID, c.PrivateKey, time.Now(), c.SignatureTTL))
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s callback failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s callback returned %d: %s", action, resp.StatusCode, string(respBody))
+	}
+	var ack Response
+	if err := json.Unmarshal(respBody, &ack); err == nil && ack.Message.Ack.Status == AckStatusNACK {
+		return fmt.Errorf("%s callback was NACKed: %v", action, ack.Error)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package ondc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownKey        = errors.New("unknown subscriber key")
+	ErrUnknownSubscriber = errors.New("unknown subscriber")
+)
+
+// KeyResolver finds a network participant's signing public key.
+type KeyResolver interface {
+	PublicKey(subscriberID, uniqueKeyID string) (ed25519.PublicKey, error)
+}
+
+// SubscriberResolver finds a network participant's registered subscriber URL, where its
+// callbacks go.
+type SubscriberResolver interface {
+	SubscriberURL(subscriberID string) (string, error)
+}
+
+// StaticKeys is a fixed key set keyed by "subscriber_id|unique_key_id".
+type StaticKeys map[string]ed25519.PublicKey
+
+func (k StaticKeys) PublicKey(subscriberID, uniqueKeyID string) (ed25519.PublicKey, error) {
+	if pub, ok := k[subscriberID+"|"+uniqueKeyID]; ok {
+		return pub, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// ParseStaticKeys parses "sub|ukid=base64pub;sub2|ukid2=base64pub" (the ONDC_TRUSTED_KEYS format).
+func ParseStaticKeys(s string) (StaticKeys, error) {
+	keys := StaticKeys{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, b64, ok := strings.Cut(entry, "=")
+		if !ok || !strings.Contains(id, "|") {
+			return nil, fmt.Errorf("malformed trusted key %q", entry)
+		}
+		pub, err := ParsePublicKey(b64)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %s: %w", id, err)
+		}
+		keys[strings.TrimSpace(id)] = pub
+	}
+	return keys, nil
+}
+
+// StaticURLs is a fixed subscriber_id -> subscriber URL set.
+type StaticURLs map[string]string
+
+func (u StaticURLs) SubscriberURL(subscriberID string) (string, error) {
+	if url, ok := u[subscriberID]; ok {
+		return url, nil
+	}
+	return "", ErrUnknownSubscriber
+}
+
+// ParseStaticURLs parses "sub=https://buyer.example.com/beckn;..." (the ONDC_TRUSTED_URLS format).
+func ParseStaticURLs(s string) (StaticURLs, error) {
+	urls := StaticURLs{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, url, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(id) == "" || !strings.HasPrefix(strings.TrimSpace(url), "http") {
+			return nil, fmt.Errorf("malformed trusted url %q", entry)
+		}
+		urls[strings.TrimSpace(id)] = strings.TrimSpace(url)
+	}
+	return urls, nil
+}
+
+// RegistryKeys looks keys and subscriber URLs up in an ONDC registry (POST {url}/lookup)
+// and caches them.
+type RegistryKeys struct {
+	URL    string
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]registryEntry
+	urls  map[string]registryURL
+}
+
+type registryEntry struct {
+	key     ed25519.PublicKey
+	fetched time.Time
+}
+
+type registryURL struct {
+	url     string
+	fetched time.Time
+}
+
+// registryRecord is one entry of a /lookup answer.
+type registryRecord struct {
+	SubscriberID     string `json:"subscriber_id"`
+	SubscriberURL    string `json:"subscriber_url"`
+	UniqueKeyID      string `json:"ukId"`
+	SigningPublicKey string `json:"signing_public_key"`
+}
+
+const registryCacheTTL = 10 * time.Minute
+
+func NewRegistryKeys(url string) *RegistryKeys {
+	return &RegistryKeys{
+		URL:    strings.TrimRight(url, "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  map[string]registryEntry{},
+		urls:   map[string]registryURL{},
+	}
+}
+
+func (r *RegistryKeys) PublicKey(subscriberID, uniqueKeyID string) (ed25519.PublicKey, error) {
+	id := subscriberID + "|" + uniqueKeyID
+	r.mu.Lock()
+	if e, ok := r.cache[id]; ok && time.Since(e.fetched) < registryCacheTTL {
+		r.mu.Unlock()
+		return e.key, nil
+	}
+	r.mu.Unlock()
+
+	entries, err := r.lookup(map[string]string{"subscriber_id": subscriberID, "ukId": uniqueKeyID})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.SubscriberID != subscriberID || (e.UniqueKeyID != "" && e.UniqueKeyID != uniqueKeyID) {
+			continue
+		}
+		pub, err := ParsePublicKey(e.SigningPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		r.cache[id] = registryEntry{key: pub, fetched: time.Now()}
+		r.mu.Unlock()
+		return pub, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (r *RegistryKeys) SubscriberURL(subscriberID string) (string, error) {
+	r.mu.Lock()
+	if e, ok := r.urls[subscriberID]; ok && time.Since(e.fetched) < registryCacheTTL {
+		r.mu.Unlock()
+		return e.url, nil
+	}
+	r.mu.Unlock()
+
+	entries, err := r.lookup(map[string]string{"subscriber_id": subscriberID})
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.SubscriberID == subscriberID && e.SubscriberURL != "" {
+			r.mu.Lock()
+			r.urls[subscriberID] = registryURL{url: e.SubscriberURL, fetched: time.Now()}
+			r.mu.Unlock()
+			return e.SubscriberURL, nil
+		}
+	}
+	return "", ErrUnknownSubscriber
+}
+
+// lookup POSTs query to the registry's /lookup and caches the subscriber URLs it answers with.
+func (r *RegistryKeys) lookup(query map[string]string) ([]registryRecord, error) {
+	body, _ := json.Marshal(query)
+	resp, err := r.client.Post(r.URL+"/lookup", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("registry lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("registry lookup returned %d: %s", resp.StatusCode, string(b))
+	}
+	var entries []registryRecord
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("registry lookup: %w", err)
+	}
+	r.mu.Lock()
+	for _, e := range entries {
+		if e.SubscriberID != "" && e.SubscriberURL != "" {
+			r.urls[e.SubscriberID] = registryURL{url: e.SubscriberURL, fetched: time.Now()}
+		}
+	}
+	r.mu.Unlock()
+	return entries, nil
+}
+
+// KeyChain tries each resolver in order.
+type KeyChain []KeyResolver
+
+func (c KeyChain) PublicKey(subscriberID, uniqueKeyID string) (ed25519.PublicKey, error) {
+	err := ErrUnknownKey
+	for _, r := range c {
+		pub, rerr := r.PublicKey(subscriberID, uniqueKeyID)
+		if rerr == nil {
+			return pub, nil
+		}
+		if !errors.Is(rerr, ErrUnknownKey) {
+			err = rerr
+		}
+	}
+	return nil, err
+}
+
+// SubscriberChain tries each resolver in order.
+type SubscriberChain []SubscriberResolver
+
+func (c SubscriberChain) SubscriberURL(subscriberID string) (string, error) {
+	err := ErrUnknownSubscriber
+	for _, r := range c {
+		url, rerr := r.SubscriberURL(subscriberID)
+		if rerr == nil {
+			return url, nil
+		}
+		if !errors.Is(rerr, ErrUnknownSubscriber) {
+			err = rerr
+		}
+	}
+	return "", err
+}
+
+// ParsePrivateKey accepts a base64 ed25519 seed (32 bytes) or full private key (64 bytes).
+func ParsePrivateKey(b64 string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, fmt.Errorf("private key is not base64: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("private key must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key.
+func ParsePublicKey(b64 string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not base64: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// EncodePublicKey is the base64 form used in the registry and ONDC_TRUSTED_KEYS.
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
@@ -0,0 +1,146 @@
+package ondc
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Beckn request signing: an ed25519 signature over
+//
+//	(created): <unix>
+//	(expires): <unix>
+//	digest: BLAKE-512=<base64 blake2b-512 of the body>
+//
+// carried in the Authorization (and, from gateways, X-Gateway-Authorization) header.
+
+var (
+	ErrMissingSignature = errors.New("missing authorization header")
+	ErrBadSignature     = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("signature expired")
+)
+
+// AuthHeader is a parsed Beckn Signature header.
+type AuthHeader struct {
+	SubscriberID string
+	UniqueKeyID  string
+	Algorithm    string
+	Created      int64
+	Expires      int64
+	Headers      string
+	Signature    string
+}
+
+// Digest returns the base64 BLAKE2b-512 digest of body.
+func Digest(body []byte) string {
+	sum := blake2b.Sum512(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signingString(created, expires int64, body []byte) string {
+	return fmt.Sprintf("(created): %d\n(expires): %d\ndigest: BLAKE-512=%s", created, expires, Digest(body))
+}
+
+// Sign builds the Authorization header value for body.
+func Sign(body []byte, subscriberID, uniqueKeyID string, key ed25519.PrivateKey, now time.Time, ttl time.Duration) string {
+	created := now.Unix()
+	expires := now.Add(ttl).Unix()
+	sig := ed25519.Sign(key, []byte(signingString(created, expires, body)))
+	return fmt.Sprintf(`Signature keyId="%s|%s|ed25519",algorithm="ed25519",created="%d",expires="%d",headers="(created) (expires) digest",signature="%s"`,
+		subscriberID, uniqueKeyID, created, expires, base64.StdEncoding.EncodeToString(sig))
+}
+
+// ParseAuthHeader parses `Signature keyId="sub|key|ed25519",created="..",...`.
+func ParseAuthHeader(header string) (AuthHeader, error) {
+	var h AuthHeader
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return h, ErrMissingSignature
+	}
+	header = strings.TrimSpace(strings.TrimPrefix(header, "Signature"))
+
+	params := map[string]string{}
+	for _, part := range splitParams(header) {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		params[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+
+	keyParts := strings.Split(params["keyId"], "|")
+	if len(keyParts) != 3 {
+		return h, fmt.Errorf("%w: malformed keyId", ErrBadSignature)
+	}
+	h.SubscriberID, h.UniqueKeyID, h.Algorithm = keyParts[0], keyParts[1], keyParts[2]
+	if alg := params["algorithm"]; alg != "" && alg != "ed25519" {
+		return h, fmt.Errorf("%w: unsupported algorithm %q", ErrBadSignature, alg)
+	}
+	var err error
+	if h.Created, err = strconv.ParseInt(params["created"], 10, 64); err != nil {
+		return h, fmt.Errorf("%w: malformed created", ErrBadSignature)
+	}
+	if h.Expires, err = strconv.ParseInt(params["expires"], 10, 64); err != nil {
+		return h, fmt.Errorf("%w: malformed expires", ErrBadSignature)
+	}
+	h.Headers = params["headers"]
+	h.Signature = params["signature"]
+	if h.Signature == "" {
+		return h, fmt.Errorf("%w: no signature", ErrBadSignature)
+	}
+	return h, nil
+}
+
+// splitParams splits on commas outside quotes (the base64 signature never contains one,
+// but headers="(created) (expires) digest" has spaces we must keep).
+func splitParams(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case r == ',' && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+	return parts
+}
+
+// Verify checks header against body. The signer's public key comes from keys.
+// It returns the parsed header so callers can tell who signed.
+func Verify(header string, body []byte, keys KeyResolver, now time.Time) (AuthHeader, error) {
+	h, err := ParseAuthHeader(header)
+	if err != nil {
+		return h, err
+	}
+	if now.Unix() > h.Expires || h.Created > now.Add(time.Minute).Unix() {
+		return h, ErrExpiredSignature
+	}
+	pub, err := keys.PublicKey(h.SubscriberID, h.UniqueKeyID)
+	if err != nil {
+		return h, fmt.Errorf("key lookup for %s|%s: %w", h.SubscriberID, h.UniqueKeyID, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(h.Signature)
+	if err != nil {
+		return h, fmt.Errorf("%w: signature is not base64", ErrBadSignature)
+	}
+	if !ed25519.Verify(pub, []byte(signingString(h.Created, h.Expires, body)), sig) {
+		return h, ErrBadSignature
+	}
+	return h, nil
+}
@@ -0,0 +1,430 @@
+package ondc
+
+import (
+	"time"
+)
+
+// Beckn actions the seller app serves, and their callbacks.
+const (
+	ActionSearch    = "search"
+	ActionOnSearch  = "on_search"
+	ActionSelect    = "select"
+	ActionOnSelect  = "on_select"
+	ActionInit      = "init"
+	ActionOnInit    = "on_init"
+	ActionConfirm   = "confirm"
+	ActionOnConfirm = "on_confirm"
+	ActionStatus    = "status"
+	ActionOnStatus  = "on_status"
+	ActionCancel    = "cancel"
+	ActionOnCancel  = "on_cancel"
+	ActionUpdate    = "update"
+	ActionOnUpdate  = "on_update"
+)
+
+const (
+	AckStatusACK  = "ACK"
+	AckStatusNACK = "NACK"
+)
+
+// Beckn error types.
+const (
+	ErrorTypeContext    = "CONTEXT-ERROR"
+	ErrorTypeDomain     = "DOMAIN-ERROR"
+	ErrorTypeJSONSchema = "JSON-SCHEMA-ERROR"
+	ErrorTypePolicy     = "POLICY-ERROR"
+)
+
+// Context is the Beckn message context shared by every request and callback.
+type Context struct {
+	Domain        string `json:"domain"`
+	Country       string `json:"country"`
+	City          string `json:"city"`
+	Action        string `json:"action"`
+	CoreVersion   string `json:"core_version"`
+	BapID         string `json:"bap_id"`
+	BapURI        string `json:"bap_uri"`
+	BppID         string `json:"bpp_id,omitempty"`
+	BppURI        string `json:"bpp_uri,omitempty"`
+	TransactionID string `json:"transaction_id"`
+	MessageID     string `json:"message_id"`
+	Timestamp     string `json:"timestamp"`
+	Key           string `json:"key,omitempty"`
+	TTL           string `json:"ttl,omitempty"`
+}
+
+// CallbackContext is ctx turned into the on_<action> reply from us.
+func (ctx Context) CallbackContext(action, bppID, bppURI string) Context {
+	out := ctx
+	out.Action = action
+	out.BppID = bppID
+	out.BppURI = bppURI
+	out.Timestamp = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	return out
+}
+
+// Retail error codes we send in callbacks.
+const (
+	ErrCodeInternal                = "20000"
+	ErrCodeInvalidOrder            = "30000"
+	ErrCodeProviderNotFound        = "30001"
+	ErrCodeItemNotFound            = "30004"
+	ErrCodeOrderNotFound           = "30008"
+	ErrCodeItemQuantityUnavailable = "40002"
+	ErrCodePriceChanged            = "40003"
+	ErrCodeCancellationNotAllowed  = "50001"
+	ErrCodeReturnNotAllowed        = "50002"
+)
+
+// Error is the Beckn error object. It is also a Go error so services can hand a
+// protocol-level failure straight back to the callback.
+type Error struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + " " + e.Message
+}
+
+// DomainError is a DOMAIN-ERROR with the given code.
+func DomainError(code, message string) *Error {
+	return &Error{Type: ErrorTypeDomain, Code: code, Message: message}
+}
+
+// Response is the synchronous ACK/NACK every Beckn endpoint returns.
+type Response struct {
+	Message struct {
+		Ack struct {
+			Status string `json:"status"`
+		} `json:"ack"`
+	} `json:"message"`
+	Error *Error `json:"error,omitempty"`
+}
+
+// Ack builds an ACK response.
+func Ack() Response {
+	var r Response
+	r.Message.Ack.Status = AckStatusACK
+	return r
+}
+
+// Nack builds a NACK response carrying err.
+func Nack(errType, code, message string) Response {
+	var r Response
+	r.Message.Ack.Status = AckStatusNACK
+	r.Error = &Error{Type: errType, Code: code, Message: message}
+	return r
+}
+
+/* ------------------------------
+   search
+   ------------------------------ */
+
+// SearchRequest is the body of /search.
+type SearchRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		Intent Intent `json:"intent"`
+	} `json:"message"`
+}
+
+// Intent is what the buyer is looking for. Every part is optional.
+type Intent struct {
+	Item *struct {
+		Descriptor *Descriptor `json:"descriptor,omitempty"`
+	} `json:"item,omitempty"`
+	Category *struct {
+		ID string `json:"id"`
+	} `json:"category,omitempty"`
+	Provider *struct {
+		ID         string      `json:"id"`
+		Descriptor *Descriptor `json:"descriptor,omitempty"`
+	} `json:"provider,omitempty"`
+	Fulfillment *struct {
+		Type string `json:"type"`
+	} `json:"fulfillment,omitempty"`
+}
+
+// OnSearchRequest is the body of the /on_search callback.
+type OnSearchRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		Catalog Catalog `json:"catalog"`
+	} `json:"message"`
+	Error *Error `json:"error,omitempty"`
+}
+
+/* ------------------------------
+   catalog
+   ------------------------------ */
+
+type Catalog struct {
+	Descriptor   Descriptor    `json:"bpp/descriptor"`
+	Fulfillments []Fulfillment `json:"bpp/fulfillments,omitempty"`
+	Providers    []Provider    `json:"bpp/providers"`
+}
+
+type Descriptor struct {
+	Name      string   `json:"name,omitempty"`
+	Code      string   `json:"code,omitempty"`
+	ShortDesc string   `json:"short_desc,omitempty"`
+	LongDesc  string   `json:"long_desc,omitempty"`
+	Symbol    string   `json:"symbol,omitempty"`
+	Images    []string `json:"images,omitempty"`
+}
+
+type Provider struct {
+	ID           string        `json:"id"`
+	Descriptor   Descriptor    `json:"descriptor"`
+	Locations    []Location    `json:"locations,omitempty"`
+	Fulfillments []Fulfillment `json:"fulfillments,omitempty"`
+	Categories   []Category    `json:"categories,omitempty"`
+	Items        []Item        `json:"items"`
+}
+
+// Category is a provider-level grouping; variant groups tie variant items to their parent.
+type Category struct {
+	ID         string     `json:"id"`
+	Descriptor Descriptor `json:"descriptor"`
+	Tags       []Tag      `json:"tags,omitempty"`
+}
+
+type Location struct {
+	ID      string   `json:"id"`
+	GPS     string   `json:"gps,omitempty"`
+	Address *Address `json:"address,omitempty"`
+	City    *City    `json:"city,omitempty"`
+}
+
+type City struct {
+	Code string `json:"code"`
+}
+
+type Address struct {
+	Name     string `json:"name,omitempty"`
+	Building string `json:"building,omitempty"`
+	Street   string `json:"street,omitempty"`
+	Locality string `json:"locality,omitempty"`
+	City     string `json:"city,omitempty"`
+	State    string `json:"state,omitempty"`
+	Country  string `json:"country,omitempty"`
+	AreaCode string `json:"area_code,omitempty"`
+}
+
+// Fulfillment types used in retail.
+const (
+	FulfillmentDelivery   = "Delivery"
+	FulfillmentSelfPickup = "Self-Pickup"
+)
+
+type Fulfillment struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type Price struct {
+	Currency     string `json:"currency"`
+	Value        string `json:"value"`
+	MaximumValue string `json:"maximum_value,omitempty"`
+}
+
+type Count struct {
+	Count string `json:"count"`
+}
+
+type ItemQuantity struct {
+	Available *Count `json:"available,omitempty"`
+	Maximum   *Count `json:"maximum,omitempty"`
+}
+
+type Item struct {
+	ID            string        `json:"id"`
+	ParentItemID  string        `json:"parent_item_id,omitempty"`
+	Descriptor    Descriptor    `json:"descriptor"`
+	Price         Price         `json:"price"`
+	Quantity      *ItemQuantity `json:"quantity,omitempty"`
+	CategoryID    string        `json:"category_id,omitempty"`
+	FulfillmentID string        `json:"fulfillment_id,omitempty"`
+	LocationID    string        `json:"location_id,omitempty"`
+
+	Returnable     bool   `json:"@ondc/org/returnable"`
+	Cancellable    bool   `json:"@ondc/org/cancellable"`
+	TimeToShip     string `json:"@ondc/org/time_to_ship,omitempty"`
+	AvailableOnCOD bool   `json:"@ondc/org/available_on_cod"`
+
+	Tags []Tag `json:"tags,omitempty"`
+}
+
+type Tag struct {
+	Code string    `json:"code"`
+	List []TagItem `json:"list"`
+}
+
+type TagItem struct {
+	Code  string `json:"code"`
+	Value string `json:"value"`
+}
+
+/* ------------------------------
+   orders
+   ------------------------------ */
+
+// Order states.
+const (
+	OrderStateCreated    = "Created"
+	OrderStateAccepted   = "Accepted"
+	OrderStateInProgress = "In-progress"
+	OrderStateCompleted  = "Completed"
+	OrderStateCancelled  = "Cancelled"
+)
+
+// OrderRequest is the body of /select, /init, /confirm and /update.
+type OrderRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		UpdateTarget string `json:"update_target,omitempty"`
+		Order        Order  `json:"order"`
+	} `json:"message"`
+}
+
+// StatusRequest is the body of /status.
+type StatusRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		OrderID string `json:"order_id"`
+	} `json:"message"`
+}
+
+// CancelRequest is the body of /cancel.
+type CancelRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		OrderID              string `json:"order_id"`
+		CancellationReasonID string `json:"cancellation_reason_id"`
+	} `json:"message"`
+}
+
+// OnOrderRequest is the body of every on_<order action> callback.
+type OnOrderRequest struct {
+	Context Context `json:"context"`
+	Message struct {
+		Order Order `json:"order"`
+	} `json:"message"`
+	Error *Error `json:"error,omitempty"`
+}
+
+type Order struct {
+	ID           string             `json:"id,omitempty"`
+	State        string             `json:"state,omitempty"`
+	Provider     OrderProvider      `json:"provider"`
+	Items        []OrderItem        `json:"items"`
+	Billing      *Billing           `json:"billing,omitempty"`
+	Fulfillments []OrderFulfillment `json:"fulfillments,omitempty"`
+	Quote        *Quote             `json:"quote,omitempty"`
+	Payment      *Payment           `json:"payment,omitempty"`
+	Cancellation *Cancellation      `json:"cancellation,omitempty"`
+	Tags         []Tag              `json:"tags,omitempty"`
+	CreatedAt    string             `json:"created_at,omitempty"`
+	UpdatedAt    string             `json:"updated_at,omitempty"`
+}
+
+type OrderProvider struct {
+	ID        string `json:"id"`
+	Locations []struct {
+		ID string `json:"id"`
+	} `json:"locations,omitempty"`
+}
+
+type OrderItem struct {
+	ID            string    `json:"id"`
+	ParentItemID  string    `json:"parent_item_id,omitempty"`
+	FulfillmentID string    `json:"fulfillment_id,omitempty"`
+	Quantity      ItemCount `json:"quantity"`
+	Tags          []Tag     `json:"tags,omitempty"`
+}
+
+// ItemCount is a quantity in an order; unlike catalog counts it is a number.
+type ItemCount struct {
+	Count int `json:"count"`
+}
+
+type Billing struct {
+	Name    string   `json:"name,omitempty"`
+	Phone   string   `json:"phone,omitempty"`
+	Email   string   `json:"email,omitempty"`
+	Address *Address `json:"address,omitempty"`
+}
+
+type OrderFulfillment struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type,omitempty"`
+	Tracking bool              `json:"tracking"`
+	State    *FulfillmentState `json:"state,omitempty"`
+	End      *FulfillmentEnd   `json:"end,omitempty"`
+}
+
+type FulfillmentState struct {
+	Descriptor Descriptor `json:"descriptor"`
+}
+
+type FulfillmentEnd struct {
+	Location *Location `json:"location,omitempty"`
+	Contact  *Contact  `json:"contact,omitempty"`
+	Person   *Person   `json:"person,omitempty"`
+}
+
+type Contact struct {
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type Person struct {
+	Name string `json:"name"`
+}
+
+type Quote struct {
+	Price   Price          `json:"price"`
+	Breakup []QuoteBreakup `json:"breakup"`
+	TTL     string         `json:"ttl,omitempty"`
+}
+
+// Breakup title types.
+const (
+	TitleTypeItem     = "item"
+	TitleTypeDelivery = "delivery"
+)
+
+type QuoteBreakup struct {
+	ItemID       string     `json:"@ondc/org/item_id"`
+	ItemQuantity *ItemCount `json:"@ondc/org/item_quantity,omitempty"`
+	TitleType    string     `json:"@ondc/org/title_type"`
+	Title        string     `json:"title"`
+	Price        Price      `json:"price"`
+	Item         *struct {
+		Price    Price         `json:"price"`
+		Quantity *ItemQuantity `json:"quantity,omitempty"`
+	} `json:"item,omitempty"`
+}
+
+type Payment struct {
+	Type        string         `json:"type,omitempty"`
+	Status      string         `json:"status,omitempty"`
+	CollectedBy string         `json:"collected_by,omitempty"`
+	Params      *PaymentParams `json:"params,omitempty"`
+}
+
+type PaymentParams struct {
+	Amount        string `json:"amount,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+type Cancellation struct {
+	CancelledBy string `json:"cancelled_by"`
+	Reason      struct {
+		ID string `json:"id"`
+	} `json:"reason"`
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Layers a ChannelView field can come from, lowest precedence first.
+const (
+	ViewSourceBase     = "base"
+	ViewSourceSettings = "settings"
+	ViewSourceOverride = "override"
+)
+
+// ChannelView is a product as a channel should publish it. It merges, in order of precedence:
+//  1. the base product,
+//  2. the typed channel settings (ProductWoo / ProductONDC, e.g. custom price),
+//  3. the free-form ProductChannelOverride.Data JSON.
+type ChannelView struct {
+	Channel          string         `json:"channel"`
+	Name             string         `json:"name"`
+	ShortDescription string         `json:"short_description"`
+	Description      string         `json:"description"`
+	RegularPrice     float64        `json:"regular_price"`
+	SalePrice        *float64       `json:"sale_price"`
+	Images           []ChannelImage `json:"images"`
+	Visibility       string         `json:"visibility"`
+	Status           string         `json:"status"`
+
+	// Variants carries each variant's prices with the same custom price and override applied.
+	Variants []ChannelVariant `json:"variants,omitempty"`
+
+	// Sources maps each field to the layer it came from (base, settings, override).
+	Sources map[string]string `json:"sources"`
+}
+
+// ChannelImage is an image with its public URL.
+type ChannelImage struct {
+	ID        uint   `json:"id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Src       string `json:"src"`
+	Alt       string `json:"alt"`
+	Position  int    `json:"position"`
+}
+
+// ChannelVariant is a variant's prices on a channel.
+type ChannelVariant struct {
+	ID           uint     `json:"id"`
+	RegularPrice float64  `json:"regular_price"`
+	SalePrice    *float64 `json:"sale_price"`
+}
+
+// Variant returns the channel prices of variant v, or its base prices when the view
+// doesn't carry it (variants weren't loaded).
+func (view ChannelView) Variant(v *models.ProductVariant) ChannelVariant {
+	for _, cv := range view.Variants {
+		if cv.ID == v.ID {
+			return cv
+		}
+	}
+	return ChannelVariant{ID: v.ID, RegularPrice: v.RegularPrice, SalePrice: v.SalePrice}
+}
+
+// ChannelOverride is the schema of ProductChannelOverride.Data. Omitted fields fall through
+// to the layers below; "sale_price": null removes the sale price on that channel.
+type ChannelOverride struct {
+	Name             *string  `json:"name,omitempty"`
+	ShortDescription *string  `json:"short_description,omitempty"`
+	Description      *string  `json:"description,omitempty"`
+	RegularPrice     *float64 `json:"regular_price,omitempty"`
+	SalePrice        *float64 `json:"sale_price,omitempty"`
+	ImageIDs         []uint   `json:"image_ids,omitempty"` // subset & order of the product's images
+	Visibility       *string  `json:"visibility,omitempty"`
+	Status           *string  `json:"status,omitempty"`
+
+	SalePriceSet bool `json:"-"`
+}
+
+// KnownChannels are the channel names products can be resolved for.
+var KnownChannels = map[string]bool{"woocommerce": true, "ondc": true}
+
+var validVisibilities = map[string]bool{"visible": true, "catalog": true, "search": true, "hidden": true}
+var validStatuses = map[string]bool{"publish": true, "draft": true, "pending": true, "private": true}
+
+// ParseChannelOverride decodes and validates override JSON. Unknown keys are rejected
+// so typos don't silently do nothing.
+func ParseChannelOverride(data []byte) (ChannelOverride, error) {
+	var o ChannelOverride
+	if len(bytes.TrimSpace(data)) == 0 {
+		return o, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return o, fmt.Errorf("invalid override: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	_ = json.Unmarshal(data, &raw)
+	_, o.SalePriceSet = raw["sale_price"]
+
+	if o.RegularPrice != nil && *o.RegularPrice < 0 {
+		return o, errors.New("override regular_price must be non-negative")
+	}
+	if o.SalePrice != nil && *o.SalePrice < 0 {
+		return o, errors.New("override sale_price must be non-negative")
+	}
+	if o.Visibility != nil && !validVisibilities[*o.Visibility] {
+		return o, fmt.Errorf("override visibility must be one of visible, catalog, search, hidden")
+	}
+	if o.Status != nil && !validStatuses[*o.Status] {
+		return o, fmt.Errorf("override status must be one of publish, draft, pending, private")
+	}
+	return o, nil
+}
+
+// ResolveChannelView computes the effective view of product on channel.
+// product must have Images, ProductWoo and ProductONDC preloaded (and Variants for variant
+// prices); the override row is loaded here.
+func ResolveChannelView(db *gorm.DB, product *models.Product, channel string) (ChannelView, error) {
+	var override ChannelOverride
+	var row models.ProductChannelOverride
+	err := db.Where("product_id = ? AND channel = ?", product.ID, channel).First(&row).Error
+	switch {
+	case err == nil:
+		if override, err = ParseChannelOverride(row.Data); err != nil {
+			return ChannelView{}, fmt.Errorf("product %d %s override: %w", product.ID, channel, err)
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return ChannelView{}, err
+	}
+	return BuildChannelView(product, channel, override), nil
+}
+
+// BuildChannelView merges the three layers without touching the database.
+func BuildChannelView(product *models.Product, channel string, override ChannelOverride) ChannelView {
+	v := ChannelView{
+		Channel:          channel,
+		Name:             product.Name,
+		ShortDescription: product.ShortDescription,
+		Description:      product.Description,
+		RegularPrice:     product.RegularPrice,
+		SalePrice:        product.SalePrice,
+		Images:           make([]ChannelImage, 0, len(product.Images)),
+		Visibility:       "visible",
+		Status:           "publish",
+		Sources: map[string]string{
+			"name":              ViewSourceBase,
+			"short_description": ViewSourceBase,
+			"description":       ViewSourceBase,
+			"regular_price":     ViewSourceBase,
+			"sale_price":        ViewSourceBase,
+			"images":            ViewSourceBase,
+			"visibility":        ViewSourceBase,
+			"status":            ViewSourceBase,
+		},
+	}
+	for _, img := range product.Images {
+		v.Images = append(v.Images, ChannelImage{
+			ID:        img.ID,
+			VariantID: img.VariantID,
+			Src:       PublicImageURL(img.Src),
+			Alt:       img.Alt,
+			Position:  img.Position,
+		})
+	}
+
+	// Typed channel settings
+	var customPrice *float64
+	switch channel {
+	case "woocommerce":
+		if w := product.ProductWoo; w.ID != 0 {
+			if w.CustomPriceEnabled {
+				customPrice = w.CustomPriceValue
+			}
+			if w.CatalogVisibility != "" {
+				v.Visibility = w.CatalogVisibility
+				v.Sources["visibility"] = ViewSourceSettings
+			}
+			if w.Status != "" {
+				v.Status = w.Status
+				v.Sources["status"] = ViewSourceSettings
+			}
+		}
+	case "ondc":
+		if o := product.ProductONDC; o.ID != 0 && o.CustomPriceEnabled {
+			customPrice = o.CustomPriceValue
+		}
+	}
+	var regularSource, saleSource string
+	v.RegularPrice, v.SalePrice, regularSource, saleSource = channelPrice(product.RegularPrice, product.SalePrice, customPrice, override)
+	v.Sources["regular_price"], v.Sources["sale_price"] = regularSource, saleSource
+	for i := range product.Variants {
+		pv := &product.Variants[i]
+		cv := ChannelVariant{ID: pv.ID}
+		cv.RegularPrice, cv.SalePrice, _, _ = channelPrice(pv.RegularPrice, pv.SalePrice, customPrice, override)
+		v.Variants = append(v.Variants, cv)
+	}
+
+	// JSON override
+	if override.Name != nil {
+		v.Name = *override.Name
+		v.Sources["name"] = ViewSourceOverride
+	}
+	if override.ShortDescription != nil {
+		v.ShortDescription = *override.ShortDescription
+		v.Sources["short_description"] = ViewSourceOverride
+	}
+	if override.Description != nil {
+		v.Description = *override.Description
+		v.Sources["description"] = ViewSourceOverride
+	}
+	if len(override.ImageIDs) > 0 {
+		byID := make(map[uint]ChannelImage, len(v.Images))
+		for _, img := range v.Images {
+			byID[img.ID] = img
+		}
+		picked := make([]ChannelImage, 0, len(override.ImageIDs))
+		for i, id := range override.ImageIDs {
+			if img, ok := byID[id]; ok {
+				img.Position = i
+				picked = append(picked, img)
+			}
+		}
+		v.Images = picked
+		v.Sources["images"] = ViewSourceOverride
+	}
+	if override.Visibility != nil {
+		v.Visibility = *override.Visibility
+		v.Sources["visibility"] = ViewSourceOverride
+	}
+	if override.Status != nil {
+		v.Status = *override.Status
+		v.Sources["status"] = ViewSourceOverride
+	}
+	return v
+}
+
+// channelPrice layers a channel's custom price and the override's prices onto a base
+// regular and sale price, returning the layer each came from. Variants go through it with
+// the product's custom price and override, so a channel price applies to all of them.
+func channelPrice(regular float64, sale *float64, customPrice *float64, override ChannelOverride) (float64, *float64, string, string) {
+	regularSource, saleSource := ViewSourceBase, ViewSourceBase
+	if customPrice != nil {
+		regular, regularSource = *customPrice, ViewSourceSettings
+		// a base sale price only survives if it still undercuts the channel price
+		if sale != nil && *sale >= *customPrice {
+			sale, saleSource = nil, ViewSourceSettings
+		}
+	}
+	if override.RegularPrice != nil {
+		regular, regularSource = *override.RegularPrice, ViewSourceOverride
+	}
+	if override.SalePriceSet {
+		sale, saleSource = override.SalePrice, ViewSourceOverride
+	}
+	return regular, sale, regularSource, saleSource
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Location rules: which locations an incoming order's stock is taken from, for lines that
+// are stocked per location. Set per channel as config.location_rule.
+const (
+	LocationRuleDefault = "default" // the default location first, then the ones holding the most
+	LocationRuleNearest = "nearest" // the nearest location holding the whole line, else nearest first
+	LocationRuleSplit   = "split"   // every location in proportion to what it holds
+	DefaultLocationRule = LocationRuleDefault
+)
+
+// IsValidLocationRule reports whether r is a known location rule.
+func IsValidLocationRule(r string) bool {
+	switch r {
+	case LocationRuleDefault, LocationRuleNearest, LocationRuleSplit:
+		return true
+	}
+	return false
+}
+
+// StockAllocation picks the locations a deduction comes from: the rule, and for "nearest"
+// where the order ships to.
+type StockAllocation struct {
+	Rule     string
+	AreaCode string // destination postcode / pincode
+	GPS      string // destination "lat,lng"
+}
+
+// locationLine is one location's row of a stock line, with what the rules rank it by.
+type locationLine struct {
+	ID         uint
+	LocationID uint
+	StockQty   int
+	IsDefault  bool
+	IsActive   bool
+	AreaCode   string
+	GPS        string
+}
+
+// locationLines returns the stock line's per-location rows, none when the line isn't
+// stocked per location. Callers hold the product row lock (lockStockLine).
+func locationLines(tx *gorm.DB, k stockKey) ([]locationLine, error) {
+	var rows []locationLine
+	err := tx.Table("product_location_stocks AS pls").
+		Select("pls.id, pls.location_id, pls.stock_qty, sl.is_default, sl.is_active, sl.area_code, sl.gps").
+		Joins("JOIN seller_locations sl ON sl.id = pls.location_id AND sl.deleted_at IS NULL").
+		Where("pls.product_id = ? AND COALESCE(pls.variant_id, 0) = ? AND pls.deleted_at IS NULL", k.ProductID, k.VariantID).
+		Order("pls.location_id ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// syncLineTotal sets the line's on-hand to the sum of its location rows.
+func syncLineTotal(tx *gorm.DB, k stockKey) error {
+	return stockLineQuery(tx, k).UpdateColumn("stock_quantity", gorm.Expr(
+		"(SELECT COALESCE(SUM(stock_qty), 0) FROM product_location_stocks WHERE product_id = ? AND COALESCE(variant_id, 0) = ? AND deleted_at IS NULL)",
+		k.ProductID, k.VariantID)).Error
+}
+
+// takeLineStock takes qty of a locked stock line out of stock, recording a movement
+// (reason, ref) per location it came from; alloc picks the locations. It returns what was
+// taken, which is less than qty only if the location rows hold less than on-hand says.
+func takeLineStock(tx *gorm.DB, k stockKey, qty int, alloc StockAllocation, reason, ref string) (int, error) {
+	rows, err := locationLines(tx, k)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		if err := stockLineQuery(tx, k).
+			UpdateColumn("stock_quantity", gorm.Expr("stock_quantity - ?", qty)).Error; err != nil {
+			return 0, err
+		}
+		return qty, recordMovement(tx, k, nil, -qty, reason, ref)
+	}
+
+	taken := 0
+	for i, n := range planLocationTake(rows, qty, alloc) {
+		if n == 0 {
+			continue
+		}
+		if err := shiftLocationStock(tx, k, rows[i], -n, reason, ref); err != nil {
+			return 0, err
+		}
+		taken += n
+	}
+	return taken, syncLineTotal(tx, k)
+}
+
+// returnLineStock puts qty of a locked stock line back, first to the locations in from
+// (location -> qty that came from there), the rest to the default location.
+func returnLineStock(tx *gorm.DB, k stockKey, qty int, from map[uint]int, reason, ref string) error {
+	rows, err := locationLines(tx, k)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		if err := stockLineQuery(tx, k).
+			UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", qty)).Error; err != nil {
+			return err
+		}
+		return recordMovement(tx, k, nil, qty, reason, ref)
+	}
+
+	give := make([]int, len(rows))
+	remaining := qty
+	for i, r := range rows {
+		n := min(remaining, from[r.LocationID])
+		if n > 0 {
+			give[i] += n
+			remaining -= n
+		}
+	}
+	if remaining > 0 {
+		give[rankLocations(rows, 0, StockAllocation{Rule: LocationRuleDefault})[0]] += remaining
+	}
+	for i, n := range give {
+		if n == 0 {
+			continue
+		}
+		if err := shiftLocationStock(tx, k, rows[i], n, reason, ref); err != nil {
+			return err
+		}
+	}
+	return syncLineTotal(tx, k)
+}
+
+// SetStockTotal sets a product's (or variant's) on-hand to qty inside tx and returns the
+// change. For a line stocked per location, a drop is taken from the default location
+// first and a rise lands there.
+func SetStockTotal(tx *gorm.DB, productID uint, variantID *uint, qty int, reason, ref string) (int, error) {
+	k := keyOf(productID, variantID)
+	onHand, _, err := lockStockLine(tx, k)
+	if err != nil {
+		return 0, err
+	}
+	switch delta := qty - onHand; {
+	case delta < 0:
+		taken, err := takeLineStock(tx, k, -delta, StockAllocation{Rule: LocationRuleDefault}, reason, ref)
+		return -taken, err
+	case delta > 0:
+		return delta, returnLineStock(tx, k, delta, nil, reason, ref)
+	}
+	return 0, nil
+}
+
+// SetLocationStock sets what a location holds of a product (or variant) inside tx, records
+// the difference as a movement and returns it. The line's on-hand becomes the sum of its
+// locations.
+func SetLocationStock(tx *gorm.DB, orgID, productID uint, variantID *uint, locationID uint, qty int, reason, ref string) (int, error) {
+	k := keyOf(productID, variantID)
+	onHand, _, err := lockStockLine(tx, k)
+	if err != nil {
+		return 0, err
+	}
+	row, err := locationRow(tx, orgID, k, onHand, locationID)
+	if err != nil {
+		return 0, err
+	}
+	delta := qty - row.StockQty
+	if delta != 0 {
+		if err := shiftLocationStock(tx, k, row, delta, reason, ref); err != nil {
+			return 0, err
+		}
+	}
+	return delta, syncLineTotal(tx, k)
+}
+
+// locationRow returns a locked line's row at locationID, creating it empty if needed. When
+// the line has no rows yet, its on-hand is first placed at the org's default location (or
+// at locationID if there's no default), so no stock goes missing.
+func locationRow(tx *gorm.DB, orgID uint, k stockKey, onHand int, locationID uint) (locationLine, error) {
+	rows, err := locationLines(tx, k)
+	if err != nil {
+		return locationLine{}, err
+	}
+
+	if len(rows) == 0 && onHand > 0 {
+		seedAt := locationID
+		var def models.SellerLocation
+		if err := tx.Select("id").Where("organization_id = ? AND is_default", orgID).First(&def).Error; err == nil {
+			seedAt = def.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return locationLine{}, err
+		}
+		seed := models.ProductLocationStock{ProductID: k.ProductID, VariantID: k.variantPtr(), LocationID: seedAt, StockQty: onHand}
+		if err := tx.Create(&seed).Error; err != nil {
+			return locationLine{}, err
+		}
+		ref := fmt.Sprintf("product:%d", k.ProductID)
+		if err := recordMovement(tx, k, nil, -onHand, MovementLocationSeed, ref); err != nil {
+			return locationLine{}, err
+		}
+		if err := recordMovement(tx, k, &seedAt, onHand, MovementLocationSeed, ref); err != nil {
+			return locationLine{}, err
+		}
+		rows = append(rows, locationLine{ID: seed.ID, LocationID: seedAt, StockQty: onHand})
+	}
+
+	for _, r := range rows {
+		if r.LocationID == locationID {
+			return r, nil
+		}
+	}
+	created := models.ProductLocationStock{ProductID: k.ProductID, VariantID: k.variantPtr(), LocationID: locationID}
+	if err := tx.Create(&created).Error; err != nil {
+		return locationLine{}, err
+	}
+	return locationLine{ID: created.ID, LocationID: locationID}, nil
+}
+
+// shiftLocationStock adds delta to one location row and records the movement. The caller
+// syncs the line total.
+func shiftLocationStock(tx *gorm.DB, k stockKey, row locationLine, delta int, reason, ref string) error {
+	if err := tx.Model(&models.ProductLocationStock{}).Where("id = ?", row.ID).
+		UpdateColumn("stock_qty", gorm.Expr("stock_qty + ?", delta)).Error; err != nil {
+		return err
+	}
+	return recordMovement(tx, k, &row.LocationID, delta, reason, ref)
+}
+
+type actorKey struct{}
+
+// WithActor returns db with userID as the acting user of every InventoryMovement written
+// through it (or through a transaction it starts).
+func WithActor(db *gorm.DB, userID uint) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, actorKey{}, userID))
+}
+
+// movementActor is the acting user WithActor put on tx, nil if none.
+func movementActor(tx *gorm.DB) *uint {
+	if tx.Statement.Context == nil {
+		return nil
+	}
+	if id, ok := tx.Statement.Context.Value(actorKey{}).(uint); ok && id != 0 {
+		return &id
+	}
+	return nil
+}
+
+func recordMovement(tx *gorm.DB, k stockKey, locationID *uint, qty int, reason, ref string) error {
+	if err := tx.Create(&models.InventoryMovement{
+		ProductID:   k.ProductID,
+		VariantID:   k.variantPtr(),
+		LocationID:  locationID,
+		ChangeQty:   qty,
+		Reason:      reason,
+		Ref:         ref,
+		ActorUserID: movementActor(tx),
+	}).Error; err != nil {
+		return fmt.Errorf("record movement for product %d: %w", k.ProductID, err)
+	}
+	return nil
+}
+
+// planLocationTake splits qty across the rows under alloc's rule, returning what to take
+// from each row (by index). It never takes more than a row holds.
+func planLocationTake(rows []locationLine, qty int, alloc StockAllocation) []int {
+	take := make([]int, len(rows))
+	remaining := qty
+	if alloc.Rule == LocationRuleSplit {
+		active := 0
+		for _, r := range rows {
+			if r.IsActive && r.StockQty > 0 {
+				active += r.StockQty
+			}
+		}
+		if active >= qty {
+			for i, r := range rows {
+				if r.IsActive && r.StockQty > 0 {
+					take[i] = qty * r.StockQty / active
+					remaining -= take[i]
+				}
+			}
+		}
+	}
+	for _, i := range rankLocations(rows, qty, alloc) {
+		if remaining == 0 {
+			break
+		}
+		n := min(remaining, rows[i].StockQty-take[i])
+		if n > 0 {
+			take[i] += n
+			remaining -= n
+		}
+	}
+	return take
+}
+
+// rankLocations orders the rows (by index) in the order alloc's rule takes from them.
+// Inactive locations always come last; ties go to the location holding the most.
+func rankLocations(rows []locationLine, qty int, alloc StockAllocation) []int {
+	order := make([]int, len(rows))
+	dist := make([]float64, len(rows))
+	for i, r := range rows {
+		order[i] = i
+		if alloc.Rule == LocationRuleNearest {
+			dist[i] = locationDistance(r, alloc)
+		}
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		a, b := rows[order[x]], rows[order[y]]
+		if a.IsActive != b.IsActive {
+			return a.IsActive
+		}
+		switch alloc.Rule {
+		case LocationRuleNearest:
+			if da, db := dist[order[x]], dist[order[y]]; da != db {
+				return da < db
+			}
+		case LocationRuleDefault:
+			if a.IsDefault != b.IsDefault {
+				return a.IsDefault
+			}
+		}
+		return a.StockQty > b.StockQty
+	})
+	if alloc.Rule == LocationRuleNearest && qty > 0 {
+		// ship the line whole from one place when one nearby can
+		for pos, i := range order {
+			if rows[i].IsActive && rows[i].StockQty >= qty {
+				copy(order[1:pos+1], order[:pos])
+				order[0] = i
+				break
+			}
+		}
+	}
+	return order
+}
+
+// locationDistance is how far a location is from an order's destination: 0 on a matching
+// area code, else the great-circle distance in km when both have GPS, else +Inf.
+func locationDistance(l locationLine, alloc StockAllocation) float64 {
+	if alloc.AreaCode != "" && strings.EqualFold(strings.TrimSpace(l.AreaCode), strings.TrimSpace(alloc.AreaCode)) {
+		return 0
+	}
+	lat1, lng1, ok1 := ParseGPS(l.GPS)
+	lat2, lng2, ok2 := ParseGPS(alloc.GPS)
+	if !ok1 || !ok2 {
+		return math.Inf(1)
+	}
+	const earthRadiusKm = 6371.0
+	rad := math.Pi / 180
+	dLat, dLng := (lat2-lat1)*rad, (lng2-lng1)*rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// ParseGPS reads ONDC's "lat,lng", rejecting out-of-range coordinates.
+func ParseGPS(s string) (float64, float64, bool) {
+	latStr, lngStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// orderStockAllocation is how an order's stock is picked: its channel's location rule and
+// its shipping destination.
+func orderStockAllocation(tx *gorm.DB, order *models.Order) StockAllocation {
+	alloc := StockAllocation{Rule: channelLocationRule(tx, order.OrganizationID, order.Source)}
+	var shipping models.OrderAddress
+	if len(order.ShippingAddress) > 0 {
+		_ = json.Unmarshal(order.ShippingAddress, &shipping)
+	}
+	alloc.AreaCode, alloc.GPS = shipping.Postcode, shipping.GPS
+	return alloc
+}
+
+// channelLocationRule reads location_rule from the org's channel config.
+func channelLocationRule(tx *gorm.DB, orgID uint, channelName string) string {
+	var channel models.Channel
+	if err := tx.Where("organization_id = ? AND name = ?", orgID, channelName).First(&channel).Error; err != nil {
+		return DefaultLocationRule
+	}
+	var cfg struct {
+		LocationRule string `json:"location_rule"`
+	}
+	if len(channel.Config) > 0 {
+		_ = json.Unmarshal(channel.Config, &cfg)
+	}
+	if !IsValidLocationRule(cfg.LocationRule) {
+		return DefaultLocationRule
+	}
+	return cfg.LocationRule
+}
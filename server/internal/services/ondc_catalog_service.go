@@ -0,0 +1,391 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/ondc"
+	"gorm.io/gorm"
+)
+
+// ONDCCurrency is the only currency on the Indian network.
+const ONDCCurrency = "INR"
+
+// ONDC fulfillment ids used in our catalogs.
+const (
+	ondcFulfillmentDeliveryID = "1"
+	ondcFulfillmentPickupID   = "2"
+)
+
+type ONDCService struct {
+	db *gorm.DB
+}
+
+func NewONDCService(db *gorm.DB) *ONDCService {
+	return &ONDCService{db: db}
+}
+
+// BuildCatalog answers a /search: every organization with an active "ondc" channel is a
+// provider, and its products enabled on that channel (with ProductONDC settings) are items.
+// Prices come from the ondc ChannelView (custom price and overrides applied); stock from
+// ProductLocationStock at active locations, falling back to the product's stock.
+func (s *ONDCService) BuildCatalog(req ondc.SearchRequest, bppName string) (ondc.Catalog, error) {
+	catalog := ondc.Catalog{
+		Descriptor: ondc.Descriptor{Name: bppName},
+		Providers:  []ondc.Provider{},
+	}
+	intent := req.Message.Intent
+
+	orgQuery := s.db.Model(&models.Channel{}).Where("name = ? AND is_active = ?", "ondc", true)
+	if intent.Provider != nil && intent.Provider.ID != "" {
+		// provider ids are our organization ids; any other id is no provider of ours
+		providerID, err := strconv.ParseUint(intent.Provider.ID, 10, 32)
+		if err != nil {
+			return catalog, nil
+		}
+		orgQuery = orgQuery.Where("organization_id = ?", providerID)
+	}
+	var orgIDs []uint
+	if err := orgQuery.Distinct().Pluck("organization_id", &orgIDs).Error; err != nil {
+		return catalog, err
+	}
+	sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i] < orgIDs[j] })
+
+	for _, orgID := range orgIDs {
+		provider, err := s.buildProvider(orgID, req.Context.City, intent)
+		if err != nil {
+			return catalog, fmt.Errorf("provider %d: %w", orgID, err)
+		}
+		if provider != nil {
+			catalog.Providers = append(catalog.Providers, *provider)
+		}
+	}
+	return catalog, nil
+}
+
+// buildProvider returns nil when the org has nothing matching the intent.
+func (s *ONDCService) buildProvider(orgID uint, city string, intent ondc.Intent) (*ondc.Provider, error) {
+	var org models.Organization
+	if err := s.db.First(&org, orgID).Error; err != nil {
+		return nil, err
+	}
+
+	locQuery := s.db.Where("organization_id = ? AND is_active = ?", orgID, true)
+	if city != "" && city != "*" {
+		locQuery = locQuery.Where("city_code IN ?", []string{city, ""})
+	}
+	var locations []models.SellerLocation
+	if err := locQuery.Order("id ASC").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+
+	products, err := s.catalogProducts(orgID, city, intent)
+	if err != nil || len(products) == 0 {
+		return nil, err
+	}
+
+	provider := &ondc.Provider{
+		ID:         strconv.FormatUint(uint64(orgID), 10),
+		Descriptor: ondc.Descriptor{Name: org.Name},
+		Fulfillments: []ondc.Fulfillment{
+			{ID: ondcFulfillmentDeliveryID, Type: ondc.FulfillmentDelivery},
+			{ID: ondcFulfillmentPickupID, Type: ondc.FulfillmentSelfPickup},
+		},
+		Items: []ondc.Item{},
+	}
+	activeLocations := make(map[uint]bool, len(locations))
+	for _, l := range locations {
+		activeLocations[l.ID] = true
+		provider.Locations = append(provider.Locations, toONDCLocation(l))
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+	}
+	reserved, err := reservedQuantities(s.db, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range products {
+		p := &products[i]
+		items, group := ondcItems(p, activeLocations, reserved)
+		provider.Items = append(provider.Items, items...)
+		if group != nil {
+			provider.Categories = append(provider.Categories, *group)
+		}
+	}
+	if len(provider.Items) == 0 {
+		return nil, nil
+	}
+	return provider, nil
+}
+
+// catalogProducts loads the org's ONDC-enabled products matching the intent.
+func (s *ONDCService) catalogProducts(orgID uint, city string, intent ondc.Intent) ([]models.Product, error) {
+	query := ondcProducts(s.db, orgID)
+
+	if intent.Item != nil && intent.Item.Descriptor != nil && strings.TrimSpace(intent.Item.Descriptor.Name) != "" {
+		query = query.Where("products.name ILIKE ?", "%"+strings.TrimSpace(intent.Item.Descriptor.Name)+"%")
+	}
+	if intent.Category != nil && intent.Category.ID != "" {
+		query = query.Where("product_ondcs.ondc_category_id = ?", intent.Category.ID)
+	}
+	if city != "" && city != "*" {
+		query = query.Where("product_ondcs.city_code IN ?", []string{city, ""})
+	}
+	if intent.Fulfillment != nil {
+		switch intent.Fulfillment.Type {
+		case ondc.FulfillmentDelivery:
+			query = query.Where("product_ondcs.fulfillment_type IN ?", []string{"delivery", "both"})
+		case ondc.FulfillmentSelfPickup:
+			query = query.Where("product_ondcs.fulfillment_type IN ?", []string{"pickup", "both"})
+		}
+	}
+
+	var products []models.Product
+	err := query.Order("products.id ASC").Find(&products).Error
+	return products, err
+}
+
+// ondcProducts scopes to the org's products that have ONDC settings and are enabled on its
+// "ondc" channel, preloading what items are built from.
+func ondcProducts(db *gorm.DB, orgID uint) *gorm.DB {
+	return db.Model(&models.Product{}).
+		Joins("JOIN product_ondcs ON product_ondcs.product_id = products.id AND product_ondcs.deleted_at IS NULL").
+		Joins("JOIN product_channels ON product_channels.product_id = products.id AND product_channels.is_enabled = ? AND product_channels.deleted_at IS NULL", true).
+		Joins("JOIN channels ON channels.id = product_channels.channel_id AND channels.name = ? AND channels.deleted_at IS NULL", "ondc").
+		Where("products.organization_id = ?", orgID).
+		Preload("Images", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("ProductONDC").
+		Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Preload("LocationStock").
+		Preload("ChannelOverrides", "channel = ?", "ondc")
+}
+
+// ONDCItemID is the catalog id of a product, or of one of its variants when v is set.
+// An explicit ONDCItemID wins; otherwise "<product id>" / "<product id>-<variant id>".
+func ONDCItemID(p *models.Product, v *models.ProductVariant) string {
+	if v != nil {
+		if v.ONDCItemID != "" {
+			return v.ONDCItemID
+		}
+		return fmt.Sprintf("%d-%d", p.ID, v.ID)
+	}
+	if p.ProductONDC.ONDCItemID != "" {
+		return p.ProductONDC.ONDCItemID
+	}
+	return strconv.FormatUint(uint64(p.ID), 10)
+}
+
+// ondcItems maps a product to catalog items: one item for a simple product, one per variant
+// (plus the variant group category) for a variable product. Quantities are available-to-sell:
+// stock less what reserved holds for in-flight orders.
+func ondcItems(p *models.Product, activeLocations map[uint]bool, reserved map[stockKey]int) ([]ondc.Item, *ondc.Category) {
+	view := ondcView(p)
+	settings := p.ProductONDC
+	locationID, stock := ondcStock(p, activeLocations)
+
+	images := make([]string, 0, len(view.Images))
+	for _, img := range view.Images {
+		if img.VariantID == nil {
+			images = append(images, img.Src)
+		}
+	}
+
+	base := ondc.Item{
+		Descriptor: ondc.Descriptor{
+			Name:      view.Name,
+			ShortDesc: view.ShortDescription,
+			LongDesc:  view.Description,
+			Images:    images,
+		},
+		CategoryID:    settings.ONDCCategoryID,
+		FulfillmentID: ondcFulfillmentID(settings.FulfillmentType),
+		LocationID:    locationID,
+		Returnable:    settings.Returnable,
+		Cancellable:   settings.Cancellable,
+		TimeToShip:    settings.TimeToShip,
+	}
+
+	if len(p.Variants) == 0 {
+		item := base
+		item.ID = ONDCItemID(p, nil)
+		item.Price = ondcPrice(view.RegularPrice, view.SalePrice)
+		item.Quantity = ondcQuantity(p.ManageStock, availableQty(stock, reserved[keyOf(p.ID, nil)]))
+		return []ondc.Item{item}, nil
+	}
+
+	groupID := ONDCItemID(p, nil)
+	group := &ondc.Category{
+		ID:         groupID,
+		Descriptor: ondc.Descriptor{Name: view.Name},
+		Tags: []ondc.Tag{
+			{Code: "type", List: []ondc.TagItem{{Code: "type", Value: "variant_group"}}},
+		},
+	}
+	var attrNames []string
+	items := make([]ondc.Item, 0, len(p.Variants))
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		var attrs map[string]string
+		_ = json.Unmarshal(v.Attributes, &attrs)
+		names := make([]string, 0, len(attrs))
+		for name := range attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if attrNames == nil {
+			attrNames = names
+		}
+
+		item := base
+		item.ID = ONDCItemID(p, v)
+		item.ParentItemID = groupID
+		price := view.Variant(v)
+		item.Price = ondcPrice(price.RegularPrice, price.SalePrice)
+		item.Quantity = ondcQuantity(v.ManageStock, availableQty(v.StockQuantity, reserved[keyOf(p.ID, &v.ID)]))
+
+		values := make([]string, 0, len(names))
+		attrTag := ondc.Tag{Code: "attribute"}
+		for _, name := range names {
+			values = append(values, attrs[name])
+			attrTag.List = append(attrTag.List, ondc.TagItem{Code: strings.ToLower(name), Value: attrs[name]})
+		}
+		if len(values) > 0 {
+			item.Descriptor.Name = fmt.Sprintf("%s (%s)", view.Name, strings.Join(values, ", "))
+			item.Tags = []ondc.Tag{attrTag}
+		}
+		if img := variantImage(view.Images, v.ID); img != "" {
+			item.Descriptor.Images = []string{img}
+		}
+		items = append(items, item)
+	}
+	if len(attrNames) > 0 {
+		attrList := make([]ondc.TagItem, 0, len(attrNames))
+		for i, name := range attrNames {
+			attrList = append(attrList, ondc.TagItem{Code: "name", Value: "item.tags.attribute." + strings.ToLower(name)},
+				ondc.TagItem{Code: "seq", Value: strconv.Itoa(i + 1)})
+		}
+		group.Tags = append(group.Tags, ondc.Tag{Code: "attr", List: attrList})
+	}
+	return items, group
+}
+
+// ondcView is the product as ONDC buyers see it (ChannelOverrides preloaded for "ondc").
+func ondcView(p *models.Product) ChannelView {
+	var override ChannelOverride
+	if len(p.ChannelOverrides) > 0 {
+		// invalid JSON can't get past the API; fall back to no override if it somehow did
+		override, _ = ParseChannelOverride(p.ChannelOverrides[0].Data)
+	}
+	return BuildChannelView(p, "ondc", override)
+}
+
+// ondcStock picks the active location holding the most stock (variants included) and the
+// product's own total across active locations. Products without location stock rows use
+// their own stock and no location.
+func ondcStock(p *models.Product, activeLocations map[uint]bool) (string, int) {
+	total := 0
+	atLocation := make(map[uint]int)
+	for _, ls := range p.LocationStock {
+		if !activeLocations[ls.LocationID] {
+			continue
+		}
+		atLocation[ls.LocationID] += ls.StockQty
+		if ls.VariantID == nil {
+			total += ls.StockQty
+		}
+	}
+	best, bestQty := uint(0), -1
+	for id, qty := range atLocation {
+		if qty > bestQty || (qty == bestQty && id < best) {
+			best, bestQty = id, qty
+		}
+	}
+	if bestQty < 0 {
+		if len(activeLocations) > 0 {
+			// no per-location rows: ship from the first active location
+			first := uint(0)
+			for id := range activeLocations {
+				if first == 0 || id < first {
+					first = id
+				}
+			}
+			return strconv.FormatUint(uint64(first), 10), p.StockQuantity
+		}
+		return "", p.StockQuantity
+	}
+	return strconv.FormatUint(uint64(best), 10), total
+}
+
+func ondcFulfillmentID(fulfillmentType string) string {
+	if fulfillmentType == "pickup" {
+		return ondcFulfillmentPickupID
+	}
+	return ondcFulfillmentDeliveryID
+}
+
+// ondcPrice sells at the sale price when there is one, with the regular price as the maximum.
+func ondcPrice(regular float64, sale *float64) ondc.Price {
+	return ondc.Price{
+		Currency:     ONDCCurrency,
+		Value:        formatONDCAmount(ondcUnitPrice(regular, sale)),
+		MaximumValue: formatONDCAmount(regular),
+	}
+}
+
+func ondcUnitPrice(regular float64, sale *float64) float64 {
+	if sale != nil && *sale < regular {
+		return *sale
+	}
+	return regular
+}
+
+func ondcQuantity(manageStock bool, stock int) *ondc.ItemQuantity {
+	if !manageStock {
+		return nil
+	}
+	if stock < 0 {
+		stock = 0
+	}
+	count := strconv.Itoa(stock)
+	return &ondc.ItemQuantity{Available: &ondc.Count{Count: count}, Maximum: &ondc.Count{Count: count}}
+}
+
+func variantImage(images []ChannelImage, variantID uint) string {
+	for _, img := range images {
+		if img.VariantID != nil && *img.VariantID == variantID {
+			return img.Src
+		}
+	}
+	return ""
+}
+
+func formatONDCAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func toONDCLocation(l models.SellerLocation) ondc.Location {
+	loc := ondc.Location{
+		ID:  strconv.FormatUint(uint64(l.ID), 10),
+		GPS: l.GPS,
+		Address: &ondc.Address{
+			Street:   strings.TrimSpace(l.AddressLine1 + " " + l.AddressLine2),
+			Locality: l.Name,
+			City:     l.City,
+			State:    l.State,
+			Country:  l.Country,
+			AreaCode: l.AreaCode,
+		},
+	}
+	if l.CityCode != "" {
+		loc.City = &ondc.City{Code: l.CityCode}
+	}
+	return loc
+}
@@ -0,0 +1,722 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"github.com/RvShivam/inventify/internal/ondc"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ondcReservationTTL is how long stock quoted in on_select/on_init stays held for the buyer.
+const ondcReservationTTL = 15 * time.Minute
+
+// ONDCOrderResult is what an order action answers with in its on_<action> callback.
+// StockChanges lists stock held, released or moved by the action so the caller can
+// announce the new available-to-sell.
+type ONDCOrderResult struct {
+	Order          ondc.Order
+	OrganizationID uint
+	StockChanges   []StockChange
+}
+
+// ondcLine is one requested item resolved to our product (and variant) and priced.
+type ondcLine struct {
+	ItemID        string
+	FulfillmentID string
+	Product       *models.Product
+	Variant       *models.ProductVariant
+	Qty           int
+	UnitPrice     float64
+	Title         string
+}
+
+func (l ondcLine) reservationItem() ReservationItem {
+	item := ReservationItem{ProductID: l.Product.ID, Qty: l.Qty}
+	if l.Variant != nil {
+		item.VariantID = &l.Variant.ID
+	}
+	return item
+}
+
+// ondcOrderLine is stored in Order.LineItems; ProductID/VariationID are our ids.
+type ondcOrderLine struct {
+	models.OrderLineItem
+	ONDCItemID string `json:"ondc_item_id"`
+}
+
+// ondcOrderRaw is stored in Order.RawData for ONDC orders.
+type ondcOrderRaw struct {
+	Context ondc.Context    `json:"context"`
+	Order   ondc.Order      `json:"order"`
+	Returns []ondcReturn    `json:"returns,omitempty"`
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+type ondcReturn struct {
+	ItemID      string `json:"item_id"`
+	Qty         int    `json:"qty"`
+	ReasonCode  string `json:"reason_code,omitempty"`
+	RequestedAt string `json:"requested_at"`
+}
+
+// Select quotes the requested items and holds their stock for this transaction.
+func (s *ONDCService) Select(ctx ondc.Context, o ondc.Order) (ONDCOrderResult, error) {
+	return s.quoteAndReserve(ctx, o, false)
+}
+
+// Init re-quotes (the buyer may have changed quantities), refreshes the hold and adds
+// billing and payment terms.
+func (s *ONDCService) Init(ctx ondc.Context, o ondc.Order) (ONDCOrderResult, error) {
+	return s.quoteAndReserve(ctx, o, true)
+}
+
+func (s *ONDCService) quoteAndReserve(ctx ondc.Context, o ondc.Order, withTerms bool) (ONDCOrderResult, error) {
+	res := ONDCOrderResult{Order: ondc.Order{Provider: o.Provider}}
+	orgID, err := s.ondcProvider(o.Provider.ID)
+	if err != nil {
+		return res, err
+	}
+	res.OrganizationID = orgID
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := ondcOrderLines(tx, orgID, o.Items)
+		if err != nil {
+			return err
+		}
+		res.Order = ondcQuotedOrder(o, lines)
+		res.StockChanges, err = reserveONDCLines(tx, ondcHoldContext(ctx), lines)
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+	if withTerms {
+		res.Order.Billing = o.Billing
+		res.Order.Payment = &ondc.Payment{
+			Type:        "ON-ORDER",
+			CollectedBy: "BAP",
+			Status:      "NOT-PAID",
+			Params:      &ondc.PaymentParams{Amount: res.Order.Quote.Price.Value, Currency: ONDCCurrency},
+		}
+	}
+	return res, nil
+}
+
+// Confirm turns the transaction into a models.Order (Source "ondc"): stock is deducted,
+// the transaction's holds are committed and the order is Accepted. Confirming the same
+// order id again returns the existing order.
+func (s *ONDCService) Confirm(ctx ondc.Context, o ondc.Order) (ONDCOrderResult, error) {
+	res := ONDCOrderResult{Order: ondc.Order{Provider: o.Provider}}
+	orgID, err := s.ondcProvider(o.Provider.ID)
+	if err != nil {
+		return res, err
+	}
+	res.OrganizationID = orgID
+
+	orderID := o.ID
+	if orderID == "" {
+		orderID = ctx.TransactionID
+	}
+	if existing, err := s.findONDCOrder(ctx.BapID, orderID); err == nil {
+		raw := decodeONDCRaw(existing)
+		res.Order = ondcOrderState(existing, raw.Order)
+		return res, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return res, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := ondcOrderLines(tx, orgID, o.Items)
+		if err != nil {
+			return err
+		}
+		quoted := ondcQuotedOrder(o, lines)
+		if o.Quote != nil && o.Quote.Price.Value != "" {
+			theirs, _ := strconv.ParseFloat(o.Quote.Price.Value, 64)
+			ours, _ := strconv.ParseFloat(quoted.Quote.Price.Value, 64)
+			if math.Abs(theirs-ours) > 0.005 {
+				return ondc.DomainError(ondc.ErrCodePriceChanged, fmt.Sprintf("quote is %s, not %s", quoted.Quote.Price.Value, o.Quote.Price.Value))
+			}
+		}
+
+		changes, err := commitONDCLines(tx, ondcHoldContext(ctx), orderID, lines, ondcStockAllocation(tx, orgID, o))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		out := quoted
+		out.ID = orderID
+		out.State = ondc.OrderStateAccepted
+		out.Billing = o.Billing
+		out.Payment = o.Payment
+		out.Fulfillments = ondcFulfillmentsWithState(out.Fulfillments, "Pending")
+		out.CreatedAt = now
+		out.UpdatedAt = now
+
+		order, err := newONDCOrder(orgID, ctx, o, out, lines)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if _, err := SyncOrderItems(tx, &order); err != nil {
+			return err
+		}
+		res.Order = out
+		res.StockChanges = changes
+		return nil
+	})
+	return res, err
+}
+
+// Status reports the current state of a confirmed order.
+func (s *ONDCService) Status(ctx ondc.Context, orderID string) (ONDCOrderResult, error) {
+	order, err := s.findONDCOrder(ctx.BapID, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ONDCOrderResult{}, ondc.DomainError(ondc.ErrCodeOrderNotFound, "order "+orderID+" not found")
+	} else if err != nil {
+		return ONDCOrderResult{}, err
+	}
+	raw := decodeONDCRaw(order)
+	return ONDCOrderResult{Order: ondcOrderState(order, raw.Order), OrganizationID: order.OrganizationID}, nil
+}
+
+// Cancel cancels a confirmed order and puts its stock back. Orders containing an item that
+// isn't Cancellable (ProductONDC.Cancellable) are refused, as are completed orders. Cancelling
+// before confirm just releases the buyer's holds for the transaction.
+func (s *ONDCService) Cancel(ctx ondc.Context, orderID, reasonID string) (ONDCOrderResult, error) {
+	order, err := s.findONDCOrder(ctx.BapID, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		released, err := ReleaseReservations(s.db, "ondc", ondcHoldContext(ctx))
+		if err != nil {
+			return ONDCOrderResult{}, err
+		}
+		res := ONDCOrderResult{StockChanges: released}
+		if len(released) > 0 {
+			s.db.Model(&models.Product{}).Select("organization_id").Where("id = ?", released[0].ProductID).Scan(&res.OrganizationID)
+		}
+		return res, ondc.DomainError(ondc.ErrCodeOrderNotFound, "order "+orderID+" not found")
+	} else if err != nil {
+		return ONDCOrderResult{}, err
+	}
+
+	raw := decodeONDCRaw(order)
+	res := ONDCOrderResult{OrganizationID: order.OrganizationID}
+	if order.Status == OrderStatusCancelled {
+		res.Order = ondcOrderState(order, raw.Order)
+		return res, nil
+	}
+	if err := CheckOrderTransition("ondc", order.Status, OrderStatusCancelled); err != nil {
+		res.Order = ondcOrderState(order, raw.Order)
+		return res, ondc.DomainError(ondc.ErrCodeCancellationNotAllowed, "order is already "+order.Status)
+	}
+
+	var lines []ondcOrderLine
+	_ = json.Unmarshal(order.LineItems, &lines)
+	for _, l := range lines {
+		var settings models.ProductONDC
+		if err := s.db.Where("product_id = ?", l.ProductID).First(&settings).Error; err == nil && !settings.Cancellable {
+			res.Order = ondcOrderState(order, raw.Order)
+			return res, ondc.DomainError(ondc.ErrCodeCancellationNotAllowed, "item "+l.ONDCItemID+" is not cancellable")
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// a concurrent cancel must not restock twice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status == OrderStatusCancelled {
+			return nil
+		}
+		items, err := orderItems(tx, &order)
+		if err != nil {
+			return err
+		}
+		reason := orderMovementReason(order.Source, order.Status, OrderStatusCancelled)
+		changes, err := RestockOrderStock(tx, orderItemStockLines(items), reason, "ondc_order_"+orderID)
+		if err != nil {
+			return err
+		}
+		res.StockChanges = changes
+
+		raw.Order.State = ondc.OrderStateCancelled
+		raw.Order.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		raw.Order.Cancellation = &ondc.Cancellation{CancelledBy: ctx.BapID}
+		raw.Order.Cancellation.Reason.ID = reasonID
+		rawJSON, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		order.Status = OrderStatusCancelled
+		order.RawData = datatypes.JSON(rawJSON)
+		return tx.Save(&order).Error
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Order = ondcOrderState(order, raw.Order)
+	return res, nil
+}
+
+// Update handles item returns (update_target "item", items tagged update_type=return).
+// Items that aren't Returnable (ProductONDC.Returnable) are refused. Stock comes back
+// only once the return is received, so nothing moves here.
+func (s *ONDCService) Update(ctx ondc.Context, target string, o ondc.Order) (ONDCOrderResult, error) {
+	order, err := s.findONDCOrder(ctx.BapID, o.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ONDCOrderResult{}, ondc.DomainError(ondc.ErrCodeOrderNotFound, "order "+o.ID+" not found")
+	} else if err != nil {
+		return ONDCOrderResult{}, err
+	}
+	raw := decodeONDCRaw(order)
+	res := ONDCOrderResult{Order: ondcOrderState(order, raw.Order), OrganizationID: order.OrganizationID}
+
+	if target != "item" {
+		return res, ondc.DomainError(ondc.ErrCodeInvalidOrder, "only item returns can be updated")
+	}
+	if order.Status == OrderStatusCancelled {
+		return res, ondc.DomainError(ondc.ErrCodeReturnNotAllowed, "order is cancelled")
+	}
+
+	var lines []ondcOrderLine
+	_ = json.Unmarshal(order.LineItems, &lines)
+	byItem := make(map[string]ondcOrderLine, len(lines))
+	for _, l := range lines {
+		byItem[l.ONDCItemID] = l
+	}
+	returned := make(map[string]int)
+	for _, r := range raw.Returns {
+		returned[r.ItemID] += r.Qty
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var requests []ondcReturn
+	for _, it := range o.Items {
+		if tagValue(it.Tags, "update_type", "type") != "return" {
+			continue
+		}
+		line, ok := byItem[it.ID]
+		if !ok {
+			return res, ondc.DomainError(ondc.ErrCodeItemNotFound, "item "+it.ID+" is not in the order")
+		}
+		if it.Quantity.Count <= 0 || returned[it.ID]+it.Quantity.Count > line.Quantity {
+			return res, ondc.DomainError(ondc.ErrCodeInvalidOrder, "return quantity for "+it.ID+" exceeds what was ordered")
+		}
+		var settings models.ProductONDC
+		if err := s.db.Where("product_id = ?", line.ProductID).First(&settings).Error; err == nil && !settings.Returnable {
+			return res, ondc.DomainError(ondc.ErrCodeReturnNotAllowed, "item "+it.ID+" is not returnable")
+		}
+		requests = append(requests, ondcReturn{
+			ItemID:      it.ID,
+			Qty:         it.Quantity.Count,
+			ReasonCode:  tagValue(it.Tags, "update_type", "reason_code"),
+			RequestedAt: now,
+		})
+	}
+	if len(requests) == 0 {
+		return res, ondc.DomainError(ondc.ErrCodeInvalidOrder, "no items marked for return")
+	}
+
+	raw.Returns = append(raw.Returns, requests...)
+	for i := range requests {
+		raw.Order.Fulfillments = append(raw.Order.Fulfillments, ondc.OrderFulfillment{
+			ID:    fmt.Sprintf("R%d", len(raw.Returns)-len(requests)+i+1),
+			Type:  "Return",
+			State: &ondc.FulfillmentState{Descriptor: ondc.Descriptor{Code: "Return_Initiated"}},
+		})
+	}
+	raw.Order.UpdatedAt = now
+	rawJSON, err := json.Marshal(raw)
+	if err != nil {
+		return res, err
+	}
+	if err := s.db.Model(&order).Update("raw_data", datatypes.JSON(rawJSON)).Error; err != nil {
+		return res, err
+	}
+	res.Order = ondcOrderState(order, raw.Order)
+	return res, nil
+}
+
+// ondcProvider maps provider.id (our organization id) to an org with an active ONDC channel.
+func (s *ONDCService) ondcProvider(providerID string) (uint, error) {
+	id, err := strconv.ParseUint(providerID, 10, 64)
+	if err != nil {
+		return 0, ondc.DomainError(ondc.ErrCodeProviderNotFound, "provider "+providerID+" not found")
+	}
+	var count int64
+	if err := s.db.Model(&models.Channel{}).
+		Where("organization_id = ? AND name = ? AND is_active = ?", id, "ondc", true).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ondc.DomainError(ondc.ErrCodeProviderNotFound, "provider "+providerID+" not found")
+	}
+	return uint(id), nil
+}
+
+// findONDCOrder looks an order up by its ONDC id, scoped to the buyer app that placed it.
+func (s *ONDCService) findONDCOrder(bapID, orderID string) (models.Order, error) {
+	var order models.Order
+	err := s.db.Where("source = ? AND external_id = ? AND raw_data->'context'->>'bap_id' = ?", "ondc", orderID, bapID).
+		First(&order).Error
+	return order, err
+}
+
+// ondcOrderLines resolves and prices the requested items. Repeated items are merged.
+func ondcOrderLines(tx *gorm.DB, orgID uint, items []ondc.OrderItem) ([]ondcLine, error) {
+	if len(items) == 0 {
+		return nil, ondc.DomainError(ondc.ErrCodeInvalidOrder, "order has no items")
+	}
+	var lines []ondcLine
+	index := make(map[string]int)
+	for _, it := range items {
+		if it.Quantity.Count <= 0 {
+			return nil, ondc.DomainError(ondc.ErrCodeInvalidOrder, "quantity for "+it.ID+" must be positive")
+		}
+		if i, ok := index[it.ID]; ok {
+			lines[i].Qty += it.Quantity.Count
+			continue
+		}
+		p, v, err := findONDCItem(tx, orgID, it.ID)
+		if err != nil {
+			return nil, err
+		}
+		line := ondcLine{ItemID: it.ID, FulfillmentID: it.FulfillmentID, Product: p, Variant: v, Qty: it.Quantity.Count}
+		view := ondcView(p)
+		line.Title = view.Name
+		if v != nil {
+			price := view.Variant(v)
+			line.UnitPrice = ondcUnitPrice(price.RegularPrice, price.SalePrice)
+			var attrs map[string]string
+			_ = json.Unmarshal(v.Attributes, &attrs)
+			names := make([]string, 0, len(attrs))
+			for name := range attrs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			values := make([]string, 0, len(names))
+			for _, name := range names {
+				values = append(values, attrs[name])
+			}
+			if len(values) > 0 {
+				line.Title = fmt.Sprintf("%s (%s)", view.Name, strings.Join(values, ", "))
+			}
+		} else {
+			line.UnitPrice = ondcUnitPrice(view.RegularPrice, view.SalePrice)
+		}
+		if line.FulfillmentID == "" {
+			line.FulfillmentID = ondcFulfillmentID(p.ProductONDC.FulfillmentType)
+		}
+		index[it.ID] = len(lines)
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// findONDCItem resolves a catalog item id (see ONDCItemID) to one of the org's ONDC products.
+// The parent of a variable product is not orderable; its variants are.
+func findONDCItem(tx *gorm.DB, orgID uint, itemID string) (*models.Product, *models.ProductVariant, error) {
+	notFound := ondc.DomainError(ondc.ErrCodeItemNotFound, "item "+itemID+" not found")
+
+	var productID, variantID uint
+	var settings models.ProductONDC
+	var variant models.ProductVariant
+	if err := tx.Joins("JOIN products ON products.id = product_ondcs.product_id").
+		Where("products.organization_id = ? AND product_ondcs.ondc_item_id = ?", orgID, itemID).
+		First(&settings).Error; err == nil {
+		productID = settings.ProductID
+	} else if err := tx.Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("products.organization_id = ? AND product_variants.ondc_item_id = ?", orgID, itemID).
+		First(&variant).Error; err == nil {
+		productID, variantID = variant.ProductID, variant.ID
+	} else {
+		pid, vid, _ := strings.Cut(itemID, "-")
+		p, err := strconv.ParseUint(pid, 10, 64)
+		if err != nil {
+			return nil, nil, notFound
+		}
+		productID = uint(p)
+		if vid != "" {
+			v, err := strconv.ParseUint(vid, 10, 64)
+			if err != nil {
+				return nil, nil, notFound
+			}
+			variantID = uint(v)
+		}
+	}
+
+	var product models.Product
+	if err := ondcProducts(tx, orgID).Where("products.id = ?", productID).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, notFound
+		}
+		return nil, nil, err
+	}
+	if variantID == 0 {
+		if len(product.Variants) > 0 {
+			return nil, nil, notFound
+		}
+		return &product, nil, nil
+	}
+	for i := range product.Variants {
+		if product.Variants[i].ID == variantID {
+			return &product, &product.Variants[i], nil
+		}
+	}
+	return nil, nil, notFound
+}
+
+// ondcQuotedOrder echoes the request's provider and fulfillments with our items and quote.
+func ondcQuotedOrder(o ondc.Order, lines []ondcLine) ondc.Order {
+	out := ondc.Order{Provider: o.Provider, Fulfillments: o.Fulfillments}
+	quote := &ondc.Quote{TTL: "PT15M"}
+	total := 0.0
+	for _, l := range lines {
+		out.Items = append(out.Items, ondc.OrderItem{
+			ID:            l.ItemID,
+			FulfillmentID: l.FulfillmentID,
+			Quantity:      ondc.ItemCount{Count: l.Qty},
+		})
+		lineTotal := l.UnitPrice * float64(l.Qty)
+		total += lineTotal
+		b := ondc.QuoteBreakup{
+			ItemID:       l.ItemID,
+			ItemQuantity: &ondc.ItemCount{Count: l.Qty},
+			TitleType:    ondc.TitleTypeItem,
+			Title:        l.Title,
+			Price:        ondc.Price{Currency: ONDCCurrency, Value: formatONDCAmount(lineTotal)},
+		}
+		b.Item = &struct {
+			Price    ondc.Price         `json:"price"`
+			Quantity *ondc.ItemQuantity `json:"quantity,omitempty"`
+		}{Price: ondc.Price{Currency: ONDCCurrency, Value: formatONDCAmount(l.UnitPrice)}}
+		quote.Breakup = append(quote.Breakup, b)
+	}
+	if len(out.Fulfillments) == 0 && len(lines) > 0 {
+		out.Fulfillments = []ondc.OrderFulfillment{{ID: lines[0].FulfillmentID}}
+	}
+	for _, f := range out.Fulfillments {
+		// we don't charge for delivery; list it so buyers can show the zero line
+		quote.Breakup = append(quote.Breakup, ondc.QuoteBreakup{
+			ItemID:    f.ID,
+			TitleType: ondc.TitleTypeDelivery,
+			Title:     "Delivery charges",
+			Price:     ondc.Price{Currency: ONDCCurrency, Value: formatONDCAmount(0)},
+		})
+	}
+	quote.Price = ondc.Price{Currency: ONDCCurrency, Value: formatONDCAmount(total)}
+	out.Quote = quote
+	return out
+}
+
+// ondcHoldContext is the reservation context of a buyer's transaction. It carries the
+// (signature-checked) bap_id so one buyer app can't touch another's holds by sending its
+// transaction_id.
+func ondcHoldContext(ctx ondc.Context) string {
+	return ctx.BapID + ":" + ctx.TransactionID
+}
+
+// reserveONDCLines holds the lines' stock for the transaction (see ReserveStock); holdCtx
+// is its ondcHoldContext.
+func reserveONDCLines(tx *gorm.DB, holdCtx string, lines []ondcLine) ([]StockChange, error) {
+	items := make([]ReservationItem, 0, len(lines))
+	for _, l := range lines {
+		items = append(items, l.reservationItem())
+	}
+	changes, err := ReserveStock(tx, "ondc", holdCtx, items, time.Now().Add(ondcReservationTTL))
+	return changes, ondcStockError(err, lines)
+}
+
+// commitONDCLines re-holds the confirmed lines (the buyer may confirm without a live hold)
+// and commits them, deducting stock.
+func commitONDCLines(tx *gorm.DB, holdCtx, orderID string, lines []ondcLine, alloc StockAllocation) ([]StockChange, error) {
+	if _, err := reserveONDCLines(tx, holdCtx, lines); err != nil {
+		return nil, err
+	}
+	changes, err := CommitReservations(tx, "ondc", holdCtx, "order_ondc", "ondc_order_"+orderID, alloc)
+	return changes, ondcStockError(err, lines)
+}
+
+// ondcStockAllocation picks locations by the ondc channel's location rule and the first
+// fulfillment's delivery end.
+func ondcStockAllocation(tx *gorm.DB, orgID uint, o ondc.Order) StockAllocation {
+	alloc := StockAllocation{Rule: channelLocationRule(tx, orgID, "ondc")}
+	for _, f := range o.Fulfillments {
+		if f.End == nil || f.End.Location == nil {
+			continue
+		}
+		alloc.GPS = f.End.Location.GPS
+		if f.End.Location.Address != nil {
+			alloc.AreaCode = f.End.Location.Address.AreaCode
+		}
+		break
+	}
+	return alloc
+}
+
+// ondcStockError turns a stock shortage into the 40002 callback error for the item.
+func ondcStockError(err error, lines []ondcLine) error {
+	var short *InsufficientStockError
+	if !errors.As(err, &short) {
+		return err
+	}
+	itemID := fmt.Sprint(short.ProductID)
+	for _, l := range lines {
+		if keyOf(l.Product.ID, l.reservationItem().VariantID) == keyOf(short.ProductID, short.VariantID) {
+			itemID = l.ItemID
+		}
+	}
+	return ondc.DomainError(ondc.ErrCodeItemQuantityUnavailable, fmt.Sprintf("only %d of %s available", short.Available, itemID))
+}
+
+// newONDCOrder builds the models.Order for a confirmed ONDC order.
+func newONDCOrder(orgID uint, ctx ondc.Context, req, out ondc.Order, lines []ondcLine) (models.Order, error) {
+	order := models.Order{
+		OrganizationID: orgID,
+		ExternalID:     out.ID,
+		Source:         "ondc",
+		Status:         OrderStatusProcessing,
+		Currency:       ONDCCurrency,
+	}
+	order.Total, _ = strconv.ParseFloat(out.Quote.Price.Value, 64)
+
+	var billing models.OrderAddress
+	if b := req.Billing; b != nil {
+		order.CustomerName = b.Name
+		order.CustomerEmail = b.Email
+		billing = orderAddressFromONDC(b.Address)
+		billing.FirstName, billing.Email, billing.Phone = b.Name, b.Email, b.Phone
+	}
+	var shipping models.OrderAddress
+	for _, f := range req.Fulfillments {
+		if f.End == nil {
+			continue
+		}
+		if f.End.Location != nil {
+			shipping = orderAddressFromONDC(f.End.Location.Address)
+			shipping.GPS = f.End.Location.GPS
+		}
+		if f.End.Person != nil {
+			shipping.FirstName = f.End.Person.Name
+		}
+		if f.End.Contact != nil {
+			shipping.Email, shipping.Phone = f.End.Contact.Email, f.End.Contact.Phone
+		}
+		break
+	}
+
+	items := make([]ondcOrderLine, 0, len(lines))
+	for _, l := range lines {
+		item := ondcOrderLine{ONDCItemID: l.ItemID}
+		item.Name = l.Title
+		item.ProductID = int64(l.Product.ID)
+		item.SKU = l.Product.SKU
+		if l.Variant != nil {
+			item.VariationID = int64(l.Variant.ID)
+			item.SKU = l.Variant.SKU
+		}
+		item.Quantity = l.Qty
+		item.Price = l.UnitPrice
+		item.Subtotal = formatONDCAmount(l.UnitPrice * float64(l.Qty))
+		item.Total = item.Subtotal
+		items = append(items, item)
+	}
+
+	request, _ := json.Marshal(req)
+	raw := ondcOrderRaw{Context: ctx, Order: out, Request: request}
+	for _, v := range []struct {
+		dst *datatypes.JSON
+		src interface{}
+	}{
+		{&order.BillingAddress, billing},
+		{&order.ShippingAddress, shipping},
+		{&order.LineItems, items},
+		{&order.RawData, raw},
+	} {
+		b, err := json.Marshal(v.src)
+		if err != nil {
+			return order, err
+		}
+		*v.dst = datatypes.JSON(b)
+	}
+	return order, nil
+}
+
+func orderAddressFromONDC(a *ondc.Address) models.OrderAddress {
+	if a == nil {
+		return models.OrderAddress{}
+	}
+	return models.OrderAddress{
+		FirstName: a.Name,
+		Address1:  strings.TrimSpace(a.Building + " " + a.Street),
+		Address2:  a.Locality,
+		City:      a.City,
+		State:     a.State,
+		Postcode:  a.AreaCode,
+		Country:   a.Country,
+	}
+}
+
+func decodeONDCRaw(order models.Order) ondcOrderRaw {
+	var raw ondcOrderRaw
+	_ = json.Unmarshal(order.RawData, &raw)
+	return raw
+}
+
+// ondcOrderState is the stored on_confirm order with its state brought up to date.
+func ondcOrderState(order models.Order, o ondc.Order) ondc.Order {
+	switch order.Status {
+	case "pending", "on-hold":
+		o.State = ondc.OrderStateCreated
+	case "processing":
+		o.State = ondc.OrderStateAccepted
+	case "completed":
+		o.State = ondc.OrderStateCompleted
+	case "cancelled", "refunded", "failed":
+		o.State = ondc.OrderStateCancelled
+	default:
+		o.State = ondc.OrderStateInProgress
+	}
+	if o.ID == "" {
+		o.ID = order.ExternalID
+	}
+	return o
+}
+
+func ondcFulfillmentsWithState(fs []ondc.OrderFulfillment, code string) []ondc.OrderFulfillment {
+	out := make([]ondc.OrderFulfillment, len(fs))
+	for i, f := range fs {
+		f.State = &ondc.FulfillmentState{Descriptor: ondc.Descriptor{Code: code}}
+		out[i] = f
+	}
+	return out
+}
+
+// tagValue finds list item `code` inside the tag group `group`.
+func tagValue(tags []ondc.Tag, group, code string) string {
+	for _, t := range tags {
+		if t.Code != group {
+			continue
+		}
+		for _, item := range t.List {
+			if item.Code == code {
+				return item.Value
+			}
+		}
+	}
+	return ""
+}
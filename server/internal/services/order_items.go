@@ -0,0 +1,222 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+const orderItemBackfillBatch = 200
+
+// OrderLine is a stored order line item with the local product (and variant) it refers to,
+// when we have one.
+type OrderLine struct {
+	models.OrderLineItem
+	LocalProductID *uint
+	LocalVariantID *uint
+}
+
+// DecodeOrderLineItems parses an order's stored line items. Items that don't decode are
+// skipped.
+func DecodeOrderLineItems(order models.Order) []models.OrderLineItem {
+	var raw []json.RawMessage
+	_ = json.Unmarshal(order.LineItems, &raw)
+	items := make([]models.OrderLineItem, 0, len(raw))
+	for _, r := range raw {
+		var item models.OrderLineItem
+		if err := json.Unmarshal(r, &item); err == nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// OrderLines returns the order's line items linked back to local products, as recorded in
+// order_items.
+func (s *OrderService) OrderLines(order models.Order) ([]OrderLine, error) {
+	items, err := orderItems(s.db, &order)
+	if err != nil {
+		return nil, err
+	}
+	byPosition := make(map[int]models.OrderItem, len(items))
+	for _, it := range items {
+		byPosition[it.Position] = it
+	}
+	decoded := DecodeOrderLineItems(order)
+	lines := make([]OrderLine, 0, len(decoded))
+	for i, item := range decoded {
+		it := byPosition[i]
+		lines = append(lines, OrderLine{OrderLineItem: item, LocalProductID: it.ProductID, LocalVariantID: it.VariantID})
+	}
+	return lines, nil
+}
+
+// SyncOrderItems replaces the order's order_items rows with its current line items.
+func SyncOrderItems(tx *gorm.DB, order *models.Order) ([]models.OrderItem, error) {
+	items, err := buildOrderItems(tx, order)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderItem{}).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// orderItems returns the order's order_items rows, building them from Order.LineItems for
+// an order that has none yet (not backfilled).
+func orderItems(tx *gorm.DB, order *models.Order) ([]models.OrderItem, error) {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("position ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		return items, nil
+	}
+	return buildOrderItems(tx, order)
+}
+
+// orderItemStockLines is what the items take from stock; unlinked items take nothing.
+func orderItemStockLines(items []models.OrderItem) []OrderStockLine {
+	lines := make([]OrderStockLine, 0, len(items))
+	for _, it := range items {
+		if it.ProductID == nil {
+			continue
+		}
+		lines = append(lines, OrderStockLine{ProductID: *it.ProductID, VariantID: it.VariantID, SKU: it.SKU, Qty: it.Quantity})
+	}
+	return lines
+}
+
+// buildOrderItems normalizes Order.LineItems into OrderItems linked to local products.
+// Woo lines carry Woo product/variation IDs and are matched via ProductWoo and
+// ProductVariant.WooVariationID; ONDC lines already carry local IDs.
+func buildOrderItems(tx *gorm.DB, order *models.Order) ([]models.OrderItem, error) {
+	decoded := DecodeOrderLineItems(*order)
+	items := make([]models.OrderItem, 0, len(decoded))
+	for i, li := range decoded {
+		item := models.OrderItem{
+			OrderID:            order.ID,
+			OrganizationID:     order.OrganizationID,
+			Position:           i,
+			ChannelProductID:   li.ProductID,
+			ChannelVariationID: li.VariationID,
+			SKU:                li.SKU,
+			Name:               li.Name,
+			Quantity:           li.Quantity,
+			UnitPrice:          li.Price,
+		}
+		if order.Source == "woocommerce" {
+			item.ExternalLineID = li.ID
+		}
+		item.Subtotal, _ = strconv.ParseFloat(li.Subtotal, 64)
+		item.Tax, _ = strconv.ParseFloat(li.TotalTax, 64)
+		item.Total, _ = strconv.ParseFloat(li.Total, 64)
+		if item.UnitPrice == 0 && item.Quantity > 0 {
+			item.UnitPrice = item.Subtotal / float64(item.Quantity)
+		}
+
+		var err error
+		item.ProductID, item.VariantID, err = linkOrderLine(tx, order, li)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// linkOrderLine finds the local product (and variant) a channel line item was sold from.
+func linkOrderLine(tx *gorm.DB, order *models.Order, li models.OrderLineItem) (*uint, *uint, error) {
+	switch order.Source {
+	case "woocommerce":
+		if li.VariationID > 0 {
+			var variant models.ProductVariant
+			err := tx.Select("product_variants.id", "product_variants.product_id").
+				Joins("JOIN products ON products.id = product_variants.product_id").
+				Where("product_variants.woo_variation_id = ? AND products.organization_id = ?", li.VariationID, order.OrganizationID).
+				First(&variant).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("order %s: no local variant for Woo variation %d", order.ExternalID, li.VariationID)
+				return nil, nil, nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			return &variant.ProductID, &variant.ID, nil
+		}
+		var productWoo models.ProductWoo
+		err := tx.Select("product_woos.product_id").
+			Joins("JOIN products ON products.id = product_woos.product_id").
+			Where("product_woos.woo_product_id = ? AND products.organization_id = ?", li.ProductID, order.OrganizationID).
+			First(&productWoo).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("order %s: no local product for Woo product %d", order.ExternalID, li.ProductID)
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return &productWoo.ProductID, nil, nil
+	case "ondc":
+		var productID, variantID *uint
+		if li.ProductID > 0 {
+			pid := uint(li.ProductID)
+			productID = &pid
+		}
+		if li.VariationID > 0 {
+			vid := uint(li.VariationID)
+			variantID = &vid
+		}
+		return productID, variantID, nil
+	}
+	return nil, nil, nil
+}
+
+// BackfillOrderItems fills order_items for orders stored before the table existed.
+// Safe to run on every start; it only touches orders with line items but no rows.
+// Orders are paged by (created_at, id), so ones whose lines don't decode or match are
+// passed over instead of being read again.
+func BackfillOrderItems(db *gorm.DB) (int, error) {
+	filled := 0
+	var last *models.Order
+	for {
+		query := db.Omit("raw_data").
+			Where("jsonb_typeof(line_items) = 'array' AND jsonb_array_length(line_items) > 0").
+			Where("NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id)")
+		if last != nil {
+			query = query.Where("(orders.created_at, orders.id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+		var orders []models.Order
+		if err := query.
+			Order("orders.created_at ASC, orders.id ASC").
+			Limit(orderItemBackfillBatch).
+			Find(&orders).Error; err != nil {
+			return filled, err
+		}
+		for i := range orders {
+			items, err := SyncOrderItems(db, &orders[i])
+			if err != nil {
+				return filled, fmt.Errorf("order %s: %w", orders[i].ID, err)
+			}
+			if len(items) > 0 {
+				filled++
+			}
+		}
+		if len(orders) < orderItemBackfillBatch {
+			return filled, nil
+		}
+		last = &orders[len(orders)-1]
+	}
+}
@@ -3,11 +3,13 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"strconv"
 
 	"github.com/RvShivam/inventify/internal/models"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderService struct {
@@ -18,8 +20,19 @@ func NewOrderService(db *gorm.DB) *OrderService {
 	return &OrderService{db: db}
 }
 
-// CreateOrUpdateOrderFromWoo processes a WooCommerce webhook payload
-func (s *OrderService) CreateOrUpdateOrderFromWoo(organizationID uint, payload map[string]interface{}) error {
+// StockChange is a stock adjustment made while processing an order.
+type StockChange struct {
+	ProductID uint
+	VariantID *uint
+	ChangeQty int
+}
+
+// CreateOrUpdateOrderFromWoo processes a WooCommerce webhook payload.
+// New orders take their stock; updates must be a legal status transition (see
+// CheckOrderTransition) and move stock by the difference: cancelling, refunding or failing
+// puts it back, reopening takes it again, and changed line quantities are applied as deltas.
+// It returns the stock changes it made so the caller can announce them.
+func (s *OrderService) CreateOrUpdateOrderFromWoo(organizationID uint, payload map[string]interface{}) ([]StockChange, error) {
 	// 1. Extract Core Fields
 	idVal, _ := payload["id"].(float64) // JSON numbers are float64
 	externalID := fmt.Sprintf("%.0f", idVal)
@@ -41,25 +54,11 @@ func (s *OrderService) CreateOrUpdateOrderFromWoo(organizationID uint, payload m
 	lineItemsJSON, _ := json.Marshal(payload["line_items"])
 	rawJSON, _ := json.Marshal(payload)
 
-	// 3. Upsert Order
-	var order models.Order
-	err := s.db.Where("external_id = ? AND source = ?", externalID, "woocommerce").First(&order).Error
-
-	if err == nil {
-		// Update existing
-		order.Status = status
-		order.Total = total
-		order.Currency = currency
-		order.CustomerName = customerName
-		order.CustomerEmail = email
-		order.BillingAddress = datatypes.JSON(billingJSON)
-		order.ShippingAddress = datatypes.JSON(shippingJSON)
-		order.LineItems = datatypes.JSON(lineItemsJSON)
-		order.RawData = datatypes.JSON(rawJSON)
-		return s.db.Save(&order).Error
-	} else if err == gorm.ErrRecordNotFound {
-		// Create new
-		newOrder := models.Order{
+	// 3. Upsert the order and take its stock in one transaction, so a failure leaves
+	// neither behind and the consumer's retry starts clean.
+	var changes []StockChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		order := models.Order{
 			OrganizationID:  organizationID,
 			ExternalID:      externalID,
 			Source:          "woocommerce",
@@ -73,68 +72,77 @@ func (s *OrderService) CreateOrUpdateOrderFromWoo(organizationID uint, payload m
 			LineItems:       datatypes.JSON(lineItemsJSON),
 			RawData:         datatypes.JSON(rawJSON),
 		}
-
-		if err := s.db.Create(&newOrder).Error; err != nil {
-			return err
+		// ux_order_source_external: a concurrent delivery of the same order loses here
+		// and falls through to the update path instead of deducting stock twice.
+		created := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "organization_id"}, {Name: "source"}, {Name: "external_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+			DoNothing:   true,
+		}).Create(&order)
+		if created.Error != nil {
+			return created.Error
 		}
 
-		// Deduct Stock for new orders
-		fmt.Println("📦 New order created, processing stock deduction...")
-		// We iterate over the raw line_items payload
-		if items, ok := payload["line_items"].([]interface{}); ok {
-			for _, item := range items {
-				itemMap, ok := item.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				// Get Woo Product ID
-				wooIDFloat, _ := itemMap["product_id"].(float64)
-				wooID := int64(wooIDFloat)
-
-				// Get Quantity
-				qtyFloat, _ := itemMap["quantity"].(float64)
-				qty := int(qtyFloat)
-
-				fmt.Printf("   - Item: WooID=%d, Qty=%d\n", wooID, qty)
-
-				if qty <= 0 {
-					continue
-				}
-
-				// Find local product via ProductWoo
-				var productWoo models.ProductWoo
-				if err := s.db.Where("woo_product_id = ?", wooID).First(&productWoo).Error; err != nil {
-					fmt.Printf("   ❌ Local product not found for WooID %d: %v\n", wooID, err)
-					continue
-				}
-				fmt.Printf("   ✅ Found local ProductID: %d\n", productWoo.ProductID)
-
-				// Update Product Stock
-				// We use a transaction or just direct update.
-				// For simplicity and speed in this sync handler:
-				if err := s.db.Model(&models.Product{}).Where("id = ?", productWoo.ProductID).
-					UpdateColumn("stock_quantity", gorm.Expr("stock_quantity - ?", qty)).Error; err != nil {
-					fmt.Printf("   ❌ Failed to update stock for product %d: %v\n", productWoo.ProductID, err)
-				} else {
-					fmt.Printf("   ✅ Stock deducted for product %d\n", productWoo.ProductID)
-				}
+		ref := fmt.Sprintf("woo_order_%s", externalID)
+		policy := channelOversellPolicy(tx, organizationID, "woocommerce")
+
+		target := &order
+		var oldLines []OrderStockLine
+		fromStatus := ""
+		if created.RowsAffected == 0 {
+			// Update existing: stock follows the status change and any line edits
+			var existing models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("organization_id = ? AND external_id = ? AND source = ?", organizationID, externalID, "woocommerce").
+				First(&existing).Error; err != nil {
+				return err
+			}
+			if err := CheckOrderTransition("woocommerce", existing.Status, status); err != nil {
+				return err
+			}
+			oldItems, err := orderItems(tx, &existing)
+			if err != nil {
+				return err
+			}
+			oldLines = orderItemStockLines(oldItems)
+			fromStatus = existing.Status
+			if fromStatus == "" {
+				fromStatus = OrderStatusPending // "" means a new order to ApplyOrderStock
+			}
 
-				// Record Movement
-				movement := models.InventoryMovement{
-					ProductID: productWoo.ProductID,
-					ChangeQty: -qty,
-					Reason:    "order_sync_woo",
-					Ref:       fmt.Sprintf("woo_order_%s", externalID),
-				}
-				s.db.Create(&movement)
+			existing.Status = status
+			existing.Total = total
+			existing.Currency = currency
+			existing.CustomerName = customerName
+			existing.CustomerEmail = email
+			existing.BillingAddress = datatypes.JSON(billingJSON)
+			existing.ShippingAddress = datatypes.JSON(shippingJSON)
+			existing.LineItems = datatypes.JSON(lineItemsJSON)
+			existing.RawData = datatypes.JSON(rawJSON)
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
 			}
-		} else {
-			fmt.Println("   ❌ Failed to parse line_items")
+			target = &existing
 		}
 
+		items, err := SyncOrderItems(tx, target)
+		if err != nil {
+			return err
+		}
+		newLines := orderItemStockLines(items)
+
+		var flag *models.OrderFlag
+		changes, flag, err = ApplyOrderStock(tx, target, oldLines, newLines, fromStatus, status, policy, ref)
+		if err != nil {
+			return err
+		}
+		if flag != nil {
+			log.Printf("order %s oversold, flagged as %s (policy %s)", externalID, flag.Reason, policy)
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	return err
+	return changes, nil
 }
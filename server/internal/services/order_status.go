@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Order statuses (WooCommerce's names; ONDC orders use the same ones).
+const (
+	OrderStatusPending    = "pending"
+	OrderStatusProcessing = "processing"
+	OrderStatusOnHold     = "on-hold"
+	OrderStatusCompleted  = "completed"
+	OrderStatusCancelled  = "cancelled"
+	OrderStatusRefunded   = "refunded"
+	OrderStatusFailed     = "failed"
+)
+
+// ErrIllegalTransition is returned (wrapped in an OrderTransitionError) when an order is
+// asked to move to a status its source doesn't allow from the current one.
+var ErrIllegalTransition = errors.New("illegal order status transition")
+
+// OrderTransitionError describes a rejected status change.
+type OrderTransitionError struct {
+	Source string
+	From   string
+	To     string
+}
+
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("%s order cannot go from %s to %s", e.Source, e.From, e.To)
+}
+
+func (e *OrderTransitionError) Is(target error) bool { return target == ErrIllegalTransition }
+
+// orderTransitions is the status state machine per order source: status -> statuses it
+// may move to. A status listed with no targets is terminal.
+var orderTransitions = map[string]map[string][]string{
+	"woocommerce": {
+		OrderStatusPending:    {OrderStatusProcessing, OrderStatusOnHold, OrderStatusCompleted, OrderStatusCancelled, OrderStatusFailed},
+		OrderStatusOnHold:     {OrderStatusPending, OrderStatusProcessing, OrderStatusCompleted, OrderStatusCancelled, OrderStatusFailed},
+		OrderStatusProcessing: {OrderStatusOnHold, OrderStatusCompleted, OrderStatusCancelled, OrderStatusRefunded, OrderStatusFailed},
+		OrderStatusCompleted:  {OrderStatusRefunded},
+		OrderStatusFailed:     {OrderStatusPending, OrderStatusProcessing, OrderStatusOnHold, OrderStatusCancelled},
+		OrderStatusCancelled:  {},
+		OrderStatusRefunded:   {},
+	},
+	"ondc": {
+		OrderStatusProcessing: {OrderStatusCompleted, OrderStatusCancelled},
+		OrderStatusCompleted:  {},
+		OrderStatusCancelled:  {},
+	},
+}
+
+// CheckOrderTransition reports whether a source's order may move from one status to
+// another. Staying put is always fine. Statuses the machine doesn't know (e.g. a custom
+// Woo status added by a plugin) may be entered from, and left to, any non-terminal status.
+func CheckOrderTransition(source, from, to string) error {
+	machine, ok := orderTransitions[source]
+	if !ok || from == to {
+		return nil
+	}
+	targets, fromKnown := machine[from]
+	if !fromKnown {
+		return nil
+	}
+	if _, toKnown := machine[to]; !toKnown && len(targets) > 0 {
+		return nil
+	}
+	for _, t := range targets {
+		if t == to {
+			return nil
+		}
+	}
+	return &OrderTransitionError{Source: source, From: from, To: to}
+}
+
+// ReleasesStock reports whether an order in this status gives its stock back.
+func ReleasesStock(status string) bool {
+	switch status {
+	case OrderStatusCancelled, OrderStatusRefunded, OrderStatusFailed:
+		return true
+	}
+	return false
+}
+
+// orderMovementReason is the InventoryMovement reason for an order stock change, e.g.
+// order_cancel_woo. New orders keep their historic reasons (order_sync_woo, order_ondc).
+func orderMovementReason(source, fromStatus, toStatus string) string {
+	suffix := source
+	if source == "woocommerce" {
+		suffix = "woo"
+	}
+	switch {
+	case fromStatus == "" && source == "woocommerce":
+		return "order_sync_woo"
+	case fromStatus == "":
+		return "order_" + suffix
+	case ReleasesStock(toStatus) && !ReleasesStock(fromStatus):
+		switch toStatus {
+		case OrderStatusRefunded:
+			return "order_refund_" + suffix
+		case OrderStatusFailed:
+			return "order_fail_" + suffix
+		}
+		return "order_cancel_" + suffix
+	case ReleasesStock(fromStatus) && !ReleasesStock(toStatus):
+		return "order_reopen_" + suffix
+	}
+	return "order_edit_" + suffix
+}
+
+// ApplyOrderStock brings an order's stock in line with a status change and/or new lines,
+// inside tx (which should hold the order row's lock). What the order holds before is
+// oldLines unless fromStatus releases stock ("" for a new order); after, newLines unless
+// toStatus does. Increases are deducted under policy (see DeductOrderStock); decreases
+// are put back, capped at what the order actually took (see RestockOrderStock).
+func ApplyOrderStock(tx *gorm.DB, order *models.Order, oldLines, newLines []OrderStockLine, fromStatus, toStatus, policy, ref string) ([]StockChange, *models.OrderFlag, error) {
+	if fromStatus == "" || ReleasesStock(fromStatus) {
+		oldLines = nil
+	}
+	if ReleasesStock(toStatus) {
+		newLines = nil
+	}
+	before, _ := mergeStockLines(oldLines)
+	after, keys := mergeStockLines(newLines)
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	var more, less []OrderStockLine
+	for _, k := range keys {
+		var was, want int
+		line := OrderStockLine{ProductID: k.ProductID, VariantID: k.variantPtr()}
+		if l, ok := before[k]; ok {
+			was, line.SKU = l.Qty, l.SKU
+		}
+		if l, ok := after[k]; ok {
+			want, line.SKU = l.Qty, l.SKU
+		}
+		switch {
+		case want > was:
+			line.Qty = want - was
+			more = append(more, line)
+		case want < was:
+			line.Qty = was - want
+			less = append(less, line)
+		}
+	}
+
+	reason := orderMovementReason(order.Source, fromStatus, toStatus)
+	changes, err := RestockOrderStock(tx, less, reason, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(more) == 0 {
+		return changes, nil, nil
+	}
+	deducted, flag, err := DeductOrderStock(tx, order, more, policy, reason, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(changes, deducted...), flag, nil
+}
+
+// RestockOrderStock puts lines back into stock inside tx, recording InventoryMovements
+// (reason, ref). Each line gives back at most what the order still holds per its ref's
+// movements, so clamped lines and repeated cancels can't create stock. Stock goes back to
+// the locations it was taken from.
+func RestockOrderStock(tx *gorm.DB, lines []OrderStockLine, reason, ref string) ([]StockChange, error) {
+	merged, keys := mergeStockLines(lines)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	held, err := orderStockHeld(tx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []StockChange
+	for _, k := range keys {
+		qty := min(merged[k].Qty, held[k].Qty)
+		if qty <= 0 {
+			continue
+		}
+		_, managed, err := lockStockLine(tx, k)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+		}
+		if !managed {
+			continue
+		}
+		if err := returnLineStock(tx, k, qty, held[k].At, reason, ref); err != nil {
+			return nil, fmt.Errorf("restock product %d: %w", k.ProductID, err)
+		}
+		changes = append(changes, StockChange{ProductID: k.ProductID, VariantID: k.variantPtr(), ChangeQty: qty})
+	}
+	return changes, nil
+}
+
+// heldStock is what an order holds of one stock line, in total and per location.
+type heldStock struct {
+	Qty int
+	At  map[uint]int
+}
+
+// orderStockHeld is the net stock an order has taken per product/variant (and location),
+// from the movements recorded under its ref.
+func orderStockHeld(tx *gorm.DB, ref string) (map[stockKey]heldStock, error) {
+	var rows []struct {
+		ProductID  uint
+		VariantID  *uint
+		LocationID *uint
+		Held       int
+	}
+	if err := tx.Model(&models.InventoryMovement{}).
+		Select("product_id, variant_id, location_id, -SUM(change_qty) AS held").
+		Where("ref = ?", ref).
+		Group("product_id, variant_id, location_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	held := make(map[stockKey]heldStock, len(rows))
+	for _, r := range rows {
+		k := keyOf(r.ProductID, r.VariantID)
+		h := held[k]
+		h.Qty += r.Held
+		if r.LocationID != nil && r.Held > 0 {
+			if h.At == nil {
+				h.At = make(map[uint]int)
+			}
+			h.At[*r.LocationID] = r.Held
+		}
+		held[k] = h
+	}
+	return held, nil
+}
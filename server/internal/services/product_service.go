@@ -1,18 +1,19 @@
 package services
 
 import (
-	"bytes"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/RvShivam/inventify/internal/crypto"
+	"github.com/RvShivam/inventify/internal/channels"
 	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -24,11 +25,18 @@ func NewProductService(db *gorm.DB) *ProductService {
 	return &ProductService{db: db}
 }
 
-// SyncProductToWoo syncs a local product to WooCommerce
-func (s *ProductService) SyncProductToWoo(productID uint) error {
+// SyncProductToWoo syncs a local product to WooCommerce.
+// Every call is recorded in ChannelPublishLog, whether it succeeds or not.
+func (s *ProductService) SyncProductToWoo(productID uint) (err error) {
+	attempt := newPublishAttempt()
+	defer func() { s.recordPublishLog(productID, "woocommerce", attempt, err) }()
+
 	// 1. Fetch Product with all necessary preloads
 	var product models.Product
-	if err := s.db.Preload("Images").Preload("ProductWoo").Preload("LocalCategory").First(&product, productID).Error; err != nil {
+	if err := s.db.Preload("Images").Preload("ProductWoo").Preload("LocalCategory").
+		Preload("Attributes", func(tx *gorm.DB) *gorm.DB { return tx.Order("position ASC") }).
+		Preload("Variants").
+		First(&product, productID).Error; err != nil {
 		return fmt.Errorf("failed to fetch product: %w", err)
 	}
 
@@ -47,36 +55,69 @@ func (s *ProductService) SyncProductToWoo(productID uint) error {
 		return fmt.Errorf("woocommerce channel not enabled for this product")
 	}
 
-	// 3. Get Active WooStore credentials
-	var store models.WooStore
-	if err := s.db.Where("organization_id = ? AND is_active = ?", product.OrganizationID, true).First(&store).Error; err != nil {
-		return fmt.Errorf("no active woocommerce store found: %w", err)
+	// 3. Get Active WooStore client
+	store, err := channels.ActiveWooStore(s.db, product.OrganizationID)
+	if err != nil {
+		return err
+	}
+	client, err := channels.NewWooClientForStore(store)
+	if err != nil {
+		return err
 	}
 
-	// Decrypt keys
-	appKey := []byte(os.Getenv("APP_SECRET_KEY"))
-	ck, err := crypto.Decrypt(store.ConsumerKeyEncrypted, appKey)
+	// 4. Construct Payload from the Woo view (base product + Woo settings + override JSON)
+	view, err := ResolveChannelView(s.db, &product, "woocommerce")
 	if err != nil {
-		return fmt.Errorf("failed to decrypt consumer key: %w", err)
+		return fmt.Errorf("failed to resolve woocommerce view: %w", err)
 	}
-	cs, err := crypto.Decrypt(store.ConsumerSecretEncrypted, appKey)
+	// channels sell what isn't held for an in-flight order
+	reserved, err := reservedQuantities(s.db, []uint{product.ID})
 	if err != nil {
-		return fmt.Errorf("failed to decrypt consumer secret: %w", err)
+		return fmt.Errorf("failed to load reservations: %w", err)
 	}
-
-	// 4. Construct Payload
 	payload := map[string]interface{}{
-		"name":              product.Name,
-		"short_description": product.ShortDescription,
-		"description":       product.Description,
-		"sku":               product.SKU,
-		"regular_price":     fmt.Sprintf("%.2f", product.RegularPrice),
-		"manage_stock":      product.ManageStock,
-		"stock_quantity":    product.StockQuantity,
+		"name":               view.Name,
+		"short_description":  view.ShortDescription,
+		"description":        view.Description,
+		"sku":                product.SKU,
+		"regular_price":      fmt.Sprintf("%.2f", view.RegularPrice),
+		"catalog_visibility": view.Visibility,
+		"manage_stock":       product.ManageStock,
+		"stock_quantity":     availableQty(product.StockQuantity, reserved[keyOf(product.ID, nil)]),
 	}
 
-	if product.SalePrice != nil {
-		payload["sale_price"] = fmt.Sprintf("%.2f", *product.SalePrice)
+	if view.SalePrice != nil {
+		payload["sale_price"] = fmt.Sprintf("%.2f", *view.SalePrice)
+	} else {
+		// clear a sale price the channel may still have
+		payload["sale_price"] = ""
+	}
+	if validStatuses[view.Status] {
+		payload["status"] = view.Status
+	}
+
+	// Variable product: price & stock live on the variations, the parent only declares attributes.
+	isVariable := len(product.Variants) > 0
+	if isVariable {
+		payload["type"] = "variable"
+		payload["manage_stock"] = false
+		delete(payload, "regular_price")
+		delete(payload, "sale_price")
+		delete(payload, "stock_quantity")
+
+		var attrs []map[string]interface{}
+		for _, a := range product.Attributes {
+			var options []string
+			_ = json.Unmarshal(a.Options, &options)
+			attrs = append(attrs, map[string]interface{}{
+				"name":      a.Name,
+				"position":  a.Position,
+				"visible":   true,
+				"variation": true,
+				"options":   options,
+			})
+		}
+		payload["attributes"] = attrs
 	}
 
 	if product.WeightKg != nil {
@@ -98,23 +139,13 @@ func (s *ProductService) SyncProductToWoo(productID uint) error {
 	}
 
 	// Images
-	if len(product.Images) > 0 {
+	if len(view.Images) > 0 {
 		var images []map[string]string
 		// Note: Woo requires public URLs. If we are localhost, this won't work for local images unless we use ngrok or similar.
-		// For now, we'll send the relative path, which Woo will likely reject or fail to fetch.
-		// In production, this should be a full URL.
-		// We will try to construct a full URL if BACKEND_URL is set.
-		baseURL := os.Getenv("BACKEND_URL")
-		if baseURL == "" {
-			baseURL = "http://localhost:8080"
-		}
-		for _, img := range product.Images {
-			fullSrc := img.Src
-			if !strings.HasPrefix(fullSrc, "http") {
-				fullSrc = strings.TrimRight(baseURL, "/") + img.Src
-			}
+		// ChannelView already made them absolute when BACKEND_URL is set.
+		for _, img := range view.Images {
 			images = append(images, map[string]string{
-				"src":      fullSrc,
+				"src":      img.Src,
 				"position": fmt.Sprintf("%d", img.Position),
 			})
 		}
@@ -137,39 +168,22 @@ func (s *ProductService) SyncProductToWoo(productID uint) error {
 
 	// 5. Send Request
 	// Check if we are creating or updating
-	var method, urlStr string
+	var method, endpoint string
 	if product.ProductWoo.WooProductID != nil && *product.ProductWoo.WooProductID > 0 {
 		// Update
 		method = "PUT"
-		urlStr = fmt.Sprintf("%s/wp-json/wc/v3/products/%d", strings.TrimRight(store.SiteURL, "/"), *product.ProductWoo.WooProductID)
+		endpoint = fmt.Sprintf("/products/%d", *product.ProductWoo.WooProductID)
 	} else {
 		// Create
 		method = "POST"
-		urlStr = fmt.Sprintf("%s/wp-json/wc/v3/products", strings.TrimRight(store.SiteURL, "/"))
-	}
-
-	jsonBody, _ := json.Marshal(payload)
-	req, _ := http.NewRequest(method, urlStr, bytes.NewBuffer(jsonBody))
-	req.SetBasicAuth(ck, cs)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	if !store.VerifySSL {
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+		endpoint = "/products"
 	}
 
-	resp, err := client.Do(req)
+	attempt.request["product"] = payload
+	bodyBytes, err := client.Do(method, endpoint, payload)
+	attempt.response["product"] = wooResponseBody(bodyBytes, err)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("woo api error (%d): %s", resp.StatusCode, string(bodyBytes))
+		return err
 	}
 
 	// 6. Parse Response and Update DB
@@ -183,6 +197,7 @@ func (s *ProductService) SyncProductToWoo(productID uint) error {
 		return fmt.Errorf("no id in woo response")
 	}
 	wooID := int64(idFloat)
+	attempt.resourceID = strconv.FormatInt(wooID, 10)
 	status, _ := wooResp["status"].(string)
 	// permalink, _ := wooResp["permalink"].(string)
 
@@ -202,16 +217,228 @@ func (s *ProductService) SyncProductToWoo(productID uint) error {
 	now := time.Now()
 	product.ProductWoo.LastPublishedAt = &now
 
+	if isVariable {
+		product.ProductWoo.Type = "variable"
+	} else {
+		product.ProductWoo.Type = "simple"
+	}
+
 	if err := s.db.Save(&product.ProductWoo).Error; err != nil {
 		return fmt.Errorf("failed to update product woo record: %w", err)
 	}
 
+	if err := s.syncWooVariations(client, wooID, &product, view, reserved, attempt); err != nil {
+		return err
+	}
+
 	// Also update ProductChannel LastPublishedAt
 	pc.LastPublishedAt = &now
 	s.db.Save(&pc)
 
-	// Log success
-	// (Optional: create ChannelPublishLog)
+	return nil
+}
+
+// wooVariationBatchSize is the WooCommerce REST limit for batch endpoints.
+const wooVariationBatchSize = 100
+
+// syncWooVariations pushes variants of a variable product as Woo variations via the batch endpoint,
+// creating new ones, updating linked ones and deleting variations whose local variant was removed.
+// Variations are priced as view has them.
+func (s *ProductService) syncWooVariations(client *channels.WooClient, wooProductID int64, product *models.Product, view ChannelView, reserved map[stockKey]int, attempt *publishAttempt) error {
+	batchEndpoint := fmt.Sprintf("/products/%d/variations/batch", wooProductID)
+
+	// Variants deleted locally but still linked on Woo
+	var removed []models.ProductVariant
+	if err := s.db.Unscoped().
+		Where("product_id = ? AND deleted_at IS NOT NULL AND woo_variation_id IS NOT NULL", product.ID).
+		Find(&removed).Error; err != nil {
+		return fmt.Errorf("failed to load removed variants: %w", err)
+	}
+	if len(removed) > 0 {
+		wooIDs := make([]int64, 0, len(removed))
+		localIDs := make([]uint, 0, len(removed))
+		for _, r := range removed {
+			wooIDs = append(wooIDs, *r.WooVariationID)
+			localIDs = append(localIDs, r.ID)
+		}
+		deletion := map[string]interface{}{"delete": wooIDs}
+		respBytes, err := client.Do("POST", batchEndpoint, deletion)
+		attempt.addVariationBatch(deletion, wooResponseBody(respBytes, err))
+		if err != nil {
+			return fmt.Errorf("variation delete failed: %w", err)
+		}
+		if err := s.db.Unscoped().Model(&models.ProductVariant{}).
+			Where("id IN ?", localIDs).Update("woo_variation_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to unlink removed variations: %w", err)
+		}
+	}
+
+	for start := 0; start < len(product.Variants); start += wooVariationBatchSize {
+		end := start + wooVariationBatchSize
+		if end > len(product.Variants) {
+			end = len(product.Variants)
+		}
+
+		var create, update []map[string]interface{}
+		var created []*models.ProductVariant
+		for i := start; i < end; i++ {
+			v := &product.Variants[i]
+			item := wooVariationPayload(v, view.Variant(v), product.Images, reserved[keyOf(product.ID, &v.ID)])
+			if v.WooVariationID != nil && *v.WooVariationID > 0 {
+				item["id"] = *v.WooVariationID
+				update = append(update, item)
+			} else {
+				create = append(create, item)
+				created = append(created, v)
+			}
+		}
+
+		batch := map[string]interface{}{}
+		if len(create) > 0 {
+			batch["create"] = create
+		}
+		if len(update) > 0 {
+			batch["update"] = update
+		}
+		respBytes, err := client.Do("POST", batchEndpoint, batch)
+		attempt.addVariationBatch(batch, wooResponseBody(respBytes, err))
+		if err != nil {
+			return fmt.Errorf("variation batch failed: %w", err)
+		}
 
+		var batchResp struct {
+			Create []struct {
+				ID    int64                  `json:"id"`
+				Error map[string]interface{} `json:"error"`
+			} `json:"create"`
+		}
+		if err := json.Unmarshal(respBytes, &batchResp); err != nil {
+			return fmt.Errorf("failed to parse variation batch response: %w", err)
+		}
+		for i, res := range batchResp.Create {
+			if i >= len(created) {
+				break
+			}
+			if res.Error != nil || res.ID == 0 {
+				return fmt.Errorf("woo rejected variation %q: %v", created[i].SKU, res.Error)
+			}
+			id := res.ID
+			created[i].WooVariationID = &id
+			if err := s.db.Model(created[i]).Update("woo_variation_id", id).Error; err != nil {
+				return fmt.Errorf("failed to store woo variation id: %w", err)
+			}
+		}
+	}
 	return nil
 }
+
+// wooVariationPayload maps a variant to the Woo variation schema, priced as the Woo view
+// has it; reserved is held stock.
+func wooVariationPayload(v *models.ProductVariant, price ChannelVariant, images []models.ProductImage, reserved int) map[string]interface{} {
+	item := map[string]interface{}{
+		"sku":            v.SKU,
+		"regular_price":  fmt.Sprintf("%.2f", price.RegularPrice),
+		"manage_stock":   v.ManageStock,
+		"stock_quantity": availableQty(v.StockQuantity, reserved),
+	}
+	if price.SalePrice != nil {
+		item["sale_price"] = fmt.Sprintf("%.2f", *price.SalePrice)
+	} else {
+		item["sale_price"] = ""
+	}
+
+	var attrs map[string]string
+	_ = json.Unmarshal(v.Attributes, &attrs)
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	wooAttrs := make([]map[string]string, 0, len(attrs))
+	for _, name := range names {
+		wooAttrs = append(wooAttrs, map[string]string{"name": name, "option": attrs[name]})
+	}
+	item["attributes"] = wooAttrs
+
+	for _, img := range images {
+		if img.VariantID != nil && *img.VariantID == v.ID {
+			item["image"] = map[string]string{"src": PublicImageURL(img.Src)}
+			break
+		}
+	}
+	return item
+}
+
+// PublicImageURL turns a stored upload path into an absolute URL channels can fetch.
+// Note: Woo requires public URLs, so BACKEND_URL must point at a reachable host in production.
+func PublicImageURL(src string) string {
+	if strings.HasPrefix(src, "http") {
+		return src
+	}
+	baseURL := os.Getenv("BACKEND_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return strings.TrimRight(baseURL, "/") + src
+}
+
+// publishAttempt collects what was sent to and received from a channel during one sync
+// so it can be written to ChannelPublishLog.
+type publishAttempt struct {
+	request    map[string]interface{}
+	response   map[string]interface{}
+	resourceID string
+}
+
+func newPublishAttempt() *publishAttempt {
+	return &publishAttempt{
+		request:  map[string]interface{}{},
+		response: map[string]interface{}{},
+	}
+}
+
+func (a *publishAttempt) addVariationBatch(req, resp interface{}) {
+	reqs, _ := a.request["variation_batches"].([]interface{})
+	a.request["variation_batches"] = append(reqs, req)
+	resps, _ := a.response["variation_batches"].([]interface{})
+	a.response["variation_batches"] = append(resps, resp)
+}
+
+// recordPublishLog writes the attempt to ChannelPublishLog (best-effort, logs on failure).
+func (s *ProductService) recordPublishLog(productID uint, channel string, attempt *publishAttempt, syncErr error) {
+	entry := models.ChannelPublishLog{
+		ProductID:         productID,
+		Channel:           channel,
+		Success:           syncErr == nil,
+		ChannelResourceID: attempt.resourceID,
+	}
+	if len(attempt.request) > 0 {
+		b, _ := json.Marshal(attempt.request)
+		entry.RequestPayload = datatypes.JSON(b)
+	}
+	if len(attempt.response) > 0 {
+		b, _ := json.Marshal(attempt.response)
+		entry.ResponsePayload = datatypes.JSON(b)
+	}
+	if syncErr != nil {
+		entry.ErrorMessage = syncErr.Error()
+	}
+	if err := s.db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write publish log for product %d: %v", productID, err)
+	}
+}
+
+// wooResponseBody is the response to log for a Woo call: the JSON body on success,
+// the error body on an API error, nothing when the request never completed.
+func wooResponseBody(body []byte, err error) interface{} {
+	var apiErr *channels.WooAPIError
+	if errors.As(err, &apiErr) {
+		body = []byte(apiErr.Body)
+	} else if err != nil {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Conflict policies for inbound Woo product changes, stored as "conflict_policy"
+// in the woocommerce Channel.Config.
+const (
+	ConflictPolicyInventifyWins = "inventify_wins"
+	ConflictPolicyWooWins       = "woo_wins"
+	ConflictPolicyNewestWins    = "newest_wins"
+
+	DefaultConflictPolicy = ConflictPolicyNewestWins
+)
+
+// Decisions recorded in ProductSyncDecision.
+const (
+	SyncDecisionAppliedRemote = "applied_remote"
+	SyncDecisionKeptLocal     = "kept_local"
+	SyncDecisionUnlinked      = "unlinked"
+)
+
+// IsValidConflictPolicy reports whether p is a known conflict policy.
+func IsValidConflictPolicy(p string) bool {
+	switch p {
+	case ConflictPolicyInventifyWins, ConflictPolicyWooWins, ConflictPolicyNewestWins:
+		return true
+	}
+	return false
+}
+
+// fieldChange is one differing field between the local product and the Woo payload.
+type fieldChange struct {
+	Local  interface{} `json:"local"`
+	Remote interface{} `json:"remote"`
+}
+
+// ApplyWooProductUpdate reconciles a Woo product.updated payload with the linked local product.
+// It returns the recorded decisions, none when the product isn't linked or nothing differs
+// (which is also what our own pushes echo back as). Name, prices and status follow the
+// conflict policy; a stock mismatch is always kept_local (see diffWooStock) and recorded as
+// its own decision so the caller can push our stock back.
+func (s *ProductService) ApplyWooProductUpdate(orgID uint, payload map[string]interface{}) ([]*models.ProductSyncDecision, error) {
+	wooID, _ := payload["id"].(float64)
+	if wooID == 0 {
+		return nil, nil
+	}
+
+	var decisions []*models.ProductSyncDecision
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		product, err := findProductByWooID(tx, orgID, int64(wooID))
+		if err != nil || product == nil {
+			return err
+		}
+
+		view, err := ResolveChannelView(tx, product, "woocommerce")
+		if err != nil {
+			return err
+		}
+		changes := diffWooProduct(*product, view, payload)
+		stockChange, stockDiffers, err := diffWooStock(tx, *product, payload)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 && !stockDiffers {
+			return nil
+		}
+
+		policy := wooConflictPolicy(tx, orgID)
+		remoteModified := parseWooTime(payload["date_modified_gmt"])
+		localUpdated := product.UpdatedAt
+		newDecision := func(decision string, changes map[string]fieldChange) *models.ProductSyncDecision {
+			changesJSON, _ := json.Marshal(changes)
+			return &models.ProductSyncDecision{
+				OrganizationID:   orgID,
+				ProductID:        product.ID,
+				Channel:          "woocommerce",
+				Topic:            "product.updated",
+				Policy:           policy,
+				Decision:         decision,
+				RemoteModifiedAt: remoteModified,
+				LocalUpdatedAt:   &localUpdated,
+				Changes:          datatypes.JSON(changesJSON),
+			}
+		}
+
+		if len(changes) > 0 {
+			applyRemote := false
+			switch policy {
+			case ConflictPolicyWooWins:
+				applyRemote = true
+			case ConflictPolicyNewestWins:
+				applyRemote = remoteModified == nil || remoteModified.After(localUpdated)
+			}
+
+			decision := newDecision(SyncDecisionKeptLocal, changes)
+			if applyRemote {
+				decision.Decision = SyncDecisionAppliedRemote
+				if err := applyWooChanges(tx, product, changes); err != nil {
+					return err
+				}
+			}
+			decisions = append(decisions, decision)
+		}
+		if stockDiffers {
+			decisions = append(decisions, newDecision(SyncDecisionKeptLocal, map[string]fieldChange{"stock_quantity": stockChange}))
+		}
+		for _, d := range decisions {
+			if err := tx.Create(d).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// IsStockDecision reports whether d records only a stock mismatch, which a stock push
+// resolves without republishing the whole product.
+func IsStockDecision(d *models.ProductSyncDecision) bool {
+	var changes map[string]fieldChange
+	if err := json.Unmarshal(d.Changes, &changes); err != nil {
+		return false
+	}
+	_, ok := changes["stock_quantity"]
+	return ok && len(changes) == 1
+}
+
+// ApplyWooProductDelete unlinks the local product from a product deleted (or trashed) in Woo:
+// ProductWoo is marked deleted, the Woo IDs are cleared and the channel is disabled.
+// The local product itself is kept.
+func (s *ProductService) ApplyWooProductDelete(orgID uint, payload map[string]interface{}) (*models.ProductSyncDecision, error) {
+	wooID, _ := payload["id"].(float64)
+	if wooID == 0 {
+		return nil, nil
+	}
+
+	var decision *models.ProductSyncDecision
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		product, err := findProductByWooID(tx, orgID, int64(wooID))
+		if err != nil || product == nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ProductWoo{}).Where("product_id = ?", product.ID).
+			Updates(map[string]interface{}{"status": "deleted", "woo_product_id": nil}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).
+			Update("woo_variation_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ProductChannel{}).
+			Where("product_id = ? AND channel_id IN (?)", product.ID,
+				tx.Model(&models.Channel{}).Select("id").Where("organization_id = ? AND name = ?", orgID, "woocommerce")).
+			Update("is_enabled", false).Error; err != nil {
+			return err
+		}
+
+		changesJSON, _ := json.Marshal(map[string]fieldChange{
+			"woo_product_id": {Local: int64(wooID), Remote: nil},
+		})
+		localUpdated := product.UpdatedAt
+		decision = &models.ProductSyncDecision{
+			OrganizationID: orgID,
+			ProductID:      product.ID,
+			Channel:        "woocommerce",
+			Topic:          "product.deleted",
+			Policy:         wooConflictPolicy(tx, orgID),
+			Decision:       SyncDecisionUnlinked,
+			LocalUpdatedAt: &localUpdated,
+			Changes:        datatypes.JSON(changesJSON),
+		}
+		return tx.Create(decision).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// findProductByWooID returns the org's product linked to a Woo product id, or nil when none is.
+func findProductByWooID(tx *gorm.DB, orgID uint, wooID int64) (*models.Product, error) {
+	var product models.Product
+	err := tx.Preload("ProductWoo").Preload("Variants").
+		Joins("JOIN product_woos ON product_woos.product_id = products.id AND product_woos.deleted_at IS NULL").
+		Where("products.organization_id = ? AND product_woos.woo_product_id = ?", orgID, wooID).
+		First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// diffWooProduct compares the fields we mirror from Woo: name, prices and status.
+// Prices are skipped for variable products since they live on the variations.
+// Name and prices are compared with what we publish (view); fields that a custom price or
+// channel override sets are skipped, since a remote edit there has no base field to land in.
+// Stock is compared separately by diffWooStock.
+func diffWooProduct(p models.Product, view ChannelView, payload map[string]interface{}) map[string]fieldChange {
+	changes := make(map[string]fieldChange)
+	fromBase := func(field string) bool { return view.Sources[field] == ViewSourceBase }
+
+	if name, ok := payload["name"].(string); ok && name != "" && name != view.Name && fromBase("name") {
+		changes["name"] = fieldChange{Local: p.Name, Remote: name}
+	}
+	if status, ok := payload["status"].(string); ok && status != "" && status != p.ProductWoo.Status &&
+		view.Sources["status"] != ViewSourceOverride {
+		changes["status"] = fieldChange{Local: p.ProductWoo.Status, Remote: status}
+	}
+
+	if len(p.Variants) > 0 {
+		return changes
+	}
+
+	if raw, ok := payload["regular_price"].(string); ok && raw != "" && fromBase("regular_price") {
+		if price, err := strconv.ParseFloat(raw, 64); err == nil && !samePrice(price, p.RegularPrice) {
+			changes["regular_price"] = fieldChange{Local: p.RegularPrice, Remote: price}
+		}
+	}
+	if raw, ok := payload["sale_price"].(string); ok && fromBase("sale_price") {
+		var remote *float64
+		if raw != "" {
+			if v, err := strconv.ParseFloat(raw, 64); err == nil {
+				remote = &v
+			}
+		}
+		switch {
+		case remote == nil && p.SalePrice != nil:
+			changes["sale_price"] = fieldChange{Local: *p.SalePrice, Remote: nil}
+		case remote != nil && (p.SalePrice == nil || !samePrice(*remote, *p.SalePrice)):
+			var local interface{}
+			if p.SalePrice != nil {
+				local = *p.SalePrice
+			}
+			changes["sale_price"] = fieldChange{Local: local, Remote: *remote}
+		}
+	}
+	return changes
+}
+
+// diffWooStock compares Woo's stock_quantity with the available-to-sell quantity we publish
+// for a simple, stock-managed product. Stock is never taken from Woo: it is ours, with Woo
+// orders deducted as they arrive, and the stock_quantity Woo sends right after such an order
+// would take the sale off twice. A mismatch is reported so our quantity can be pushed back.
+func diffWooStock(tx *gorm.DB, p models.Product, payload map[string]interface{}) (fieldChange, bool, error) {
+	remote, ok := payload["stock_quantity"].(float64)
+	if !ok || len(p.Variants) > 0 || !p.ManageStock {
+		return fieldChange{}, false, nil
+	}
+	held, err := reservedQty(tx, keyOf(p.ID, nil), "", time.Now())
+	if err != nil {
+		return fieldChange{}, false, err
+	}
+	local := availableQty(p.StockQuantity, held)
+	if int(remote) == local {
+		return fieldChange{}, false, nil
+	}
+	return fieldChange{Local: local, Remote: int(remote)}, true, nil
+}
+
+// applyWooChanges writes the remote values.
+func applyWooChanges(tx *gorm.DB, p *models.Product, changes map[string]fieldChange) error {
+	updates := make(map[string]interface{})
+	for field, ch := range changes {
+		switch field {
+		case "name", "regular_price", "sale_price":
+			updates[field] = ch.Remote
+		case "status":
+			if err := tx.Model(&models.ProductWoo{}).Where("product_id = ?", p.ID).
+				Update("status", ch.Remote).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", p.ID).Updates(updates).Error
+}
+
+// wooConflictPolicy reads conflict_policy from the org's woocommerce channel config.
+func wooConflictPolicy(tx *gorm.DB, orgID uint) string {
+	var channel models.Channel
+	if err := tx.Where("organization_id = ? AND name = ?", orgID, "woocommerce").First(&channel).Error; err != nil {
+		return DefaultConflictPolicy
+	}
+	var cfg struct {
+		ConflictPolicy string `json:"conflict_policy"`
+	}
+	if len(channel.Config) > 0 {
+		_ = json.Unmarshal(channel.Config, &cfg)
+	}
+	if !IsValidConflictPolicy(cfg.ConflictPolicy) {
+		return DefaultConflictPolicy
+	}
+	return cfg.ConflictPolicy
+}
+
+// parseWooTime parses Woo's *_gmt timestamps ("2006-01-02T15:04:05", no zone).
+func parseWooTime(v interface{}) *time.Time {
+	s, _ := v.(string)
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", s, time.UTC)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func samePrice(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
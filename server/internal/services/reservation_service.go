@@ -0,0 +1,384 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reservation statuses. Only "reserved" rows that haven't passed ExpiresAt hold stock.
+const (
+	ReservationReserved  = "reserved"
+	ReservationReleased  = "released"
+	ReservationCommitted = "committed"
+	ReservationExpired   = "expired"
+)
+
+// ErrReservationCommitted means the context was already committed and can't be re-reserved.
+var ErrReservationCommitted = errors.New("reservation already committed")
+
+// InsufficientStockError is returned when a reservation or commit asks for more than is
+// available to sell.
+type InsufficientStockError struct {
+	ProductID uint
+	VariantID *uint
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	if e.VariantID != nil {
+		return fmt.Sprintf("only %d of product %d variant %d available, %d requested", e.Available, e.ProductID, *e.VariantID, e.Requested)
+	}
+	return fmt.Sprintf("only %d of product %d available, %d requested", e.Available, e.ProductID, e.Requested)
+}
+
+// ReservationItem is a quantity of a product (or one of its variants) to hold.
+type ReservationItem struct {
+	ProductID uint
+	VariantID *uint
+	Qty       int
+}
+
+// stockKey identifies a stock line: a simple product (VariantID 0) or a variant.
+type stockKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+func keyOf(productID uint, variantID *uint) stockKey {
+	k := stockKey{ProductID: productID}
+	if variantID != nil {
+		k.VariantID = *variantID
+	}
+	return k
+}
+
+// less orders stock lines by product then variant, the order their rows are locked in.
+func (k stockKey) less(o stockKey) bool {
+	if k.ProductID != o.ProductID {
+		return k.ProductID < o.ProductID
+	}
+	return k.VariantID < o.VariantID
+}
+
+func (k stockKey) variantPtr() *uint {
+	if k.VariantID == 0 {
+		return nil
+	}
+	v := k.VariantID
+	return &v
+}
+
+type ReservationService struct {
+	db *gorm.DB
+}
+
+func NewReservationService(db *gorm.DB) *ReservationService {
+	return &ReservationService{db: db}
+}
+
+// Reserve holds items for source/contextID until expiresAt; see ReserveStock.
+func (s *ReservationService) Reserve(source, contextID string, items []ReservationItem, expiresAt time.Time) ([]StockChange, error) {
+	var changes []StockChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changes, err = ReserveStock(tx, source, contextID, items, expiresAt)
+		return err
+	})
+	return changes, err
+}
+
+// Commit deducts the context's held stock; see CommitReservations.
+func (s *ReservationService) Commit(source, contextID, reason, ref string, alloc StockAllocation) ([]StockChange, error) {
+	var changes []StockChange
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changes, err = CommitReservations(tx, source, contextID, reason, ref, alloc)
+		return err
+	})
+	return changes, err
+}
+
+// Release drops the context's holds; see ReleaseReservations.
+func (s *ReservationService) Release(source, contextID string) ([]StockChange, error) {
+	return ReleaseReservations(s.db, source, contextID)
+}
+
+// ReserveStock makes the holds for source/contextID exactly items, valid until expiresAt.
+// It is idempotent by context: calling it again replaces quantities and refreshes the
+// expiry, and lines no longer asked for are released. Every line is checked against
+// available-to-sell (on hand minus other contexts' holds) with the product row locked.
+// Products and variants that don't manage stock are not held.
+func ReserveStock(tx *gorm.DB, source, contextID string, items []ReservationItem, expiresAt time.Time) ([]StockChange, error) {
+	var existing []models.InventoryReservation
+	if err := tx.Where("source = ? AND context_id = ?", source, contextID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	rows := make(map[stockKey]*models.InventoryReservation, len(existing))
+	for i := range existing {
+		if existing[i].Status == ReservationCommitted {
+			return nil, ErrReservationCommitted
+		}
+		rows[keyOf(existing[i].ProductID, existing[i].VariantID)] = &existing[i]
+	}
+
+	wanted := make(map[stockKey]int)
+	var keys []stockKey
+	for _, it := range items {
+		if it.Qty <= 0 {
+			return nil, fmt.Errorf("reservation quantity must be positive, got %d", it.Qty)
+		}
+		k := keyOf(it.ProductID, it.VariantID)
+		if _, ok := wanted[k]; !ok {
+			keys = append(keys, k)
+		}
+		wanted[k] += it.Qty
+	}
+	// lock in a fixed order so concurrent reservations can't deadlock
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].VariantID < keys[j].VariantID
+	})
+
+	var changes []StockChange
+	for k, r := range rows {
+		if _, ok := wanted[k]; ok || r.Status != ReservationReserved {
+			continue
+		}
+		if err := tx.Model(r).Update("status", ReservationReleased).Error; err != nil {
+			return nil, err
+		}
+		changes = append(changes, StockChange{ProductID: k.ProductID, VariantID: k.variantPtr(), ChangeQty: r.ReservedQty})
+	}
+
+	for _, k := range keys {
+		qty := wanted[k]
+		onHand, managed, err := lockStockLine(tx, k)
+		if err != nil {
+			return nil, err
+		}
+		if !managed {
+			continue
+		}
+		held, err := reservedQty(tx, k, contextID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if available := onHand - held; available < qty {
+			return nil, &InsufficientStockError{ProductID: k.ProductID, VariantID: k.variantPtr(), Requested: qty, Available: max(available, 0)}
+		}
+
+		if r, ok := rows[k]; ok {
+			if err := tx.Model(r).Updates(map[string]interface{}{
+				"reserved_qty": qty,
+				"status":       ReservationReserved,
+				"expires_at":   expiresAt,
+			}).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			r := models.InventoryReservation{
+				ProductID:   k.ProductID,
+				VariantID:   k.variantPtr(),
+				Source:      source,
+				ContextID:   contextID,
+				ReservedQty: qty,
+				Status:      ReservationReserved,
+				ExpiresAt:   &expiresAt,
+			}
+			if err := tx.Create(&r).Error; err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, StockChange{ProductID: k.ProductID, VariantID: k.variantPtr(), ChangeQty: -qty})
+	}
+	return changes, nil
+}
+
+// CommitReservations turns the context's holds into sales: stock is deducted, an
+// InventoryMovement (reason, ref) is recorded per line and the holds are marked committed.
+// A hold that expired but wasn't swept yet still commits if the stock is there. alloc picks
+// the locations for lines stocked per location.
+func CommitReservations(tx *gorm.DB, source, contextID, reason, ref string, alloc StockAllocation) ([]StockChange, error) {
+	var held []models.InventoryReservation
+	if err := tx.Where("source = ? AND context_id = ? AND status IN ?", source, contextID,
+		[]string{ReservationReserved, ReservationExpired}).
+		Order("product_id ASC, variant_id ASC").
+		Find(&held).Error; err != nil {
+		return nil, err
+	}
+
+	var changes []StockChange
+	for i := range held {
+		r := &held[i]
+		k := keyOf(r.ProductID, r.VariantID)
+		onHand, _, err := lockStockLine(tx, k)
+		if err != nil {
+			return nil, err
+		}
+		others, err := reservedQty(tx, k, contextID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if available := onHand - others; available < r.ReservedQty {
+			return nil, &InsufficientStockError{ProductID: k.ProductID, VariantID: r.VariantID, Requested: r.ReservedQty, Available: max(available, 0)}
+		}
+
+		taken, err := takeLineStock(tx, k, r.ReservedQty, alloc, reason, ref)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Model(r).Update("status", ReservationCommitted).Error; err != nil {
+			return nil, err
+		}
+		changes = append(changes, StockChange{ProductID: r.ProductID, VariantID: r.VariantID, ChangeQty: -taken})
+	}
+	return changes, nil
+}
+
+// ReleaseReservations drops the context's live holds. Releasing twice is a no-op.
+func ReleaseReservations(tx *gorm.DB, source, contextID string) ([]StockChange, error) {
+	var released []models.InventoryReservation
+	err := tx.Model(&released).
+		Clauses(clause.Returning{}).
+		Where("source = ? AND context_id = ? AND status = ?", source, contextID, ReservationReserved).
+		Update("status", ReservationReleased).Error
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]StockChange, 0, len(released))
+	for _, r := range released {
+		changes = append(changes, StockChange{ProductID: r.ProductID, VariantID: r.VariantID, ChangeQty: r.ReservedQty})
+	}
+	return changes, nil
+}
+
+// ExpireReservations marks holds past their ExpiresAt as expired and returns the affected
+// stock lines grouped by organization.
+func (s *ReservationService) ExpireReservations(now time.Time) (map[uint][]StockChange, error) {
+	var expired []models.InventoryReservation
+	err := s.db.Model(&expired).
+		Clauses(clause.Returning{}).
+		Where("status = ? AND expires_at <= ?", ReservationReserved, now).
+		Update("status", ReservationExpired).Error
+	if err != nil || len(expired) == 0 {
+		return nil, err
+	}
+
+	productIDs := make([]uint, 0, len(expired))
+	for _, r := range expired {
+		productIDs = append(productIDs, r.ProductID)
+	}
+	var products []models.Product
+	if err := s.db.Unscoped().Select("id", "organization_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	orgOf := make(map[uint]uint, len(products))
+	for _, p := range products {
+		orgOf[p.ID] = p.OrganizationID
+	}
+
+	byOrg := make(map[uint][]StockChange)
+	for _, r := range expired {
+		orgID := orgOf[r.ProductID]
+		byOrg[orgID] = append(byOrg[orgID], StockChange{ProductID: r.ProductID, VariantID: r.VariantID, ChangeQty: r.ReservedQty})
+	}
+	return byOrg, nil
+}
+
+// AvailableToSell is on-hand stock less every live hold, for a product or one of its variants.
+func AvailableToSell(db *gorm.DB, productID uint, variantID *uint) (int, error) {
+	k := keyOf(productID, variantID)
+	var onHand int
+	if err := stockLineQuery(db, k).Select("stock_quantity").Scan(&onHand).Error; err != nil {
+		return 0, err
+	}
+	held, err := reservedQty(db, k, "", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return onHand - held, nil
+}
+
+// availableQty is what can be sold from onHand with held reserved, never below zero.
+func availableQty(onHand, held int) int {
+	if onHand-held < 0 {
+		return 0
+	}
+	return onHand - held
+}
+
+// reservedQuantities sums the live holds on the given products, per stock line.
+func reservedQuantities(db *gorm.DB, productIDs []uint) (map[stockKey]int, error) {
+	out := make(map[stockKey]int)
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		ProductID uint
+		VariantID *uint
+		Qty       int
+	}
+	err := db.Model(&models.InventoryReservation{}).
+		Select("product_id, variant_id, SUM(reserved_qty) AS qty").
+		Where("product_id IN ? AND status = ?", productIDs, ReservationReserved).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Group("product_id, variant_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[keyOf(r.ProductID, r.VariantID)] = r.Qty
+	}
+	return out, nil
+}
+
+// reservedQty sums live holds on one stock line, leaving out exceptContext's own.
+func reservedQty(tx *gorm.DB, k stockKey, exceptContext string, now time.Time) (int, error) {
+	query := tx.Model(&models.InventoryReservation{}).
+		Where("product_id = ? AND status = ? AND context_id <> ?", k.ProductID, ReservationReserved, exceptContext).
+		Where("expires_at IS NULL OR expires_at > ?", now)
+	if k.VariantID != 0 {
+		query = query.Where("variant_id = ?", k.VariantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+	var qty int
+	err := query.Select("COALESCE(SUM(reserved_qty), 0)").Scan(&qty).Error
+	return qty, err
+}
+
+// lockStockLine locks the product row (which serialises all of its variants too) and
+// returns the line's on-hand stock and whether it is managed.
+func lockStockLine(tx *gorm.DB, k stockKey) (int, bool, error) {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "stock_quantity", "manage_stock").First(&product, k.ProductID).Error; err != nil {
+		return 0, false, err
+	}
+	if k.VariantID == 0 {
+		return product.StockQuantity, product.ManageStock, nil
+	}
+	var variant models.ProductVariant
+	if err := tx.Select("id", "stock_quantity", "manage_stock").
+		Where("product_id = ?", k.ProductID).First(&variant, k.VariantID).Error; err != nil {
+		return 0, false, err
+	}
+	return variant.StockQuantity, variant.ManageStock, nil
+}
+
+func stockLineQuery(tx *gorm.DB, k stockKey) *gorm.DB {
+	if k.VariantID != 0 {
+		return tx.Model(&models.ProductVariant{}).Where("id = ?", k.VariantID)
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", k.ProductID)
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Stock adjustment statuses: pending_approval -> applied or rejected. An adjustment that
+// needs no approval is applied as it is created.
+const (
+	AdjustmentPending  = "pending_approval"
+	AdjustmentApplied  = "applied"
+	AdjustmentRejected = "rejected"
+)
+
+// Adjustment reason codes. Damage and theft only lower stock, found only raises it, a
+// count correction goes either way. Movements are recorded as adjust_<reason>.
+const (
+	AdjustReasonDamage          = "damage"
+	AdjustReasonTheft           = "theft"
+	AdjustReasonCountCorrection = "count_correction"
+	AdjustReasonFound           = "found"
+)
+
+// Adjustment line modes: set the stock to Quantity, or move it by Quantity.
+const (
+	AdjustModeSet   = "set"
+	AdjustModeDelta = "delta"
+)
+
+// ErrAdjustmentStatus means the adjustment can't take this step from its current status.
+var ErrAdjustmentStatus = errors.New("adjustment status does not allow this")
+
+// IsValidAdjustmentReason reports whether r is a known adjustment reason code.
+func IsValidAdjustmentReason(r string) bool {
+	switch r {
+	case AdjustReasonDamage, AdjustReasonTheft, AdjustReasonCountCorrection, AdjustReasonFound:
+		return true
+	}
+	return false
+}
+
+// AdjustmentLineError is an adjustment line that can't be applied to the stock as it is
+// now: it would go negative, or move against its reason.
+type AdjustmentLineError struct {
+	ProductID  uint
+	VariantID  *uint
+	LocationID *uint
+	Msg        string
+}
+
+func (e *AdjustmentLineError) Error() string {
+	if e.VariantID != nil {
+		return fmt.Sprintf("product %d variant %d: %s", e.ProductID, *e.VariantID, e.Msg)
+	}
+	return fmt.Sprintf("product %d: %s", e.ProductID, e.Msg)
+}
+
+// AdjustmentRef is the InventoryMovement ref of an adjustment's movements.
+func AdjustmentRef(adjustmentID uint) string {
+	return fmt.Sprintf("stock_adjustment:%d", adjustmentID)
+}
+
+// PlanAdjustment works out what each line (by index) would move its stock by, without
+// changing anything. It locks the lines' stock rows, so applying in the same tx moves
+// exactly this.
+func PlanAdjustment(tx *gorm.DB, orgID uint, lines []models.StockAdjustmentLine) ([]int, error) {
+	changes := make([]int, len(lines))
+	for _, i := range adjustmentLockOrder(lines) {
+		l := lines[i]
+		k := keyOf(l.ProductID, l.VariantID)
+		onHand, _, err := lockAdjustmentLine(tx, l, k)
+		if err != nil {
+			return nil, err
+		}
+		current, err := adjustmentCurrent(tx, orgID, k, onHand, l.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		if changes[i], err = adjustmentChange(l, current); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// AdjustmentNeedsApproval reports whether any planned change exceeds threshold units; a
+// nil threshold never needs approval.
+func AdjustmentNeedsApproval(changes []int, threshold *int) bool {
+	if threshold == nil {
+		return false
+	}
+	for _, c := range changes {
+		if c > *threshold || -c > *threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyAdjustment applies a pending adjustment's lines inside tx, which should hold the
+// adjustment row's lock. A set line is measured against the stock now, not when it was
+// requested. Movements are recorded against the requester; reviewerID is the approving
+// admin, nil when no approval was needed.
+func ApplyAdjustment(tx *gorm.DB, a *models.StockAdjustment, reviewerID *uint, now time.Time) ([]StockChange, error) {
+	if a.Status != AdjustmentPending {
+		return nil, fmt.Errorf("%w: %s adjustment can't be applied", ErrAdjustmentStatus, a.Status)
+	}
+	if a.RequestedBy != nil {
+		tx = WithActor(tx, *a.RequestedBy)
+	}
+	ref := AdjustmentRef(a.ID)
+	var changes []StockChange
+	for _, i := range adjustmentLockOrder(a.Lines) {
+		l := &a.Lines[i]
+		k := keyOf(l.ProductID, l.VariantID)
+		onHand, _, err := lockAdjustmentLine(tx, *l, k)
+		if err != nil {
+			return nil, err
+		}
+		current, err := adjustmentCurrent(tx, a.OrganizationID, k, onHand, l.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		change, err := adjustmentChange(*l, current)
+		if err != nil {
+			return nil, err
+		}
+
+		reason := "adjust_" + l.Reason
+		if change != 0 && l.LocationID == nil {
+			if change, err = SetStockTotal(tx, l.ProductID, l.VariantID, onHand+change, reason, ref); err != nil {
+				return nil, err
+			}
+		} else if change != 0 {
+			row, err := locationRow(tx, a.OrganizationID, k, onHand, *l.LocationID)
+			if err != nil {
+				return nil, err
+			}
+			if err := shiftLocationStock(tx, k, row, change, reason, ref); err != nil {
+				return nil, err
+			}
+			if err := syncLineTotal(tx, k); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Model(&models.StockAdjustmentLine{}).Where("id = ?", l.ID).Update("change_qty", change).Error; err != nil {
+			return nil, err
+		}
+		l.ChangeQty = &change
+		if change != 0 {
+			changes = append(changes, StockChange{ProductID: l.ProductID, VariantID: l.VariantID, ChangeQty: change})
+		}
+	}
+
+	updates := map[string]interface{}{"status": AdjustmentApplied, "applied_at": now}
+	a.Status, a.AppliedAt = AdjustmentApplied, &now
+	if reviewerID != nil {
+		updates["reviewed_by"], updates["reviewed_at"] = reviewerID, now
+		a.ReviewedBy, a.ReviewedAt = reviewerID, &now
+	}
+	return changes, tx.Model(&models.StockAdjustment{}).Where("id = ?", a.ID).Updates(updates).Error
+}
+
+// RejectAdjustment turns down a pending adjustment inside tx; no stock moves.
+func RejectAdjustment(tx *gorm.DB, a *models.StockAdjustment, reviewerID *uint, note string, now time.Time) error {
+	if a.Status != AdjustmentPending {
+		return fmt.Errorf("%w: %s adjustment can't be rejected", ErrAdjustmentStatus, a.Status)
+	}
+	a.Status, a.ReviewedBy, a.ReviewedAt, a.ReviewNote = AdjustmentRejected, reviewerID, &now, note
+	return tx.Model(&models.StockAdjustment{}).Where("id = ?", a.ID).
+		Updates(map[string]interface{}{"status": a.Status, "reviewed_by": reviewerID, "reviewed_at": now, "review_note": note}).Error
+}
+
+// lockAdjustmentLine locks a line's stock row, reporting a product or variant deleted
+// since the adjustment was requested as a line error.
+func lockAdjustmentLine(tx *gorm.DB, l models.StockAdjustmentLine, k stockKey) (int, bool, error) {
+	onHand, managed, err := lockStockLine(tx, k)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, &AdjustmentLineError{ProductID: l.ProductID, VariantID: l.VariantID, LocationID: l.LocationID, Msg: "product no longer exists"}
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+	}
+	return onHand, managed, nil
+}
+
+// adjustmentCurrent is the stock a line is adjusted from: the line's on-hand, or what the
+// location holds of it. A line not yet stocked per location counts its on-hand as held
+// where locationRow would put it.
+func adjustmentCurrent(tx *gorm.DB, orgID uint, k stockKey, onHand int, locationID *uint) (int, error) {
+	if locationID == nil {
+		return onHand, nil
+	}
+	rows, err := locationLines(tx, k)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range rows {
+		if r.LocationID == *locationID {
+			return r.StockQty, nil
+		}
+	}
+	if len(rows) > 0 {
+		return 0, nil
+	}
+	var def models.SellerLocation
+	err = tx.Select("id").Where("organization_id = ? AND is_default", orgID).First(&def).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return onHand, nil
+	case err != nil:
+		return 0, err
+	case def.ID == *locationID:
+		return onHand, nil
+	}
+	return 0, nil
+}
+
+// adjustmentChange is what a line moves stock by from current, checked against its reason.
+func adjustmentChange(l models.StockAdjustmentLine, current int) (int, error) {
+	target := l.Quantity
+	if l.Mode == AdjustModeDelta {
+		target = current + l.Quantity
+	}
+	lineErr := func(msg string) error {
+		return &AdjustmentLineError{ProductID: l.ProductID, VariantID: l.VariantID, LocationID: l.LocationID, Msg: msg}
+	}
+	change := target - current
+	switch {
+	case target < 0:
+		return 0, lineErr(fmt.Sprintf("only %d in stock, can't remove %d", current, -change))
+	case change > 0 && (l.Reason == AdjustReasonDamage || l.Reason == AdjustReasonTheft):
+		return 0, lineErr(fmt.Sprintf("%s can't raise stock (%d now, %d asked)", l.Reason, current, target))
+	case change < 0 && l.Reason == AdjustReasonFound:
+		return 0, lineErr(fmt.Sprintf("found can't lower stock (%d now, %d asked)", current, target))
+	}
+	return change, nil
+}
+
+// adjustmentLockOrder returns the lines' indexes in the order their stock rows are locked.
+func adjustmentLockOrder(lines []models.StockAdjustmentLine) []int {
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		a, b := lines[order[x]], lines[order[y]]
+		return keyOf(a.ProductID, a.VariantID).less(keyOf(b.ProductID, b.VariantID))
+	})
+	return order
+}
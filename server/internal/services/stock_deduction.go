@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Oversell policies: what an incoming order does when it asks for more than is available
+// to sell. Set per channel as config.oversell_policy.
+const (
+	OversellReject        = "reject"         // deduct nothing for the order and flag it
+	OversellBackorder     = "backorder"      // deduct what's there, flag the rest as still owed
+	OversellClampAndFlag  = "clamp_and_flag" // deduct what's there and flag the order for review
+	DefaultOversellPolicy = OversellClampAndFlag
+)
+
+// Order flag reasons and statuses.
+const (
+	FlagOversellRejected = "oversell_rejected"
+	FlagOversellClamped  = "oversell_clamped"
+	FlagBackordered      = "backordered"
+	FlagDuplicateOrder   = "duplicate_order" // set by the migration that merged duplicate channel orders
+
+	FlagStatusOpen     = "open"
+	FlagStatusResolved = "resolved"
+)
+
+// IsValidOversellPolicy reports whether p is a known oversell policy.
+func IsValidOversellPolicy(p string) bool {
+	switch p {
+	case OversellReject, OversellBackorder, OversellClampAndFlag:
+		return true
+	}
+	return false
+}
+
+// OrderStockLine is a quantity an order takes from a product or one of its variants.
+type OrderStockLine struct {
+	ProductID uint
+	VariantID *uint
+	SKU       string
+	Qty       int
+}
+
+// oversoldLine is one line of an OrderFlag's details.
+type oversoldLine struct {
+	ProductID uint   `json:"product_id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Requested int    `json:"requested"`
+	OnHand    int    `json:"on_hand"`
+	Held      int    `json:"held"` // reserved for in-flight orders of other channels
+	Deducted  int    `json:"deducted"`
+	Short     int    `json:"short"`
+	Owed      int    `json:"owed,omitempty"` // backorder: units still to ship
+}
+
+// DeductOrderStock takes an order's lines out of stock inside tx. Every line's product row
+// is locked (in id order, so concurrent orders can't deadlock) before on-hand is read, so
+// two orders can't both sell the last unit and stock never goes below zero. A line can
+// only take what is available to sell: stock held for another in-flight order (an ONDC
+// cart) stays put. When a line asks for more, policy decides (see Oversell*) and the order
+// is flagged.
+// Each deduction is recorded as an InventoryMovement (reason, ref). Lines stocked per
+// location are taken from the locations the channel's location rule picks.
+func DeductOrderStock(tx *gorm.DB, order *models.Order, lines []OrderStockLine, policy, reason, ref string) ([]StockChange, *models.OrderFlag, error) {
+	if !IsValidOversellPolicy(policy) {
+		policy = DefaultOversellPolicy
+	}
+	alloc := orderStockAllocation(tx, order)
+
+	merged, keys := mergeStockLines(lines)
+
+	type plan struct {
+		key    stockKey
+		line   *OrderStockLine
+		onHand int
+		take   int
+	}
+	var plans []plan
+	var short []oversoldLine
+	for _, k := range keys {
+		l := merged[k]
+		onHand, managed, err := lockStockLine(tx, k)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // deleted since the line was resolved; nothing left to take
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+		}
+		if !managed {
+			continue
+		}
+		held, err := reservedQty(tx, k, "", time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		take := l.Qty
+		if available := onHand - held; available < take {
+			take = max(available, 0)
+			short = append(short, oversoldLine{
+				ProductID: k.ProductID, VariantID: k.variantPtr(), SKU: l.SKU,
+				Requested: l.Qty, OnHand: onHand, Held: held, Short: l.Qty - take,
+			})
+		}
+		plans = append(plans, plan{key: k, line: l, onHand: onHand, take: take})
+	}
+
+	var changes []StockChange
+	if len(short) == 0 || policy != OversellReject {
+		for _, p := range plans {
+			if p.take == 0 {
+				continue
+			}
+			taken, err := takeLineStock(tx, p.key, p.take, alloc, reason, ref)
+			if err != nil {
+				return nil, nil, fmt.Errorf("deduct stock for product %d: %w", p.key.ProductID, err)
+			}
+			p.take = taken
+			changes = append(changes, StockChange{ProductID: p.key.ProductID, VariantID: p.key.variantPtr(), ChangeQty: -p.take})
+			for i := range short {
+				if keyOf(short[i].ProductID, short[i].VariantID) == p.key {
+					short[i].Deducted = p.take
+				}
+			}
+		}
+	}
+	if len(short) == 0 {
+		return changes, nil, nil
+	}
+
+	flagReason := FlagOversellClamped
+	owed := 0
+	switch policy {
+	case OversellReject:
+		flagReason = FlagOversellRejected
+	case OversellBackorder:
+		flagReason = FlagBackordered
+		for i := range short {
+			short[i].Owed = short[i].Requested - short[i].Deducted
+			owed += short[i].Owed
+		}
+	}
+	details, _ := json.Marshal(map[string]interface{}{"lines": short})
+	flag := &models.OrderFlag{
+		OrganizationID: order.OrganizationID,
+		OrderID:        order.ID,
+		Reason:         flagReason,
+		Policy:         policy,
+		Details:        datatypes.JSON(details),
+		OwedQty:        owed,
+		Status:         FlagStatusOpen,
+	}
+	if err := tx.Create(flag).Error; err != nil {
+		return nil, nil, fmt.Errorf("flag order: %w", err)
+	}
+	return changes, flag, nil
+}
+
+// mergeStockLines sums the lines' positive quantities per product/variant and returns the
+// keys in lock order (product id, then variant id).
+func mergeStockLines(lines []OrderStockLine) (map[stockKey]*OrderStockLine, []stockKey) {
+	merged := make(map[stockKey]*OrderStockLine)
+	var keys []stockKey
+	for _, l := range lines {
+		if l.Qty <= 0 {
+			continue
+		}
+		k := keyOf(l.ProductID, l.VariantID)
+		if m, ok := merged[k]; ok {
+			m.Qty += l.Qty
+			continue
+		}
+		l := l
+		merged[k] = &l
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].VariantID < keys[j].VariantID
+	})
+	return merged, keys
+}
+
+// channelOversellPolicy reads oversell_policy from the org's channel config.
+func channelOversellPolicy(tx *gorm.DB, orgID uint, channelName string) string {
+	var channel models.Channel
+	if err := tx.Where("organization_id = ? AND name = ?", orgID, channelName).First(&channel).Error; err != nil {
+		return DefaultOversellPolicy
+	}
+	var cfg struct {
+		OversellPolicy string `json:"oversell_policy"`
+	}
+	if len(channel.Config) > 0 {
+		_ = json.Unmarshal(channel.Config, &cfg)
+	}
+	if !IsValidOversellPolicy(cfg.OversellPolicy) {
+		return DefaultOversellPolicy
+	}
+	return cfg.OversellPolicy
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ledger movement reasons that aren't tied to an order, adjustment or transfer. Every
+// stock line's ledger starts with one opening movement (its stock when created, or when
+// the ledger was backfilled); a location_seed pair moves a line's on-hand into its first
+// location row.
+const (
+	MovementOpening      = "opening"
+	MovementLocationSeed = "location_seed"
+)
+
+// RecordOpeningStock starts a new product's (or variant's) ledger with its initial stock.
+func RecordOpeningStock(tx *gorm.DB, productID uint, variantID *uint, qty int, ref string) error {
+	return recordMovement(tx, keyOf(productID, variantID), nil, qty, MovementOpening, ref)
+}
+
+// BackfillOpeningBalances gives every live stock line without an opening movement one
+// for the difference between its stock and its movements so far, so the ledger adds up
+// to today's stock. Safe to run on every start; it only touches lines that have none.
+func BackfillOpeningBalances(db *gorm.DB) (int64, error) {
+	products := db.Exec(`
+		INSERT INTO inventory_movements (product_id, variant_id, change_qty, reason, ref, created_at)
+		SELECT p.id, NULL, p.stock_quantity - COALESCE(m.total, 0), ?, 'ledger_backfill', NOW()
+		FROM products p
+		LEFT JOIN (
+		  SELECT product_id, SUM(change_qty) AS total FROM inventory_movements
+		  WHERE variant_id IS NULL AND reason <> ? GROUP BY product_id
+		) m ON m.product_id = p.id
+		WHERE p.deleted_at IS NULL
+		  AND NOT EXISTS (
+		    SELECT 1 FROM inventory_movements o
+		    WHERE o.product_id = p.id AND o.variant_id IS NULL AND o.reason = ?
+		  )`, MovementOpening, MovementTransferTransit, MovementOpening)
+	if products.Error != nil {
+		return 0, products.Error
+	}
+	variants := db.Exec(`
+		INSERT INTO inventory_movements (product_id, variant_id, change_qty, reason, ref, created_at)
+		SELECT v.product_id, v.id, v.stock_quantity - COALESCE(m.total, 0), ?, 'ledger_backfill', NOW()
+		FROM product_variants v
+		LEFT JOIN (
+		  SELECT variant_id, SUM(change_qty) AS total FROM inventory_movements
+		  WHERE variant_id IS NOT NULL AND reason <> ? GROUP BY variant_id
+		) m ON m.variant_id = v.id
+		WHERE v.deleted_at IS NULL
+		  AND NOT EXISTS (
+		    SELECT 1 FROM inventory_movements o
+		    WHERE o.variant_id = v.id AND o.reason = ?
+		  )`, MovementOpening, MovementTransferTransit, MovementOpening)
+	return products.RowsAffected + variants.RowsAffected, variants.Error
+}
+
+// StockDrift is a stock line whose on-hand doesn't match what its ledger adds up to.
+type StockDrift struct {
+	OrganizationID uint
+	ProductID      uint
+	VariantID      *uint
+	Stock          int // stock_quantity
+	Ledger         int
+	Drift          int // Stock - Ledger
+	Repaired       bool
+}
+
+// FindStockDrift compares every live stock line of the org (every org for orgID 0) with
+// its ledger: all its movements but transit ones, since stock on the road is in no
+// location's on-hand.
+func FindStockDrift(db *gorm.DB, orgID uint) ([]StockDrift, error) {
+	var drift []StockDrift
+	err := db.Raw(`
+		SELECT organization_id, product_id, variant_id, stock, ledger, stock - ledger AS drift
+		FROM (
+		  SELECT p.organization_id, p.id AS product_id, NULL::bigint AS variant_id,
+		         p.stock_quantity AS stock, COALESCE(m.total, 0) AS ledger
+		  FROM products p
+		  LEFT JOIN (
+		    SELECT product_id, SUM(change_qty) AS total FROM inventory_movements
+		    WHERE variant_id IS NULL AND reason <> @transit GROUP BY product_id
+		  ) m ON m.product_id = p.id
+		  WHERE p.deleted_at IS NULL
+
+		  UNION ALL
+
+		  SELECT p.organization_id, v.product_id, v.id,
+		         v.stock_quantity, COALESCE(m.total, 0)
+		  FROM product_variants v
+		  JOIN products p ON p.id = v.product_id AND p.deleted_at IS NULL
+		  LEFT JOIN (
+		    SELECT variant_id, SUM(change_qty) AS total FROM inventory_movements
+		    WHERE variant_id IS NOT NULL AND reason <> @transit GROUP BY variant_id
+		  ) m ON m.variant_id = v.id
+		  WHERE v.deleted_at IS NULL
+		) lines
+		WHERE stock <> ledger AND (@org = 0 OR organization_id = @org)
+		ORDER BY organization_id, product_id, variant_id NULLS FIRST`,
+		map[string]interface{}{"transit": MovementTransferTransit, "org": orgID}).
+		Scan(&drift).Error
+	return drift, err
+}
+
+// ReconcileStock finds the drift of the org (every org for orgID 0) and, with repair,
+// sets each drifting line's on-hand back to what its ledger says, returning the stock
+// changes per org. No movement is recorded for a repair: the ledger is already right.
+// A negative ledger is left alone.
+func ReconcileStock(db *gorm.DB, orgID uint, repair bool) ([]StockDrift, map[uint][]StockChange, error) {
+	drift, err := FindStockDrift(db, orgID)
+	if err != nil || !repair {
+		return drift, nil, err
+	}
+	byOrg := make(map[uint][]StockChange)
+	for i := range drift {
+		d := &drift[i]
+		change, err := repairStockDrift(db, d)
+		if err != nil {
+			return drift, byOrg, fmt.Errorf("repair product %d: %w", d.ProductID, err)
+		}
+		if change != 0 {
+			byOrg[d.OrganizationID] = append(byOrg[d.OrganizationID], StockChange{ProductID: d.ProductID, VariantID: d.VariantID, ChangeQty: change})
+		}
+	}
+	return drift, byOrg, nil
+}
+
+// repairStockDrift sets one drifting line's on-hand to its ledger in its own transaction,
+// measured again under the line's lock, and returns the change. For a line stocked per
+// location the difference lands on (or comes off) its locations as the default rule
+// would pick them.
+func repairStockDrift(db *gorm.DB, d *StockDrift) (int, error) {
+	change := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		k := keyOf(d.ProductID, d.VariantID)
+		onHand, _, err := lockStockLine(tx, k)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // deleted since
+		}
+		if err != nil {
+			return err
+		}
+		ledger, err := ledgerTotal(tx, k)
+		if err != nil || ledger < 0 {
+			return err
+		}
+		if ledger == onHand {
+			d.Repaired = true
+			return nil
+		}
+
+		rows, err := locationLines(tx, k)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			if err := stockLineQuery(tx, k).UpdateColumn("stock_quantity", ledger).Error; err != nil {
+				return err
+			}
+			change, d.Repaired = ledger-onHand, true
+			return nil
+		}
+
+		shift := make([]int, len(rows))
+		if delta := ledger - onHand; delta > 0 {
+			shift[rankLocations(rows, 0, StockAllocation{Rule: LocationRuleDefault})[0]] = delta
+		} else {
+			for i, n := range planLocationTake(rows, -delta, StockAllocation{Rule: LocationRuleDefault}) {
+				shift[i] = -n
+			}
+		}
+		for i, n := range shift {
+			if n == 0 {
+				continue
+			}
+			if err := tx.Model(&models.ProductLocationStock{}).Where("id = ?", rows[i].ID).
+				UpdateColumn("stock_qty", gorm.Expr("stock_qty + ?", n)).Error; err != nil {
+				return err
+			}
+		}
+		if err := syncLineTotal(tx, k); err != nil {
+			return err
+		}
+		after, _, err := lockStockLine(tx, k)
+		if err != nil {
+			return err
+		}
+		change, d.Repaired = after-onHand, after == ledger
+		return nil
+	})
+	return change, err
+}
+
+// ledgerTotal adds up a line's movements, transit ones aside.
+func ledgerTotal(tx *gorm.DB, k stockKey) (int, error) {
+	var total int
+	err := tx.Model(&models.InventoryMovement{}).
+		Where("product_id = ? AND COALESCE(variant_id, 0) = ? AND reason <> ?", k.ProductID, k.VariantID, MovementTransferTransit).
+		Select("COALESCE(SUM(change_qty), 0)").
+		Scan(&total).Error
+	return total, err
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Stock transfer statuses: draft -> dispatched -> received, or cancelled from either of
+// the first two.
+const (
+	TransferDraft      = "draft"
+	TransferDispatched = "dispatched"
+	TransferReceived   = "received"
+	TransferCancelled  = "cancelled"
+)
+
+// Movement reasons of a transfer, all under ref stock_transfer:<id>. Dispatch pairs
+// transfer_out (source location, -) with transfer_transit (no location, +); receipt pairs
+// transfer_transit (-) with transfer_in (destination, +); cancelling a dispatched transfer
+// pairs transfer_transit (-) with transfer_return (source, +). Transit movements are stock
+// on the road and count towards no location's on-hand.
+const (
+	MovementTransferOut     = "transfer_out"
+	MovementTransferTransit = "transfer_transit"
+	MovementTransferIn      = "transfer_in"
+	MovementTransferReturn  = "transfer_return"
+)
+
+var (
+	// ErrTransferStatus means the transfer can't take this step from its current status.
+	ErrTransferStatus = errors.New("transfer status does not allow this")
+	// ErrTransferUnmanaged means a line's product (or variant) doesn't track stock.
+	ErrTransferUnmanaged = errors.New("product does not track stock")
+)
+
+// TransferShortError is returned when the source location holds less than a line sends.
+type TransferShortError struct {
+	ProductID uint
+	VariantID *uint
+	Requested int
+	Available int
+}
+
+func (e *TransferShortError) Error() string {
+	if e.VariantID != nil {
+		return fmt.Sprintf("source location holds only %d of product %d variant %d, %d requested", e.Available, e.ProductID, *e.VariantID, e.Requested)
+	}
+	return fmt.Sprintf("source location holds only %d of product %d, %d requested", e.Available, e.ProductID, e.Requested)
+}
+
+// TransferRef is the InventoryMovement ref of a transfer's movements.
+func TransferRef(transferID uint) string {
+	return fmt.Sprintf("stock_transfer:%d", transferID)
+}
+
+// DispatchTransfer takes a draft transfer's lines out of its source location inside tx,
+// which should hold the transfer row's lock. Every line must be wholly at the source; the
+// lines' on-hand drops by what leaves, so stock in transit can't be sold.
+func DispatchTransfer(tx *gorm.DB, t *models.StockTransfer, userID *uint, now time.Time) ([]StockChange, error) {
+	if t.Status != TransferDraft {
+		return nil, fmt.Errorf("%w: %s transfer can't be dispatched", ErrTransferStatus, t.Status)
+	}
+	ref := TransferRef(t.ID)
+	var changes []StockChange
+	for _, l := range transferLinesInLockOrder(t.Lines) {
+		k := keyOf(l.ProductID, l.VariantID)
+		onHand, managed, err := lockStockLine(tx, k)
+		if err != nil {
+			return nil, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+		}
+		if !managed {
+			return nil, fmt.Errorf("%w: product %d", ErrTransferUnmanaged, k.ProductID)
+		}
+		row, err := locationRow(tx, t.OrganizationID, k, onHand, t.FromLocationID)
+		if err != nil {
+			return nil, err
+		}
+		if row.StockQty < l.Quantity {
+			return nil, &TransferShortError{ProductID: l.ProductID, VariantID: l.VariantID, Requested: l.Quantity, Available: row.StockQty}
+		}
+		if err := shiftLocationStock(tx, k, row, -l.Quantity, MovementTransferOut, ref); err != nil {
+			return nil, err
+		}
+		if err := recordMovement(tx, k, nil, l.Quantity, MovementTransferTransit, ref); err != nil {
+			return nil, err
+		}
+		if err := syncLineTotal(tx, k); err != nil {
+			return nil, err
+		}
+		changes = append(changes, StockChange{ProductID: l.ProductID, VariantID: l.VariantID, ChangeQty: -l.Quantity})
+	}
+
+	t.Status, t.DispatchedAt, t.DispatchedBy = TransferDispatched, &now, userID
+	return changes, tx.Model(&models.StockTransfer{}).Where("id = ?", t.ID).
+		Updates(map[string]interface{}{"status": t.Status, "dispatched_at": now, "dispatched_by": userID}).Error
+}
+
+// ReceiveTransfer lands a dispatched transfer at its destination inside tx, which should
+// hold the transfer row's lock. received gives what arrived per line ID; lines not in it
+// arrived in full. A short line's missing units are written off with the transit; an over
+// line's extra units land too. Either way ReceivedQty records the count.
+func ReceiveTransfer(tx *gorm.DB, t *models.StockTransfer, received map[uint]int, userID *uint, now time.Time) ([]StockChange, error) {
+	if t.Status != TransferDispatched {
+		return nil, fmt.Errorf("%w: %s transfer can't be received", ErrTransferStatus, t.Status)
+	}
+	ref := TransferRef(t.ID)
+	var changes []StockChange
+	landed := make(map[uint]int, len(t.Lines))
+	for _, l := range transferLinesInLockOrder(t.Lines) {
+		got := l.Quantity
+		if q, ok := received[l.ID]; ok {
+			got = q
+		}
+		k := keyOf(l.ProductID, l.VariantID)
+		if err := recordMovement(tx, k, nil, -l.Quantity, MovementTransferTransit, ref); err != nil {
+			return nil, err
+		}
+		if got > 0 {
+			onHand, _, err := lockStockLine(tx, k)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				got = 0 // deleted while on the road; nothing to put it on
+			} else if err != nil {
+				return nil, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+			} else {
+				row, err := locationRow(tx, t.OrganizationID, k, onHand, t.ToLocationID)
+				if err != nil {
+					return nil, err
+				}
+				if err := shiftLocationStock(tx, k, row, got, MovementTransferIn, ref); err != nil {
+					return nil, err
+				}
+				if err := syncLineTotal(tx, k); err != nil {
+					return nil, err
+				}
+				changes = append(changes, StockChange{ProductID: l.ProductID, VariantID: l.VariantID, ChangeQty: got})
+			}
+		}
+		if err := tx.Model(&models.StockTransferLine{}).Where("id = ?", l.ID).Update("received_qty", got).Error; err != nil {
+			return nil, err
+		}
+		landed[l.ID] = got
+	}
+	for i := range t.Lines {
+		got := landed[t.Lines[i].ID]
+		t.Lines[i].ReceivedQty = &got
+	}
+
+	t.Status, t.ReceivedAt, t.ReceivedBy = TransferReceived, &now, userID
+	return changes, tx.Model(&models.StockTransfer{}).Where("id = ?", t.ID).
+		Updates(map[string]interface{}{"status": t.Status, "received_at": now, "received_by": userID}).Error
+}
+
+// CancelTransfer cancels a draft or dispatched transfer inside tx, which should hold the
+// transfer row's lock. A dispatched transfer's lines go back to the source location.
+func CancelTransfer(tx *gorm.DB, t *models.StockTransfer, now time.Time) ([]StockChange, error) {
+	if t.Status != TransferDraft && t.Status != TransferDispatched {
+		return nil, fmt.Errorf("%w: %s transfer can't be cancelled", ErrTransferStatus, t.Status)
+	}
+	var changes []StockChange
+	if t.Status == TransferDispatched {
+		ref := TransferRef(t.ID)
+		for _, l := range transferLinesInLockOrder(t.Lines) {
+			k := keyOf(l.ProductID, l.VariantID)
+			if err := recordMovement(tx, k, nil, -l.Quantity, MovementTransferTransit, ref); err != nil {
+				return nil, err
+			}
+			onHand, _, err := lockStockLine(tx, k)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("lock stock for product %d: %w", k.ProductID, err)
+			}
+			row, err := locationRow(tx, t.OrganizationID, k, onHand, t.FromLocationID)
+			if err != nil {
+				return nil, err
+			}
+			if err := shiftLocationStock(tx, k, row, l.Quantity, MovementTransferReturn, ref); err != nil {
+				return nil, err
+			}
+			if err := syncLineTotal(tx, k); err != nil {
+				return nil, err
+			}
+			changes = append(changes, StockChange{ProductID: l.ProductID, VariantID: l.VariantID, ChangeQty: l.Quantity})
+		}
+	}
+
+	t.Status, t.CancelledAt = TransferCancelled, &now
+	return changes, tx.Model(&models.StockTransfer{}).Where("id = ?", t.ID).
+		Updates(map[string]interface{}{"status": t.Status, "cancelled_at": now}).Error
+}
+
+// transferLinesInLockOrder sorts lines by product then variant, the order stock rows are
+// locked in everywhere else.
+func transferLinesInLockOrder(lines []models.StockTransferLine) []models.StockTransferLine {
+	sorted := append([]models.StockTransferLine(nil), lines...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return keyOf(sorted[i].ProductID, sorted[i].VariantID).less(keyOf(sorted[j].ProductID, sorted[j].VariantID))
+	})
+	return sorted
+}
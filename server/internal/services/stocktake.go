@@ -0,0 +1,394 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/gorm"
+)
+
+// Stocktake statuses: counting -> approved, or cancelled.
+const (
+	StocktakeCounting  = "counting"
+	StocktakeApproved  = "approved"
+	StocktakeCancelled = "cancelled"
+)
+
+var (
+	// ErrStocktakeStatus means the stocktake can't take this step from its current status.
+	ErrStocktakeStatus = errors.New("stocktake status does not allow this")
+	// ErrStocktakeEmpty means the stocktake's scope holds no stock-tracked products.
+	ErrStocktakeEmpty = errors.New("no stock-tracked products to count")
+	// ErrStocktakeNegativeCount means a count would take a line's counted quantity below zero.
+	ErrStocktakeNegativeCount = errors.New("counted quantity can't go below zero")
+)
+
+// StocktakeOverlapError is returned when an open stocktake already counts some of the
+// same stock: a product of this one at the same location, or either counts on-hand.
+type StocktakeOverlapError struct {
+	StocktakeID uint
+}
+
+func (e *StocktakeOverlapError) Error() string {
+	return fmt.Sprintf("stocktake %d is already counting some of these products", e.StocktakeID)
+}
+
+// StocktakeCountEntry is a counted quantity for one line of a stocktake.
+type StocktakeCountEntry struct {
+	LineID   uint
+	Quantity int
+}
+
+// OpenStocktake creates st inside tx with a line per stock-tracked product (or variant)
+// in its scope, each expecting what the stock holds now: the location's quantity, or the
+// line's on-hand when st has no location.
+func OpenStocktake(tx *gorm.DB, st *models.Stocktake, now time.Time) error {
+	query := tx.Select("id", "sku", "stock_quantity", "manage_stock").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "product_id", "sku", "stock_quantity", "manage_stock").Order("id ASC")
+		}).
+		Where("organization_id = ?", st.OrganizationID)
+	if st.CategoryID != nil {
+		query = query.Where("local_category_id = ?", *st.CategoryID)
+	}
+	var products []models.Product
+	if err := query.Order("id ASC").Find(&products).Error; err != nil {
+		return err
+	}
+
+	var lines []models.StocktakeLine
+	onHand := make(map[stockKey]int)
+	productIDs := make([]uint, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ID)
+		if len(p.Variants) == 0 && p.ManageStock {
+			lines = append(lines, models.StocktakeLine{ProductID: p.ID, SKU: p.SKU})
+			onHand[keyOf(p.ID, nil)] = p.StockQuantity
+		}
+		for _, v := range p.Variants {
+			if !v.ManageStock {
+				continue
+			}
+			sku := v.SKU
+			if sku == "" {
+				sku = p.SKU
+			}
+			lines = append(lines, models.StocktakeLine{ProductID: p.ID, VariantID: &v.ID, SKU: sku})
+			onHand[keyOf(p.ID, &v.ID)] = v.StockQuantity
+		}
+	}
+	if len(lines) == 0 {
+		return ErrStocktakeEmpty
+	}
+	if err := checkStocktakeOverlap(tx, st, productIDs); err != nil {
+		return err
+	}
+
+	expected := onHand
+	if st.LocationID != nil {
+		var err error
+		if expected, err = locationStockOf(tx, st.OrganizationID, *st.LocationID, productIDs, onHand); err != nil {
+			return err
+		}
+	}
+	for i := range lines {
+		lines[i].ExpectedQty = expected[keyOf(lines[i].ProductID, lines[i].VariantID)]
+	}
+
+	st.Status, st.FrozenAt, st.Lines = StocktakeCounting, now, lines
+	return tx.Create(st).Error
+}
+
+// SubmitStocktakeCounts adds counts to a counting stocktake's lines inside tx, which should
+// hold the stocktake row's lock. st.Lines must be loaded; the counted lines are updated.
+func SubmitStocktakeCounts(tx *gorm.DB, st *models.Stocktake, entries []StocktakeCountEntry, userID *uint) error {
+	if st.Status != StocktakeCounting {
+		return fmt.Errorf("%w: %s stocktake can't take counts", ErrStocktakeStatus, st.Status)
+	}
+	index := make(map[uint]int, len(st.Lines))
+	for i, l := range st.Lines {
+		index[l.ID] = i
+	}
+	touched := make(map[uint]bool, len(entries))
+	for _, e := range entries {
+		i, ok := index[e.LineID]
+		if !ok {
+			return fmt.Errorf("line %d is not on stocktake %d", e.LineID, st.ID)
+		}
+		l := &st.Lines[i]
+		counted := e.Quantity
+		if l.CountedQty != nil {
+			counted += *l.CountedQty
+		}
+		if counted < 0 {
+			return fmt.Errorf("%w: line %d (%s) has %d counted", ErrStocktakeNegativeCount, l.ID, l.SKU, counted-e.Quantity)
+		}
+		l.CountedQty = &counted
+		touched[l.ID] = true
+		if err := tx.Create(&models.StocktakeCount{StocktakeID: st.ID, LineID: l.ID, UserID: userID, Quantity: e.Quantity}).Error; err != nil {
+			return err
+		}
+	}
+	for id := range touched {
+		if err := tx.Model(&models.StocktakeLine{}).Where("id = ?", id).
+			Update("counted_qty", st.Lines[index[id]].CountedQty).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApproveStocktake posts a counting stocktake's differences inside tx, which should hold
+// the stocktake row's lock, as one count_correction stock adjustment. Each line moves by
+// what was counted less what it held when counted (its expected quantity plus what moved
+// between the freeze and its first count), rather than being set to what was counted, so
+// stock that moved after the count (orders taken, transfers received) stays moved and an
+// order shipped before the shelf was counted isn't taken off twice. Lines never counted
+// are left alone unless zeroUncounted, when they count as none now; lines whose product
+// has since been deleted are skipped.
+func ApproveStocktake(tx *gorm.DB, st *models.Stocktake, approverID *uint, zeroUncounted bool, now time.Time) ([]StockChange, error) {
+	if st.Status != StocktakeCounting {
+		return nil, fmt.Errorf("%w: %s stocktake can't be approved", ErrStocktakeStatus, st.Status)
+	}
+	live, err := liveStockLines(tx, st.Lines)
+	if err != nil {
+		return nil, err
+	}
+	beforeCount, err := StocktakeMovedBeforeCount(tx, []uint{st.ID})
+	if err != nil {
+		return nil, err
+	}
+	var since map[uint]int
+	if zeroUncounted {
+		if since, err = StocktakeMovedSince(tx, st); err != nil {
+			return nil, err
+		}
+	}
+
+	adj := models.StockAdjustment{
+		OrganizationID: st.OrganizationID,
+		Status:         AdjustmentPending,
+		Note:           fmt.Sprintf("Stocktake #%d", st.ID),
+		RequestedBy:    st.CreatedBy,
+	}
+	for _, l := range st.Lines {
+		counted, held := 0, l.ExpectedQty
+		switch {
+		case l.CountedQty != nil:
+			counted, held = *l.CountedQty, held+beforeCount[l.ID]
+		case zeroUncounted:
+			held += since[l.ID]
+		default:
+			continue
+		}
+		if diff := counted - held; diff != 0 && live[keyOf(l.ProductID, l.VariantID)] {
+			adj.Lines = append(adj.Lines, models.StockAdjustmentLine{
+				ProductID:  l.ProductID,
+				VariantID:  l.VariantID,
+				LocationID: st.LocationID,
+				Reason:     AdjustReasonCountCorrection,
+				Mode:       AdjustModeDelta,
+				Quantity:   diff,
+			})
+		}
+	}
+
+	var changes []StockChange
+	updates := map[string]interface{}{"status": StocktakeApproved, "approved_at": now, "approved_by": approverID}
+	if len(adj.Lines) > 0 {
+		if err := tx.Create(&adj).Error; err != nil {
+			return nil, err
+		}
+		if changes, err = ApplyAdjustment(tx, &adj, approverID, now); err != nil {
+			return nil, err
+		}
+		updates["adjustment_id"], st.AdjustmentID = adj.ID, &adj.ID
+	}
+	st.Status, st.ApprovedAt, st.ApprovedBy = StocktakeApproved, &now, approverID
+	return changes, tx.Model(&models.Stocktake{}).Where("id = ?", st.ID).Updates(updates).Error
+}
+
+// CancelStocktake drops a counting stocktake inside tx; no stock moves.
+func CancelStocktake(tx *gorm.DB, st *models.Stocktake, now time.Time) error {
+	if st.Status != StocktakeCounting {
+		return fmt.Errorf("%w: %s stocktake can't be cancelled", ErrStocktakeStatus, st.Status)
+	}
+	st.Status, st.CancelledAt = StocktakeCancelled, &now
+	return tx.Model(&models.Stocktake{}).Where("id = ?", st.ID).
+		Updates(map[string]interface{}{"status": st.Status, "cancelled_at": now}).Error
+}
+
+// StocktakeMovedSince is how much each line's stock (at the stocktake's location, or its
+// on-hand) has moved since the expected quantities were frozen, by line ID, leaving out
+// the stocktake's own adjustment.
+func StocktakeMovedSince(db *gorm.DB, st *models.Stocktake) (map[uint]int, error) {
+	moved := make(map[uint]int, len(st.Lines))
+	if len(st.Lines) == 0 {
+		return moved, nil
+	}
+	productIDs := make([]uint, 0, len(st.Lines))
+	for _, l := range st.Lines {
+		productIDs = append(productIDs, l.ProductID)
+	}
+	query := db.Model(&models.InventoryMovement{}).
+		Select("product_id, COALESCE(variant_id, 0) AS variant_id, SUM(change_qty) AS moved").
+		Where("product_id IN ? AND created_at > ?", productIDs, st.FrozenAt)
+	if st.LocationID != nil {
+		query = query.Where("location_id = ?", *st.LocationID)
+	} else {
+		query = query.Where("reason <> ?", MovementTransferTransit)
+	}
+	if st.AdjustmentID != nil {
+		query = query.Where("ref <> ?", AdjustmentRef(*st.AdjustmentID))
+	}
+	var rows []struct {
+		ProductID uint
+		VariantID uint
+		Moved     int
+	}
+	if err := query.Group("product_id, COALESCE(variant_id, 0)").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[stockKey]int, len(rows))
+	for _, r := range rows {
+		byKey[stockKey{ProductID: r.ProductID, VariantID: r.VariantID}] = r.Moved
+	}
+	for _, l := range st.Lines {
+		moved[l.ID] = byKey[keyOf(l.ProductID, l.VariantID)]
+	}
+	return moved, nil
+}
+
+// StocktakeMovedBeforeCount is how much each counted line of the stocktakes moved between
+// its stocktake's freeze and the line's first count, by line ID. That is when the shelf
+// was counted, so the line held its ExpectedQty plus this; an order shipped in between is
+// already missing from the count.
+func StocktakeMovedBeforeCount(db *gorm.DB, stocktakeIDs []uint) (map[uint]int, error) {
+	moved := make(map[uint]int)
+	if len(stocktakeIDs) == 0 {
+		return moved, nil
+	}
+	var rows []struct {
+		LineID uint
+		Moved  int
+	}
+	err := db.Raw(`
+		SELECT l.id AS line_id, SUM(m.change_qty) AS moved
+		FROM stocktakes s
+		JOIN stocktake_lines l ON l.stocktake_id = s.id
+		JOIN (
+		  SELECT line_id, MIN(created_at) AS first_at FROM stocktake_counts
+		  WHERE stocktake_id IN @ids GROUP BY line_id
+		) c ON c.line_id = l.id
+		JOIN inventory_movements m ON m.product_id = l.product_id
+		  AND COALESCE(m.variant_id, 0) = COALESCE(l.variant_id, 0)
+		  AND m.created_at > s.frozen_at AND m.created_at <= c.first_at
+		  AND (m.location_id = s.location_id OR (s.location_id IS NULL AND m.reason <> @transit))
+		WHERE s.id IN @ids
+		GROUP BY l.id`,
+		map[string]interface{}{"ids": stocktakeIDs, "transit": MovementTransferTransit}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		moved[r.LineID] = r.Moved
+	}
+	return moved, nil
+}
+
+// checkStocktakeOverlap looks for an open stocktake counting any of productIDs at st's
+// location; a stocktake without a location overlaps every location.
+func checkStocktakeOverlap(tx *gorm.DB, st *models.Stocktake, productIDs []uint) error {
+	query := tx.Model(&models.Stocktake{}).
+		Joins("JOIN stocktake_lines l ON l.stocktake_id = stocktakes.id").
+		Where("stocktakes.organization_id = ? AND stocktakes.status = ? AND l.product_id IN ?", st.OrganizationID, StocktakeCounting, productIDs)
+	if st.LocationID != nil {
+		query = query.Where("(stocktakes.location_id IS NULL OR stocktakes.location_id = ?)", *st.LocationID)
+	}
+	var open []uint
+	if err := query.Limit(1).Pluck("stocktakes.id", &open).Error; err != nil {
+		return err
+	}
+	if len(open) > 0 {
+		return &StocktakeOverlapError{StocktakeID: open[0]}
+	}
+	return nil
+}
+
+// locationStockOf is what a location holds of each stock line of productIDs. A line not
+// yet stocked per location holds its on-hand at the org's default location (or at any
+// location when there's no default), where locationRow would place it.
+func locationStockOf(tx *gorm.DB, orgID, locationID uint, productIDs []uint, onHand map[stockKey]int) (map[stockKey]int, error) {
+	var rows []struct {
+		ProductID  uint
+		VariantID  *uint
+		LocationID uint
+		StockQty   int
+	}
+	if err := tx.Model(&models.ProductLocationStock{}).
+		Select("product_id, variant_id, location_id, stock_qty").
+		Where("product_id IN ?", productIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	stocked := make(map[stockKey]bool, len(rows))
+	held := make(map[stockKey]int, len(onHand))
+	for _, r := range rows {
+		k := keyOf(r.ProductID, r.VariantID)
+		stocked[k] = true
+		if r.LocationID == locationID {
+			held[k] = r.StockQty
+		}
+	}
+
+	var def models.SellerLocation
+	err := tx.Select("id").Where("organization_id = ? AND is_default", orgID).First(&def).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	holdsUnplaced := err != nil || def.ID == locationID
+	for k, qty := range onHand {
+		if !stocked[k] && holdsUnplaced {
+			held[k] = qty
+		}
+	}
+	return held, nil
+}
+
+// liveStockLines reports which of the lines' products (or variants) still exist.
+func liveStockLines(tx *gorm.DB, lines []models.StocktakeLine) (map[stockKey]bool, error) {
+	var productIDs, variantIDs []uint
+	for _, l := range lines {
+		productIDs = append(productIDs, l.ProductID)
+		if l.VariantID != nil {
+			variantIDs = append(variantIDs, *l.VariantID)
+		}
+	}
+	var products, variants []uint
+	if len(productIDs) > 0 {
+		if err := tx.Model(&models.Product{}).Where("id IN ?", productIDs).Pluck("id", &products).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(variantIDs) > 0 {
+		if err := tx.Model(&models.ProductVariant{}).Where("id IN ?", variantIDs).Pluck("id", &variants).Error; err != nil {
+			return nil, err
+		}
+	}
+	liveProduct := make(map[uint]bool, len(products))
+	for _, id := range products {
+		liveProduct[id] = true
+	}
+	liveVariant := make(map[uint]bool, len(variants))
+	for _, id := range variants {
+		liveVariant[id] = true
+	}
+	live := make(map[stockKey]bool, len(lines))
+	for _, l := range lines {
+		live[keyOf(l.ProductID, l.VariantID)] = liveProduct[l.ProductID] && (l.VariantID == nil || liveVariant[*l.VariantID])
+	}
+	return live, nil
+}
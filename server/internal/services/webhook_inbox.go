@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/RvShivam/inventify/internal/channels"
+	"github.com/RvShivam/inventify/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook inbox statuses
+const (
+	InboxReceived   = "received"
+	InboxProcessing = "processing"
+	InboxProcessed  = "processed"
+	InboxFailed     = "failed"
+)
+
+const (
+	// MaxWebhookAttempts is how often a delivery is retried automatically before it
+	// is left failed for someone to replay.
+	MaxWebhookAttempts = 5
+	// WebhookClaimTimeout is how long a delivery may sit in processing before another
+	// consumer may take it over (the first one crashed without finishing).
+	WebhookClaimTimeout = 5 * time.Minute
+)
+
+// ErrWebhookBusy means another consumer holds a live claim on the delivery; it may yet
+// die without finishing, so the message should come back later rather than be dropped.
+var ErrWebhookBusy = errors.New("webhook delivery is being processed by another consumer")
+
+// RecordWebhook stores a verified delivery in the inbox. When the same delivery
+// (delivery ID and body) is already there, it only counts the duplicate and returns
+// the existing row with duplicate set.
+func RecordWebhook(db *gorm.DB, wh *channels.Webhook, deliveryID string, body []byte) (models.WebhookInbox, bool, error) {
+	sum := sha256.Sum256(body)
+	sourceJSON, _ := json.Marshal(wh.Source)
+	payloadJSON, err := json.Marshal(wh.Payload)
+	if err != nil {
+		return models.WebhookInbox{}, false, err
+	}
+	now := time.Now()
+
+	inbox := models.WebhookInbox{
+		OrganizationID: wh.OrganizationID,
+		Channel:        wh.Channel,
+		DeliveryID:     deliveryID,
+		PayloadHash:    hex.EncodeToString(sum[:]),
+		Topic:          wh.Topic,
+		Source:         datatypes.JSON(sourceJSON),
+		Payload:        datatypes.JSON(payloadJSON),
+		Status:         InboxReceived,
+		LastSeenAt:     &now,
+	}
+	// ux_webhook_inbox_delivery
+	created := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel"}, {Name: "delivery_id"}, {Name: "payload_hash"}},
+		DoNothing: true,
+	}).Create(&inbox)
+	if created.Error != nil {
+		return inbox, false, created.Error
+	}
+	if created.RowsAffected > 0 {
+		return inbox, false, nil
+	}
+
+	var existing models.WebhookInbox
+	err = db.Model(&existing).
+		Clauses(clause.Returning{}).
+		Where("channel = ? AND delivery_id = ? AND payload_hash = ?", inbox.Channel, inbox.DeliveryID, inbox.PayloadHash).
+		Updates(map[string]interface{}{
+			"duplicate_count": gorm.Expr("duplicate_count + 1"),
+			"last_seen_at":    now,
+		}).Error
+	return existing, true, err
+}
+
+// ClaimWebhook marks a delivery as processing and returns its attempt count. A claim
+// older than WebhookClaimTimeout is taken over. claimed is false when it's already
+// processed; ErrWebhookBusy when another consumer's claim is still live.
+func ClaimWebhook(db *gorm.DB, id uint, now time.Time) (attempts int, claimed bool, err error) {
+	var inbox models.WebhookInbox
+	res := db.Model(&inbox).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "attempts"}}}).
+		Where("id = ? AND (status IN ? OR (status = ? AND updated_at < ?))",
+			id, []string{InboxReceived, InboxFailed}, InboxProcessing, now.Add(-WebhookClaimTimeout)).
+		Updates(map[string]interface{}{
+			"status":   InboxProcessing,
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+	if res.Error != nil {
+		return 0, false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return inbox.Attempts, true, nil
+	}
+	if err := db.Select("status", "attempts").First(&inbox, id).Error; err != nil {
+		return 0, false, err
+	}
+	if inbox.Status == InboxProcessing {
+		return inbox.Attempts, false, ErrWebhookBusy
+	}
+	return inbox.Attempts, false, nil
+}
+
+// ReplayableWebhook scopes to deliveries that may be handed to the consumers again:
+// received, failed, or stuck in processing past WebhookClaimTimeout (the consumer died).
+func ReplayableWebhook(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("(status IN ? OR (status = ? AND updated_at < ?))",
+		[]string{InboxReceived, InboxFailed}, InboxProcessing, now.Add(-WebhookClaimTimeout))
+}
+
+// FinishWebhook records the outcome of processing a claimed delivery.
+func FinishWebhook(db *gorm.DB, id uint, procErr error) error {
+	updates := map[string]interface{}{"status": InboxProcessed, "last_error": ""}
+	if procErr != nil {
+		updates = map[string]interface{}{"status": InboxFailed, "last_error": procErr.Error()}
+	} else {
+		updates["processed_at"] = time.Now()
+	}
+	return db.Model(&models.WebhookInbox{}).Where("id = ?", id).Updates(updates).Error
+}